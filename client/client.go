@@ -0,0 +1,331 @@
+// Package client provides a Go client for the events API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/declantraynor/go-events-service/interfaces/web"
+)
+
+const (
+	// DefaultTimeout bounds each attempt at a request.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries is the number of times a failed request is retried.
+	DefaultMaxRetries = 3
+
+	// DefaultBackoff is the delay before the first retry. It doubles with
+	// each subsequent retry, up to MaxBackoff.
+	DefaultBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff bounds the delay between retries.
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// maxErrorBodyBytes bounds how much of an error response is read.
+const maxErrorBodyBytes = 64 << 10
+
+// Client calls the events API. Its fields may be changed after New, but not
+// while requests are in flight.
+type Client struct {
+	// BaseURL is the root of the service, e.g. "http://localhost:5000".
+	BaseURL string
+
+	// APIKey, if set, is sent as a bearer token with every request.
+	APIKey string
+
+	// Tenant, if set, is sent in the X-Tenant header with every request.
+	Tenant string
+
+	// HTTPClient is used to send requests.
+	HTTPClient *http.Client
+
+	// Timeout bounds each attempt at a request. Zero means no timeout
+	// beyond that of the context passed in.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a request is retried after a
+	// network error or a response indicating the service is temporarily
+	// unable to handle it.
+	MaxRetries int
+
+	// Backoff and MaxBackoff control the delay between retries.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a Client for the service at `baseURL`, authenticating with
+// `apiKey`, with default timeout and retry settings.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+		Timeout:    DefaultTimeout,
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// AddEvent records an occurrence of the event `name` at time `t`.
+//
+// Since recording an event is not idempotent, AddEvent is only retried
+// when the service could not have recorded it: when the connection could
+// not be established, or the request was rate limited.
+func (client *Client) AddEvent(ctx context.Context, name string, t time.Time) error {
+	event := web.EventResource{Name: name, Timestamp: formatTime(t)}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	sent := map[string]string{"timestamp": event.Timestamp}
+	return client.do(ctx, "POST", "/v1/events", nil, body, false, sent, nil)
+}
+
+// AddEvents records every event in `events`, which may number at most
+// usecases.MaxBatchEvents, in a single request. If any is invalid, none is
+// recorded.
+//
+// Like AddEvent, AddEvents is only retried when the service could not have
+// recorded the events.
+func (client *Client) AddEvents(ctx context.Context, events []Event) error {
+	batch := web.BatchResource{Events: make([]web.EventResource, len(events))}
+	sent := map[string]string{}
+	for i, event := range events {
+		batch.Events[i] = web.EventResource{Name: event.Name, Timestamp: formatTime(event.Time)}
+		sent[fmt.Sprintf("events[%d].timestamp", i)] = batch.Events[i].Timestamp
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return client.do(ctx, "POST", "/v1/events/batch", nil, body, false, sent, nil)
+}
+
+// Count returns the number of events of each name which occurred at or
+// after `from` and before `to`. Names with no events in the range are
+// omitted.
+func (client *Client) Count(ctx context.Context, from, to time.Time) (map[string]int, error) {
+	query := url.Values{}
+	query.Set("from", formatTime(from))
+	query.Set("to", formatTime(to))
+
+	sent := map[string]string{"from": query.Get("from"), "to": query.Get("to")}
+	counts := map[string]int{}
+	if err := client.do(ctx, "GET", "/v1/events/count", query, nil, true, sent, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// formatTime formats `t` as the service expects: an ISO8601 UTC timestamp.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// do sends a request, retrying it as allowed, and decodes a successful
+// response into `result`. `idempotent` reports whether the request may be
+// retried after any temporary failure. `sent` holds the parameter values
+// sent, so they can be included in any typed error returned.
+func (client *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, idempotent bool, sent map[string]string, result interface{}) error {
+	u := client.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.attempt(ctx, method, u, body)
+		if err == nil && res.status < http.StatusBadRequest {
+			if result == nil {
+				return nil
+			}
+			if err := json.Unmarshal(res.body, result); err != nil {
+				return fmt.Errorf("decoding response from %s %s: %s", method, path, err)
+			}
+			return nil
+		}
+
+		var retryAfter time.Duration
+		retry := false
+		if err != nil {
+			retry = ctx.Err() == nil && (idempotent || isDialError(err))
+		} else {
+			err = decodeError(res, sent, client.Tenant)
+			retry = res.status == http.StatusTooManyRequests || (idempotent && retryableStatus(res.status))
+			retryAfter = res.retryAfter
+		}
+
+		if !retry || attempt >= client.MaxRetries {
+			return err
+		}
+
+		delay := client.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if err := client.wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// response holds the parts of a HTTP response the client needs, read in
+// full so the connection can be reused.
+type response struct {
+	status      int
+	contentType string
+	body        []byte
+	retryAfter  time.Duration
+}
+
+func (client *Client) attempt(ctx context.Context, method, u string, body []byte) (*response, error) {
+	if client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+		defer cancel()
+	}
+
+	res, err := client.send(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	limit := int64(maxErrorBodyBytes)
+	if res.StatusCode < http.StatusBadRequest {
+		limit = 1 << 30
+	}
+	return readResponse(res, limit)
+}
+
+// readResponse reads up to `limit` bytes of the body of `res`.
+func readResponse(res *http.Response, limit int64) (*response, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{
+		status:      res.StatusCode,
+		contentType: res.Header.Get("Content-Type"),
+		body:        data,
+		retryAfter:  parseRetryAfter(res.Header.Get("Retry-After")),
+	}, nil
+}
+
+// send sends a request with the client's credentials, returning the
+// response with its body unread.
+func (client *Client) send(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+client.APIKey)
+	}
+	if client.Tenant != "" {
+		req.Header.Set(web.TenantHeader, client.Tenant)
+	}
+
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+// backoff returns the delay before retry number `attempt`, doubling from
+// Backoff up to MaxBackoff, with jitter so that many clients failing at
+// once do not retry in lockstep.
+func (client *Client) backoff(attempt int) time.Duration {
+	delay := client.Backoff
+	for i := 0; i < attempt && (client.MaxBackoff <= 0 || delay < client.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if client.MaxBackoff > 0 && delay > client.MaxBackoff {
+		delay = client.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (client *Client) wait(ctx context.Context, d time.Duration) error {
+	if client.sleep != nil {
+		return client.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryableStatus reports whether a response status indicates a temporary
+// failure, after which an idempotent request may be retried.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isDialError reports whether `err` occurred while establishing a
+// connection, in which case the request was never sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	for err != nil {
+		if e, ok := err.(*net.OpError); ok {
+			opErr = e
+			break
+		}
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = unwrapper.Unwrap()
+	}
+	return opErr != nil && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
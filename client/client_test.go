@@ -0,0 +1,344 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/interfaces/web"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+var keys = map[string]usecases.KeyOptions{
+	"writer":      {Scopes: []string{usecases.ScopeWrite, usecases.ScopeRead}},
+	"reader":      {Scopes: []string{usecases.ScopeRead}},
+	"acme-reader": {Scopes: []string{usecases.ScopeRead}, Tenant: "acme"},
+	"admin":       {Scopes: []string{usecases.ScopeAdmin}},
+}
+
+// newServer starts a server running the real WebService against `store`,
+// with its handler wrapped by `wrap` if given.
+func newServer(t *testing.T, store domain.EventStore, wrap func(http.Handler) http.Handler) *httptest.Server {
+	auth := &usecases.AuthInteractor{Keys: new(StubAPIKeyStore)}
+	for key, options := range keys {
+		if err := auth.RegisterKey(key, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service := web.WebService{
+		EventInteractor: &usecases.EventInteractor{Store: store},
+		AuthInteractor:  auth,
+	}
+
+	handler := service.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newClient returns a Client for `server` which records, rather than
+// waits for, the delays between retries.
+func newClient(server *httptest.Server, apiKey string, delays *[]time.Duration) *Client {
+	client := New(server.URL+"/", apiKey)
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		if delays != nil {
+			*delays = append(*delays, d)
+		}
+		return nil
+	}
+	return client
+}
+
+// failing wraps a handler so that its first `n` requests are answered with
+// `status` instead.
+func failing(n int, status int) (func(http.Handler) http.Handler, *int) {
+	var mu sync.Mutex
+	requests := 0
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			requests++
+			fail := requests <= n
+			mu.Unlock()
+
+			if !fail {
+				next.ServeHTTP(res, req)
+				return
+			}
+			res.Header().Set("Content-Type", web.ProblemContentType)
+			res.WriteHeader(status)
+			res.Write([]byte(`{"type": "about:blank", "title": "Failed", "status": 503, "code": "store.unavailable"}`))
+		})
+	}, &requests
+}
+
+var (
+	t0 = time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)
+	t1 = t0.Add(time.Minute)
+)
+
+func TestAddEventAndCount(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "writer", nil)
+	ctx := context.Background()
+
+	events := []struct {
+		name string
+		at   time.Time
+	}{
+		{"foo", t0},
+		{"foo", t0.Add(30 * time.Second)},
+		{"bar", t0.In(time.FixedZone("EST", -5*60*60))},
+		{"baz", t1.Add(time.Hour)},
+	}
+	for _, event := range events {
+		if err := client.AddEvent(ctx, event.name, event.at); err != nil {
+			t.Fatalf("unexpected error adding event: %s", err)
+		}
+	}
+
+	counts, err := client.Count(ctx, t0, t1)
+	if err != nil {
+		t.Fatalf("unexpected error counting events: %s", err)
+	}
+
+	expected := map[string]int{"foo": 2, "bar": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected counts %v, got %v", expected, counts)
+	}
+}
+
+func TestCountInvalidTimeRange(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "reader", nil)
+
+	_, err := client.Count(context.Background(), t1, t0)
+
+	expected := usecases.InvalidTimeRangeError{From: "2015-02-11T15:02:00Z", To: "2015-02-11T15:01:00Z"}
+	if err != expected {
+		t.Errorf("expected %#v, got %#v", expected, err)
+	}
+}
+
+func TestAuthErrors(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	ctx := context.Background()
+
+	err := newClient(server, "wrong", nil).AddEvent(ctx, "foo", t0)
+	if _, ok := err.(usecases.UnauthenticatedError); !ok {
+		t.Errorf("expected UnauthenticatedError, got %#v", err)
+	}
+
+	err = newClient(server, "reader", nil).AddEvent(ctx, "foo", t0)
+	if err != (usecases.ForbiddenError{Scope: usecases.ScopeWrite}) {
+		t.Errorf("expected ForbiddenError for %s, got %#v", usecases.ScopeWrite, err)
+	}
+
+	client := newClient(server, "acme-reader", nil)
+	client.Tenant = "globex"
+	_, err = client.Count(ctx, t0, t1)
+	if err != (usecases.TenantForbiddenError{Tenant: "globex"}) {
+		t.Errorf("expected TenantForbiddenError, got %#v", err)
+	}
+}
+
+func TestAddEvents(t *testing.T) {
+	store := storetest.NewEventStore()
+	server := newServer(t, store, nil)
+	client := newClient(server, "writer", nil)
+
+	events := []Event{{Name: "foo", Time: t0}, {Name: "foo", Time: t0}, {Name: "bar", Time: t0}}
+	if err := client.AddEvents(context.Background(), events); err != nil {
+		t.Fatalf("unexpected error adding events: %s", err)
+	}
+
+	counts, _ := client.Count(context.Background(), t0, t1)
+	if counts["foo"] != 2 || counts["bar"] != 1 {
+		t.Errorf("expected every event to be recorded, got %v", counts)
+	}
+}
+
+func TestAddEventsInvalidEvent(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "writer", nil)
+
+	err := client.AddEvents(context.Background(), []Event{{Name: "foo", Time: t0}, {Name: "", Time: t0}})
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusBadRequest || e.Field != "events[1].name" {
+		t.Errorf("expected an error naming events[1].name, got %#v", err)
+	}
+}
+
+func TestTenantIsSent(t *testing.T) {
+	store := storetest.NewEventStore()
+	server := newServer(t, store, nil)
+	client := newClient(server, "admin", nil)
+	client.Tenant = "acme"
+
+	if err := client.AddEvent(context.Background(), "foo", t0); err != nil {
+		t.Fatalf("unexpected error adding event: %s", err)
+	}
+
+	if count, _ := store.ForTenant("acme").CountInTimeRange("foo", t0.Unix(), t0.Unix()); count != 1 {
+		t.Errorf("expected event to be recorded for tenant acme, got count %d", count)
+	}
+}
+
+func TestStoreErrorsMatchDomainErrors(t *testing.T) {
+	server := newServer(t, new(StubUnavailableEventStore), nil)
+	client := newClient(server, "reader", nil)
+	client.MaxRetries = 0
+
+	_, err := client.Count(context.Background(), t0, t1)
+
+	if !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected error matching domain.ErrUnavailable, got %#v", err)
+	}
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusServiceUnavailable || e.RequestID == "" {
+		t.Errorf("expected *Error with status and request ID, got %#v", err)
+	}
+}
+
+func TestCountIsRetried(t *testing.T) {
+	wrap, requests := failing(2, http.StatusServiceUnavailable)
+	server := newServer(t, storetest.NewEventStore(), wrap)
+	var delays []time.Duration
+	client := newClient(server, "reader", &delays)
+	client.Backoff = 100 * time.Millisecond
+
+	if _, err := client.Count(context.Background(), t0, t1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if *requests != 3 {
+		t.Errorf("expected 3 requests, got %d", *requests)
+	}
+	if len(delays) != 2 {
+		t.Fatalf("expected 2 delays, got %v", delays)
+	}
+	if delays[0] < 50*time.Millisecond || delays[0] > 100*time.Millisecond {
+		t.Errorf("expected first delay between 50ms and 100ms, got %s", delays[0])
+	}
+	if delays[1] < 100*time.Millisecond || delays[1] > 200*time.Millisecond {
+		t.Errorf("expected second delay between 100ms and 200ms, got %s", delays[1])
+	}
+}
+
+func TestRetriesAreLimited(t *testing.T) {
+	wrap, requests := failing(10, http.StatusServiceUnavailable)
+	server := newServer(t, storetest.NewEventStore(), wrap)
+	client := newClient(server, "reader", nil)
+	client.MaxRetries = 2
+
+	_, err := client.Count(context.Background(), t0, t1)
+
+	if !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected error matching domain.ErrUnavailable, got %#v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("expected 3 requests, got %d", *requests)
+	}
+}
+
+func TestAddEventIsOnlyRetriedWhenNotRecorded(t *testing.T) {
+	wrap, requests := failing(1, http.StatusServiceUnavailable)
+	server := newServer(t, storetest.NewEventStore(), wrap)
+
+	if err := newClient(server, "writer", nil).AddEvent(context.Background(), "foo", t0); err == nil {
+		t.Error("expected error")
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+
+	wrap, requests = failing(1, http.StatusTooManyRequests)
+	server = newServer(t, storetest.NewEventStore(), wrap)
+
+	if err := newClient(server, "writer", nil).AddEvent(context.Background(), "foo", t0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+}
+
+func TestRetryAfterIsRespected(t *testing.T) {
+	var requests int
+	server := newServer(t, storetest.NewEventStore(), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			requests++
+			if requests == 1 {
+				res.Header().Set("Retry-After", "3")
+				res.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	})
+	var delays []time.Duration
+	client := newClient(server, "reader", &delays)
+
+	if _, err := client.Count(context.Background(), t0, t1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(delays) != 1 || delays[0] != 3*time.Second {
+		t.Errorf("expected a single 3s delay, got %v", delays)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+		})
+	})
+	client := newClient(server, "reader", nil)
+	client.Timeout = 10 * time.Millisecond
+	client.MaxRetries = 0
+
+	start := time.Now()
+	if _, err := client.Count(context.Background(), t0, t1); err == nil {
+		t.Error("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected request to time out, took %s", elapsed)
+	}
+}
+
+func TestDecodeTimestampError(t *testing.T) {
+	res := &response{
+		status:      http.StatusBadRequest,
+		contentType: web.ProblemContentType,
+		body:        []byte(`{"status": 400, "code": "timestamp.not_utc", "field": "from"}`),
+	}
+
+	err := decodeError(res, map[string]string{"from": "2015-02-11T15:01:00-05:00"}, "")
+
+	expected := usecases.InvalidTimestampError{Timestamp: "2015-02-11T15:01:00-05:00", Field: "from", NotUTC: true}
+	if err != expected {
+		t.Errorf("expected %#v, got %#v", expected, err)
+	}
+}
+
+func TestDecodeNonProblemError(t *testing.T) {
+	res := &response{status: http.StatusBadGateway, contentType: "text/html", body: []byte("<html>")}
+
+	err := decodeError(res, nil, "")
+
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusBadGateway {
+		t.Errorf("expected *Error with status 502, got %#v", err)
+	}
+}
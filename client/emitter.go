@@ -0,0 +1,352 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// OverflowPolicy determines what an Emitter does with an event when its
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// Block makes Emit wait for space in the queue, applying backpressure
+	// to the caller.
+	Block OverflowPolicy = iota
+
+	// DropNewest discards the event being emitted.
+	DropNewest
+
+	// DropOldest discards the oldest queued event to make space.
+	DropOldest
+)
+
+const (
+	// DefaultQueueSize is the number of events an Emitter queues by default.
+	DefaultQueueSize = 10000
+
+	// DefaultBatchSize is the number of queued events which triggers a flush,
+	// and the most sent in one request.
+	DefaultBatchSize = 100
+
+	// DefaultFlushInterval is how often queued events are flushed regardless
+	// of how many there are.
+	DefaultFlushInterval = time.Second
+
+	// DefaultConcurrency is the number of batches sent at once while flushing.
+	DefaultConcurrency = 4
+)
+
+var (
+	// ErrEmitterClosed is returned by Emit once Close has been called.
+	ErrEmitterClosed = errors.New("emitter is closed")
+
+	// ErrQueueFull is returned by Emit when the DropNewest policy discards
+	// the event being emitted.
+	ErrQueueFull = errors.New("emitter queue is full")
+)
+
+// Event is an occurrence of the event Name at Time, as sent by AddEvents and
+// an Emitter.
+type Event struct {
+	Name string
+	Time time.Time
+}
+
+// EmitterConfig configures an Emitter. Zero values select the defaults.
+// BatchSize is capped at usecases.MaxBatchEvents, the most the service
+// accepts in one request.
+type EmitterConfig struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Concurrency   int
+	Overflow      OverflowPolicy
+
+	// MaxRetries is the number of times the Emitter resends a batch after a
+	// network error or a response indicating a temporary failure, on top of
+	// any retries made by the Client. Since such a failure may occur after
+	// the batch was recorded, retried events may be counted more than once.
+	MaxRetries int
+
+	// OnError, if set, is called with each event which could not be sent.
+	// A batch is sent in one request, so one invalid event fails every
+	// event in its batch.
+	OnError func(event Event, err error)
+}
+
+// EmitterStats counts the events handled by an Emitter.
+type EmitterStats struct {
+	Sent    uint64
+	Failed  uint64
+	Dropped uint64
+}
+
+// Emitter sends events in the background. Events are queued in memory and
+// flushed whenever BatchSize of them are waiting, every FlushInterval, and
+// when the Emitter is closed.
+type Emitter struct {
+	client *Client
+	config EmitterConfig
+
+	queue   chan Event
+	flushes chan chan struct{}
+	closing chan struct{}
+	stopped chan struct{}
+
+	// emitting is held for reading by each call to Emit, so that run can
+	// wait for those under way when it is closed before draining the queue
+	emitting sync.RWMutex
+	closed   int32
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sent    uint64
+	failed  uint64
+	dropped uint64
+}
+
+// NewEmitter returns an Emitter which sends events using `client`. It must
+// be closed to release its resources and send any queued events.
+func (client *Client) NewEmitter(config EmitterConfig) *Emitter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.BatchSize > usecases.MaxBatchEvents {
+		config.BatchSize = usecases.MaxBatchEvents
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	emitter := &Emitter{
+		client:  client,
+		config:  config,
+		queue:   make(chan Event, config.QueueSize),
+		flushes: make(chan chan struct{}),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go emitter.run()
+	return emitter
+}
+
+// Emit queues an occurrence of the event `name` at time `t`. When the queue
+// is full it behaves according to the Overflow policy; under the Block
+// policy it waits until there is space, `ctx` is done or the Emitter is
+// closed.
+func (emitter *Emitter) Emit(ctx context.Context, name string, t time.Time) error {
+	emitter.emitting.RLock()
+	defer emitter.emitting.RUnlock()
+
+	select {
+	case <-emitter.closing:
+		return ErrEmitterClosed
+	default:
+	}
+
+	event := Event{Name: name, Time: t}
+	switch emitter.config.Overflow {
+	case DropNewest:
+		select {
+		case emitter.queue <- event:
+			return nil
+		default:
+			atomic.AddUint64(&emitter.dropped, 1)
+			return ErrQueueFull
+		}
+	case DropOldest:
+		for {
+			select {
+			case emitter.queue <- event:
+				return nil
+			default:
+			}
+			select {
+			case <-emitter.queue:
+				atomic.AddUint64(&emitter.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case emitter.queue <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-emitter.closing:
+			return ErrEmitterClosed
+		}
+	}
+}
+
+// Flush sends every queued event, returning once they have been sent or
+// `ctx` is done.
+func (emitter *Emitter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case emitter.flushes <- done:
+	case <-emitter.stopped:
+		return ErrEmitterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the Emitter accepting events and sends those still queued.
+// If `ctx` is done first, sending is abandoned and the remaining events are
+// counted as failed.
+func (emitter *Emitter) Close(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&emitter.closed, 0, 1) {
+		return ErrEmitterClosed
+	}
+	close(emitter.closing)
+
+	select {
+	case <-emitter.stopped:
+		emitter.cancel()
+		return nil
+	case <-ctx.Done():
+		emitter.cancel()
+		<-emitter.stopped
+		return ctx.Err()
+	}
+}
+
+// Stats returns the number of events sent, failed and dropped so far.
+func (emitter *Emitter) Stats() EmitterStats {
+	return EmitterStats{
+		Sent:    atomic.LoadUint64(&emitter.sent),
+		Failed:  atomic.LoadUint64(&emitter.failed),
+		Dropped: atomic.LoadUint64(&emitter.dropped),
+	}
+}
+
+func (emitter *Emitter) run() {
+	defer close(emitter.stopped)
+
+	ticker := time.NewTicker(emitter.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, emitter.config.BatchSize)
+	for {
+		select {
+		case event := <-emitter.queue:
+			batch = append(batch, event)
+			if len(batch) >= emitter.config.BatchSize {
+				emitter.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			emitter.send(batch)
+			batch = batch[:0]
+		case done := <-emitter.flushes:
+			emitter.send(emitter.drain(batch))
+			batch = batch[:0]
+			close(done)
+		case <-emitter.closing:
+			// Wait for calls to Emit under way, which now give up rather
+			// than block, so that every event they queued is drained.
+			emitter.emitting.Lock()
+			emitter.emitting.Unlock()
+			emitter.send(emitter.drain(batch))
+			return
+		}
+	}
+}
+
+// drain appends every queued event to `batch`.
+func (emitter *Emitter) drain(batch []Event) []Event {
+	for {
+		select {
+		case event := <-emitter.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+}
+
+// send sends `events` in batches of up to BatchSize, each in one request,
+// Concurrency batches at a time.
+func (emitter *Emitter) send(events []Event) {
+	batches := make(chan []Event)
+	var wg sync.WaitGroup
+	for i := 0; i < emitter.config.Concurrency && i*emitter.config.BatchSize < len(events); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				emitter.sendBatch(batch)
+			}
+		}()
+	}
+
+	for start := 0; start < len(events); start += emitter.config.BatchSize {
+		end := start + emitter.config.BatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		batches <- events[start:end]
+	}
+	close(batches)
+	wg.Wait()
+}
+
+func (emitter *Emitter) sendBatch(batch []Event) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = emitter.client.AddEvents(emitter.ctx, batch)
+		if err == nil {
+			atomic.AddUint64(&emitter.sent, uint64(len(batch)))
+			return
+		}
+		if !temporary(err) || attempt >= emitter.config.MaxRetries || emitter.ctx.Err() != nil {
+			break
+		}
+		if emitter.client.wait(emitter.ctx, emitter.client.backoff(attempt)) != nil {
+			break
+		}
+	}
+
+	atomic.AddUint64(&emitter.failed, uint64(len(batch)))
+	if emitter.config.OnError != nil {
+		for _, event := range batch {
+			emitter.config.OnError(event, err)
+		}
+	}
+}
+
+// temporary reports whether `err` may not recur if the request is resent:
+// a network error, or an error response indicating a temporary failure.
+// Errors describing the event itself, such as an invalid name, are not.
+func temporary(err error) bool {
+	if e, ok := err.(*Error); ok {
+		return e.Status >= http.StatusInternalServerError || e.Status == http.StatusTooManyRequests
+	}
+	_, isNetworkError := err.(interface{ Timeout() bool })
+	return isNetworkError
+}
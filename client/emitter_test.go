@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+// counting wraps a handler, counting the requests posted to it.
+func counting() (func(http.Handler) http.Handler, func() int) {
+	var mu sync.Mutex
+	posts := 0
+	wrap := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Method == "POST" {
+				mu.Lock()
+				posts++
+				mu.Unlock()
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+	return wrap, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return posts
+	}
+}
+
+// blocking wraps a handler so that requests wait until `release` is closed.
+func blocking(release chan struct{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			<-release
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+func count(t *testing.T, store domain.EventStore, name string) int {
+	n, err := store.ForTenant("default").CountInTimeRange(name, t0.Unix(), t1.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEmitterFlushesOnClose(t *testing.T) {
+	store := storetest.NewEventStore()
+	server := newServer(t, store, nil)
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		if err := emitter.Emit(context.Background(), "foo", t0); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := emitter.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+
+	if n := count(t, store, "foo"); n != 5 {
+		t.Errorf("expected 5 events recorded, got %d", n)
+	}
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 5}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := emitter.Emit(context.Background(), "foo", t0); err != ErrEmitterClosed {
+		t.Errorf("expected ErrEmitterClosed, got %v", err)
+	}
+}
+
+func TestEmitterFlushesBatches(t *testing.T) {
+	wrap, posts := counting()
+	store := storetest.NewEventStore()
+	server := newServer(t, store, wrap)
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{BatchSize: 3, FlushInterval: time.Hour})
+	defer emitter.Close(context.Background())
+
+	for i := 0; i < 4; i++ {
+		emitter.Emit(context.Background(), "foo", t0)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for posts() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n, recorded := posts(), count(t, store, "foo"); n != 1 || recorded != 3 {
+		t.Errorf("expected a batch of 3 events to be sent in one request, got %d events in %d", recorded, n)
+	}
+
+	if err := emitter.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error flushing: %s", err)
+	}
+	if n, recorded := posts(), count(t, store, "foo"); n != 2 || recorded != 4 {
+		t.Errorf("expected 4 events to be sent in 2 requests after flush, got %d events in %d", recorded, n)
+	}
+}
+
+func TestEmitterSplitsFlushesIntoBatches(t *testing.T) {
+	wrap, posts := counting()
+	store := storetest.NewEventStore()
+	server := newServer(t, store, wrap)
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{BatchSize: 10, FlushInterval: time.Hour})
+
+	// queue the events before the emitter can take any from its queue
+	emitter.emitting.Lock()
+	for i := 0; i < 25; i++ {
+		emitter.queue <- Event{Name: "foo", Time: t0}
+	}
+	emitter.emitting.Unlock()
+	emitter.Close(context.Background())
+
+	if n, recorded := posts(), count(t, store, "foo"); recorded != 25 || n < 3 || n > 4 {
+		t.Errorf("expected 25 events to be sent in batches of at most 10, got %d events in %d requests", recorded, n)
+	}
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 25}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEmitterFlushesOnInterval(t *testing.T) {
+	wrap, posts := counting()
+	server := newServer(t, storetest.NewEventStore(), wrap)
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{FlushInterval: 5 * time.Millisecond})
+	defer emitter.Close(context.Background())
+
+	emitter.Emit(context.Background(), "foo", t0)
+
+	deadline := time.Now().Add(time.Second)
+	for posts() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := posts(); n != 1 {
+		t.Errorf("expected event to be sent on interval, got %d", n)
+	}
+}
+
+// fillQueue starts an Emitter whose sends are blocked until `release` is
+// closed, and fills its queue of size 2.
+func fillQueue(t *testing.T, overflow OverflowPolicy, release chan struct{}) *Emitter {
+	server := newServer(t, storetest.NewEventStore(), blocking(release))
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{
+		QueueSize:     2,
+		BatchSize:     1,
+		Concurrency:   1,
+		FlushInterval: time.Hour,
+		Overflow:      overflow,
+	})
+
+	// The first event is taken from the queue and blocks in flight.
+	emitter.Emit(context.Background(), "first", t0)
+	for len(emitter.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	emitter.Emit(context.Background(), "second", t0)
+	emitter.Emit(context.Background(), "third", t0)
+	return emitter
+}
+
+func TestEmitterDropNewest(t *testing.T) {
+	release := make(chan struct{})
+	emitter := fillQueue(t, DropNewest, release)
+
+	if err := emitter.Emit(context.Background(), "fourth", t0); err != ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	close(release)
+	emitter.Close(context.Background())
+
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 3, Dropped: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEmitterDropOldest(t *testing.T) {
+	release := make(chan struct{})
+	emitter := fillQueue(t, DropOldest, release)
+
+	if err := emitter.Emit(context.Background(), "fourth", t0); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	queued := []string{(<-emitter.queue).Name, (<-emitter.queue).Name}
+	if queued[0] != "third" || queued[1] != "fourth" {
+		t.Errorf("expected oldest event to be dropped, queue holds %v", queued)
+	}
+	close(release)
+	emitter.Close(context.Background())
+
+	if stats := emitter.Stats(); stats.Dropped != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEmitterBlocks(t *testing.T) {
+	release := make(chan struct{})
+	emitter := fillQueue(t, Block, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := emitter.Emit(ctx, "fourth", t0); err != context.DeadlineExceeded {
+		t.Errorf("expected Emit to block until deadline, got %v", err)
+	}
+	close(release)
+	emitter.Close(context.Background())
+
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 3}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEmitterCloseWhileEmitBlocks(t *testing.T) {
+	release := make(chan struct{})
+	emitter := fillQueue(t, Block, release)
+
+	blocked := make(chan error)
+	go func() { blocked <- emitter.Emit(context.Background(), "fourth", t0) }()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() { closed <- emitter.Close(context.Background()) }()
+	select {
+	case err := <-blocked:
+		if err != ErrEmitterClosed {
+			t.Errorf("expected ErrEmitterClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to unblock Emit")
+	}
+
+	close(release)
+	if err := <-closed; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 3}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEmitterRetriesTemporaryFailures(t *testing.T) {
+	wrap, _ := failing(2, http.StatusServiceUnavailable)
+	store := storetest.NewEventStore()
+	server := newServer(t, store, wrap)
+	var delays []time.Duration
+	emitter := newClient(server, "writer", &delays).NewEmitter(EmitterConfig{MaxRetries: 2})
+
+	emitter.Emit(context.Background(), "foo", t0)
+	emitter.Close(context.Background())
+
+	if stats := emitter.Stats(); stats != (EmitterStats{Sent: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(delays) != 2 {
+		t.Errorf("expected 2 delays between retries, got %v", delays)
+	}
+	if n := count(t, store, "foo"); n != 1 {
+		t.Errorf("expected 1 event recorded, got %d", n)
+	}
+}
+
+func TestEmitterReportsFailures(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	var failed []Event
+	emitter := newClient(server, "writer", nil).NewEmitter(EmitterConfig{
+		MaxRetries: 3,
+		OnError:    func(event Event, err error) { failed = append(failed, event) },
+	})
+
+	emitter.Emit(context.Background(), "", t0)
+	emitter.Close(context.Background())
+
+	if stats := emitter.Stats(); stats != (EmitterStats{Failed: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(failed) != 1 {
+		t.Errorf("expected OnError to be called once, got %v", failed)
+	}
+}
+
+func TestEmitterCloseGivesUpAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	emitter := fillQueue(t, Block, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := emitter.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected Close to give up at deadline, got %v", err)
+	}
+	if stats := emitter.Stats(); stats.Failed != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/interfaces/web"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// Error describes a problem reported by the service which does not
+// correspond to one of the typed errors of the usecases and domain
+// packages, such as a rate limit being exceeded or an internal error.
+type Error struct {
+	Status        int
+	Code          string
+	Title         string
+	Detail        string
+	Field         string
+	InvalidParams []web.InvalidParam
+	RequestID     string
+}
+
+func (err *Error) Error() string {
+	message := err.Detail
+	if message == "" {
+		message = err.Title
+	}
+	if message == "" {
+		message = http.StatusText(err.Status)
+	}
+	if err.Code == "" {
+		return fmt.Sprintf("events api: %d: %s", err.Status, message)
+	}
+	return fmt.Sprintf("events api: %d %s: %s", err.Status, err.Code, message)
+}
+
+// Is allows store failures reported by the service to be matched against
+// the domain package's sentinel errors, e.g. errors.Is(err,
+// domain.ErrUnavailable).
+func (err *Error) Is(target error) bool {
+	switch err.Code {
+	case web.CodeNotFound:
+		return target == domain.ErrNotFound
+	case web.CodeConflict:
+		return target == domain.ErrConflict
+	case web.CodeUnavailable:
+		return target == domain.ErrUnavailable
+	case web.CodeTimeout:
+		return target == domain.ErrTimeout
+	}
+	return false
+}
+
+// decodeError turns an error response back into the error which caused
+// it. `sent` holds the parameter values sent with the request, since the
+// service does not echo them back.
+func decodeError(res *response, sent map[string]string, tenant string) error {
+	problem := web.ErrorResource{Status: res.status}
+	mediaType, _, _ := mime.ParseMediaType(res.contentType)
+	if mediaType == web.ProblemContentType || mediaType == "application/json" {
+		json.Unmarshal(res.body, &problem)
+	}
+	if problem.Status == 0 {
+		problem.Status = res.status
+	}
+
+	switch problem.Code {
+	case web.CodeTimestampNotISO8601, web.CodeTimestampNotUTC:
+		return usecases.InvalidTimestampError{
+			Timestamp:  sent[problem.Field],
+			Field:      problem.Field,
+			NotISO8601: problem.Code == web.CodeTimestampNotISO8601,
+			NotUTC:     problem.Code == web.CodeTimestampNotUTC,
+		}
+	case web.CodeRangeInverted:
+		return usecases.InvalidTimeRangeError{From: sent["from"], To: sent["to"]}
+	case web.CodeRangeEmpty:
+		return usecases.EmptyTimeRangeError{From: sent["from"], To: sent["to"]}
+	case web.CodeFieldInvalid:
+		return domain.ValidationError{
+			Field:  problem.Field,
+			Reason: strings.TrimPrefix(problem.Detail, problem.Field+" "),
+		}
+	case web.CodeTenantInvalid:
+		return usecases.InvalidTenantError{Tenant: tenant}
+	case web.CodeTenantForbidden:
+		return usecases.TenantForbiddenError{Tenant: tenant}
+	case web.CodeUnauthenticated:
+		return usecases.UnauthenticatedError{Reason: problem.Detail}
+	case web.CodeForbidden:
+		return usecases.ForbiddenError{Scope: quoted(problem.Detail)}
+	}
+
+	return &Error{
+		Status:        problem.Status,
+		Code:          problem.Code,
+		Title:         problem.Title,
+		Detail:        problem.Detail,
+		Field:         problem.Field,
+		InvalidParams: problem.InvalidParams,
+		RequestID:     problem.RequestID,
+	}
+}
+
+// quoted returns the first double-quoted string in `s`, or "" if there is
+// none.
+func quoted(s string) string {
+	start := strings.Index(s, `"`)
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(s[start+1:], `"`)
+	if end < 0 {
+		return ""
+	}
+	return s[start+1 : start+1+end]
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/declantraynor/go-events-service/interfaces/web"
+)
+
+// Export calls `fn` with each event which occurred between `from` and `to`,
+// optionally only those named `name`, as the service streams them. It stops
+// at the first error returned by `fn`.
+//
+// The request is retried as Count is until the service starts streaming
+// events, but not afterwards, since `fn` would see events repeated. Timeout
+// bounds only the wait for the stream to start.
+func (client *Client) Export(ctx context.Context, from, to time.Time, name string, fn func(Event) error) error {
+	query := url.Values{}
+	query.Set("from", formatTime(from))
+	query.Set("to", formatTime(to))
+	if name != "" {
+		query.Set("name", name)
+	}
+	u := client.BaseURL + "/v1/events/export?" + query.Encode()
+	sent := map[string]string{"from": query.Get("from"), "to": query.Get("to")}
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.startExport(ctx, u)
+		if err == nil && res.StatusCode < http.StatusBadRequest {
+			defer res.Body.Close()
+			return readExport(res, fn)
+		}
+
+		var retryAfter time.Duration
+		retry := false
+		if err != nil {
+			retry = ctx.Err() == nil
+		} else {
+			problem, readErr := readResponse(res, maxErrorBodyBytes)
+			res.Body.Close()
+			if readErr != nil {
+				return readErr
+			}
+			err = decodeError(problem, sent, client.Tenant)
+			retry = retryableStatus(problem.status)
+			retryAfter = problem.retryAfter
+		}
+
+		if !retry || attempt >= client.MaxRetries {
+			return err
+		}
+
+		delay := client.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if err := client.wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// startExport sends an export request, waiting at most Timeout for the
+// response header.
+func (client *Client) startExport(ctx context.Context, u string) (*http.Response, error) {
+	if client.Timeout <= 0 {
+		return client.send(ctx, "GET", u, nil)
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	timeout := time.AfterFunc(client.Timeout, cancel)
+	res, err := client.send(attemptCtx, "GET", u, nil)
+	if !timeout.Stop() || err != nil {
+		cancel()
+		if err == nil {
+			res.Body.Close()
+			err = context.DeadlineExceeded
+		}
+		return nil, err
+	}
+
+	// the attempt's context is released with the response body
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelOnClose cancels a request's context when its response body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// readExport decodes newline-delimited events from an export response.
+func readExport(res *http.Response, fn func(Event) error) error {
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var resource web.EventResource
+		if err := json.Unmarshal(scanner.Bytes(), &resource); err != nil {
+			return fmt.Errorf("decoding exported event: %s", err)
+		}
+		t, err := time.Parse(time.RFC3339, resource.Timestamp)
+		if err != nil {
+			return fmt.Errorf("decoding exported event: %s", err)
+		}
+		if err := fn(Event{Name: resource.Name, Time: t}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("export interrupted: %s", err)
+	}
+	return nil
+}
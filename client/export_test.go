@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+func populate(t *testing.T, client *Client, events []Event) {
+	for _, event := range events {
+		if err := client.AddEvent(context.Background(), event.Name, event.Time); err != nil {
+			t.Fatalf("unexpected error adding event: %s", err)
+		}
+	}
+}
+
+func collect(client *Client, name string) ([]Event, error) {
+	var events []Event
+	err := client.Export(context.Background(), t0, t1, name, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+var exportEvents = []Event{
+	{Name: "foo", Time: t0.Add(30 * time.Second)},
+	{Name: "bar", Time: t0},
+	{Name: "foo", Time: t0},
+	{Name: "foo", Time: t1.Add(time.Hour)},
+}
+
+func TestExport(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "writer", nil)
+	populate(t, client, exportEvents)
+
+	events, err := collect(client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []Event{exportEvents[1], exportEvents[2], exportEvents[0]}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+
+	events, _ = collect(client, "bar")
+	if !reflect.DeepEqual(events, exportEvents[1:2]) {
+		t.Errorf("expected %v, got %v", exportEvents[1:2], events)
+	}
+}
+
+func TestExportIsRetriedBeforeStreaming(t *testing.T) {
+	wrap, requests := failing(1, http.StatusServiceUnavailable)
+	store := storetest.NewEventStore()
+	server := newServer(t, store, wrap)
+	client := newClient(server, "writer", nil)
+	store.ForTenant(usecases.DefaultTenant).Put(domain.Event{Name: "foo", Timestamp: t0.Unix()})
+
+	events, err := collect(client, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(events) != 1 || *requests != 2 {
+		t.Errorf("expected 1 event after 2 requests, got %v after %d", events, *requests)
+	}
+}
+
+func TestExportStopsAtError(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "writer", nil)
+	populate(t, client, exportEvents)
+
+	stop := errors.New("stop")
+	calls := 0
+	err := client.Export(context.Background(), t0, t1, "", func(Event) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected export to stop at first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestExportInvalidTimeRange(t *testing.T) {
+	server := newServer(t, storetest.NewEventStore(), nil)
+	client := newClient(server, "reader", nil)
+
+	err := client.Export(context.Background(), t1, t0, "", func(Event) error { return nil })
+	if _, ok := err.(usecases.InvalidTimeRangeError); !ok {
+		t.Errorf("expected InvalidTimeRangeError, got %#v", err)
+	}
+}
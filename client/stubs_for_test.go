@@ -0,0 +1,50 @@
+package client
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+// EventStore which simulates the store being unreachable
+type StubUnavailableEventStore struct {
+	storetest.EventStore
+}
+
+func (stub *StubUnavailableEventStore) Names() ([]string, error) {
+	return nil, &domain.StoreError{Op: "fetching names", Kind: domain.ErrUnavailable, Err: errors.New("connection refused")}
+}
+
+func (stub *StubUnavailableEventStore) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
+// APIKeyStore which keeps keys in memory
+type StubAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]domain.APIKey
+}
+
+func (stub *StubAPIKeyStore) Get(hash string) (domain.APIKey, error) {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	key, ok := stub.keys[hash]
+	if !ok {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	return key, nil
+}
+
+func (stub *StubAPIKeyStore) Put(key domain.APIKey) error {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	if stub.keys == nil {
+		stub.keys = map[string]domain.APIKey{}
+	}
+	stub.keys[key.Hash] = key
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/declantraynor/go-events-service/client"
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/interfaces/datastore"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// config selects where commands read and write events, and how their output
+// is formatted. Its defaults are taken from the environment.
+type config struct {
+	server    string
+	apiKey    string
+	tenant    string
+	timeout   time.Duration
+	store     string
+	redisAddr string
+	redisPort string
+	output    string
+}
+
+func defaultConfig(getenv func(string) string) config {
+	c := config{
+		server:    getenv("EVENTS_SERVER"),
+		apiKey:    getenv("EVENTS_API_KEY"),
+		tenant:    getenv("EVENTS_TENANT"),
+		timeout:   client.DefaultTimeout,
+		store:     getenv("EVENTS_STORE"),
+		redisAddr: getenv("REDIS_PORT_6379_TCP_ADDR"),
+		redisPort: getenv("REDIS_PORT_6379_TCP_PORT"),
+		output:    outputTable,
+	}
+	if c.server == "" {
+		c.server = "http://localhost:5000"
+	}
+	if c.redisAddr == "" {
+		c.redisAddr = "127.0.0.1"
+	}
+	if c.redisPort == "" {
+		c.redisPort = "6379"
+	}
+	return c
+}
+
+func (c *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "server", c.server, "base URL of the events service ($EVENTS_SERVER)")
+	fs.StringVar(&c.apiKey, "api-key", c.apiKey, "API key for the events service ($EVENTS_API_KEY)")
+	fs.StringVar(&c.tenant, "tenant", c.tenant, "tenant to operate on ($EVENTS_TENANT)")
+	fs.DurationVar(&c.timeout, "timeout", c.timeout, "timeout for each request to the events service")
+	fs.StringVar(&c.store, "store", c.store, "use an event store directly instead of the service: redis ($EVENTS_STORE)")
+	fs.StringVar(&c.redisAddr, "redis-addr", c.redisAddr, "address of the redis server ($REDIS_PORT_6379_TCP_ADDR)")
+	fs.StringVar(&c.redisPort, "redis-port", c.redisPort, "port of the redis server ($REDIS_PORT_6379_TCP_PORT)")
+	fs.StringVar(&c.output, "output", c.output, "output format: table, json or csv")
+}
+
+// backend performs commands, either through the events service or directly
+// against an event store.
+type backend interface {
+	Send(name string, t time.Time) error
+	Count(from, to time.Time) (map[string]int, error)
+	Names() ([]string, error)
+	Export(from, to time.Time, name string, fn func(client.Event) error) error
+
+	// BuildRollups builds the rollups of the names stored before rollups
+	// were introduced, returning the number of names built.
+	BuildRollups() (int, error)
+
+	// Importer returns an importer which calls `onError` with each event
+	// which could not be recorded.
+	Importer(onError func(client.Event, error)) importer
+}
+
+// importer records a stream of events, possibly in the background.
+type importer interface {
+	Add(event client.Event) error
+
+	// Close waits for every event added to be recorded or to fail.
+	Close() (client.EmitterStats, error)
+}
+
+// openStore opens the event store named by `c.store`. It is a variable so
+// that tests can substitute an in-memory store.
+var openStore = func(c config) (domain.EventStore, error) {
+	switch c.store {
+	case "redis":
+		store, err := datastore.NewRedisEventStore(c.redisAddr, c.redisPort)
+		if err != nil {
+			return nil, err
+		}
+		return &store, nil
+	}
+	return nil, fmt.Errorf("unknown store %q", c.store)
+}
+
+func openBackend(c config) (backend, error) {
+	if c.store == "" {
+		api := client.New(c.server, c.apiKey)
+		api.Tenant = c.tenant
+		api.Timeout = c.timeout
+		return &remoteBackend{client: api}, nil
+	}
+
+	store, err := openStore(c)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := c.tenant
+	if tenant == "" {
+		tenant = usecases.DefaultTenant
+	}
+	if err := usecases.ValidateTenant(tenant); err != nil {
+		return nil, err
+	}
+	return &storeBackend{interactor: &usecases.EventInteractor{Store: store}, store: store, tenant: tenant}, nil
+}
+
+// remoteBackend performs commands through a running events service.
+type remoteBackend struct {
+	client *client.Client
+}
+
+// allTime spans every timestamp the service accepts.
+var allTime = [2]time.Time{
+	time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+}
+
+func (b *remoteBackend) Send(name string, t time.Time) error {
+	return b.client.AddEvent(context.Background(), name, t)
+}
+
+func (b *remoteBackend) Count(from, to time.Time) (map[string]int, error) {
+	return b.client.Count(context.Background(), from, to)
+}
+
+// Names lists the names of events recorded at any time. The service has no
+// endpoint listing names, but every recorded name has a non-zero count over
+// all time.
+func (b *remoteBackend) Names() ([]string, error) {
+	counts, err := b.client.Count(context.Background(), allTime[0], allTime[1])
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *remoteBackend) Export(from, to time.Time, name string, fn func(client.Event) error) error {
+	return b.client.Export(context.Background(), from, to, name, fn)
+}
+
+func (b *remoteBackend) BuildRollups() (int, error) {
+	return 0, errors.New("build-rollups works on the event store directly; select it with -store")
+}
+
+func (b *remoteBackend) Importer(onError func(client.Event, error)) importer {
+	return &emitterImporter{emitter: b.client.NewEmitter(client.EmitterConfig{OnError: onError})}
+}
+
+// emitterImporter imports events through an Emitter, which sends them
+// concurrently and applies backpressure once its queue is full.
+type emitterImporter struct {
+	emitter *client.Emitter
+}
+
+func (imp *emitterImporter) Add(event client.Event) error {
+	return imp.emitter.Emit(context.Background(), event.Name, event.Time)
+}
+
+func (imp *emitterImporter) Close() (client.EmitterStats, error) {
+	err := imp.emitter.Close(context.Background())
+	return imp.emitter.Stats(), err
+}
+
+// storeBackend performs commands directly against an event store, for
+// administration while the service is not running.
+type storeBackend struct {
+	interactor *usecases.EventInteractor
+	store      domain.EventStore
+	tenant     string
+}
+
+func (b *storeBackend) Send(name string, t time.Time) error {
+	return b.interactor.AddEvent(b.tenant, name, formatTime(t), usecases.EventOptions{})
+}
+
+func (b *storeBackend) Count(from, to time.Time) (map[string]int, error) {
+	return b.interactor.CountEventsInTimeRange(b.tenant, usecases.TimeRange{From: formatTime(from), To: formatTime(to)})
+}
+
+func (b *storeBackend) Names() ([]string, error) {
+	names, err := b.store.ForTenant(b.tenant).Names()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *storeBackend) Export(from, to time.Time, name string, fn func(client.Event) error) error {
+	return b.interactor.ExportEvents(b.tenant, usecases.TimeRange{From: formatTime(from), To: formatTime(to)}, name, func(event domain.Event) error {
+		return fn(client.Event{Name: event.Name, Time: time.Unix(event.Timestamp, 0).UTC()})
+	})
+}
+
+// rollupBuilder is implemented by event stores which count names from
+// rollups only once they have been built.
+type rollupBuilder interface {
+	BuildRollups() (int, error)
+}
+
+func (b *storeBackend) BuildRollups() (int, error) {
+	builder, ok := b.store.ForTenant(b.tenant).(rollupBuilder)
+	if !ok {
+		return 0, nil
+	}
+	return builder.BuildRollups()
+}
+
+func (b *storeBackend) Importer(onError func(client.Event, error)) importer {
+	return &storeImporter{backend: b, onError: onError}
+}
+
+// storeImporter records events one at a time, directly in the store.
+type storeImporter struct {
+	backend *storeBackend
+	onError func(client.Event, error)
+	stats   client.EmitterStats
+}
+
+func (imp *storeImporter) Add(event client.Event) error {
+	if err := imp.backend.Send(event.Name, event.Time); err != nil {
+		imp.stats.Failed++
+		imp.onError(event, err)
+
+		// a store failure will affect every subsequent event too
+		if _, invalid := err.(domain.ValidationError); !invalid {
+			return err
+		}
+		return nil
+	}
+	imp.stats.Sent++
+	return nil
+}
+
+func (imp *storeImporter) Close() (client.EmitterStats, error) {
+	return imp.stats, nil
+}
+
+// formatTime formats `t` as an ISO8601 UTC timestamp.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
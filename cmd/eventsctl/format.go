@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/declantraynor/go-events-service/client"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+var validOutputs = map[string]bool{outputTable: true, outputJSON: true, outputCSV: true}
+
+// eventRecord is the representation of an event read by import and written
+// by export, matching the body accepted by POST /events.
+type eventRecord struct {
+	Name      string `json:"name"`
+	Timestamp string `json:"timestamp"`
+}
+
+func writeCounts(out io.Writer, output string, counts map[string]int) error {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch output {
+	case outputJSON:
+		return writeJSON(out, counts)
+	case outputCSV:
+		w := csv.NewWriter(out)
+		w.Write([]string{"name", "count"})
+		for _, name := range names {
+			w.Write([]string{name, strconv.Itoa(counts[name])})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCOUNT")
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+	}
+	return w.Flush()
+}
+
+func writeNames(out io.Writer, output string, names []string) error {
+	switch output {
+	case outputJSON:
+		return writeJSON(out, names)
+	case outputCSV:
+		w := csv.NewWriter(out)
+		w.Write([]string{"name"})
+		for _, name := range names {
+			w.Write([]string{name})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME")
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
+	return w.Flush()
+}
+
+func writeJSON(out io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
+
+// eventWriter writes a stream of events. The JSON output format writes one
+// object per line, so that events can be written as they are exported.
+type eventWriter struct {
+	output string
+	out    *bufio.Writer
+	csv    *csv.Writer
+	table  *tabwriter.Writer
+	header bool
+}
+
+func newEventWriter(out io.Writer, output string) *eventWriter {
+	w := &eventWriter{output: output, out: bufio.NewWriter(out)}
+	switch output {
+	case outputCSV:
+		w.csv = csv.NewWriter(w.out)
+	case outputTable:
+		w.table = tabwriter.NewWriter(w.out, 0, 4, 2, ' ', 0)
+	}
+	return w
+}
+
+func (w *eventWriter) Write(event client.Event) error {
+	record := eventRecord{Name: event.Name, Timestamp: formatTime(event.Time)}
+
+	switch w.output {
+	case outputJSON:
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w.out, "%s\n", data)
+		return err
+	case outputCSV:
+		if !w.header {
+			w.csv.Write([]string{"name", "timestamp"})
+			w.header = true
+		}
+		w.csv.Write([]string{record.Name, record.Timestamp})
+		return w.csv.Error()
+	}
+
+	// a table is aligned as a whole, so is only written once complete
+	if !w.header {
+		fmt.Fprintln(w.table, "NAME\tTIMESTAMP")
+		w.header = true
+	}
+	_, err := fmt.Fprintf(w.table, "%s\t%s\n", record.Name, record.Timestamp)
+	return err
+}
+
+func (w *eventWriter) Flush() error {
+	switch w.output {
+	case outputCSV:
+		if !w.header {
+			w.csv.Write([]string{"name", "timestamp"})
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	case outputTable:
+		if !w.header {
+			fmt.Fprintln(w.table, "NAME\tTIMESTAMP")
+		}
+		if err := w.table.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// eventReader reads a stream of events, returning io.EOF after the last.
+type eventReader interface {
+	Read() (client.Event, error)
+}
+
+func newEventReader(format string, in io.Reader) (eventReader, error) {
+	switch format {
+	case formatNDJSON:
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &ndjsonReader{scanner: scanner}, nil
+	case formatCSV:
+		return newCSVReader(in)
+	}
+	return nil, fmt.Errorf("unknown input format %q, expected ndjson or csv", format)
+}
+
+// ndjsonReader reads events from newline-delimited JSON objects with name
+// and timestamp fields. Blank lines are skipped.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Read() (client.Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record eventRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return client.Event{}, fmt.Errorf("line %d: %s", r.line, err)
+		}
+		return parseRecord(record, r.line)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return client.Event{}, err
+	}
+	return client.Event{}, io.EOF
+}
+
+// csvReader reads events from CSV with a header row naming its name and
+// timestamp columns.
+type csvReader struct {
+	reader    *csv.Reader
+	name      int
+	timestamp int
+	line      int
+}
+
+func newCSVReader(in io.Reader) (*csvReader, error) {
+	r := &csvReader{reader: csv.NewReader(in), name: -1, timestamp: -1, line: 1}
+	r.reader.FieldsPerRecord = -1
+
+	header, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv input is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "name":
+			r.name = i
+		case "timestamp":
+			r.timestamp = i
+		}
+	}
+	if r.name < 0 || r.timestamp < 0 {
+		return nil, fmt.Errorf("csv header must include name and timestamp columns")
+	}
+	return r, nil
+}
+
+func (r *csvReader) Read() (client.Event, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		return client.Event{}, err
+	}
+	r.line++
+
+	if r.name >= len(row) || r.timestamp >= len(row) {
+		return client.Event{}, fmt.Errorf("line %d: expected name and timestamp columns", r.line)
+	}
+	return parseRecord(eventRecord{Name: row[r.name], Timestamp: row[r.timestamp]}, r.line)
+}
+
+func parseRecord(record eventRecord, line int) (client.Event, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(record.Timestamp))
+	if err != nil {
+		return client.Event{}, fmt.Errorf("line %d: timestamp %q is not ISO8601", line, record.Timestamp)
+	}
+	return client.Event{Name: record.Name, Time: t}, nil
+}
@@ -0,0 +1,301 @@
+// Command eventsctl sends, counts, imports and exports events, either through
+// a running events service or directly against an event store.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/declantraynor/go-events-service/client"
+)
+
+const usage = `usage: eventsctl <command> [flags] [arguments]
+
+commands:
+  send [-at TIME] NAME          record an event, at the current time by default
+  count -from TIME -to TIME     count events of each name in a time range
+  names                         list the names of recorded events
+  import [-format F] [FILE]     record events read from FILE, or stdin, as ndjson or csv
+  export -from TIME -to TIME    write events in a time range, optionally only those
+         [-name NAME]           with a given name
+  build-rollups -store redis    build the rollups of names stored before rollups existed
+
+Times are ISO8601 (RFC3339) timestamps. Run "eventsctl <command> -h" for the flags
+common to every command, which select the server or store and the output format.
+`
+
+// errUsage is returned when a command is invoked incorrectly, after its
+// usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+// command runs a subcommand with its flags already parsed.
+type command func(inv *invocation, args []string) error
+
+var commands = map[string]struct {
+	flags func(fs *flag.FlagSet, opts *options)
+	run   command
+}{
+	"send":   {sendFlags, send},
+	"count":  {rangeFlags, count},
+	"names":  {noFlags, names},
+	"import": {importFlags, importEvents},
+	"export": {exportFlags, export},
+
+	"build-rollups": {noFlags, buildRollups},
+}
+
+// options holds the flags specific to each command.
+type options struct {
+	at     string
+	from   string
+	to     string
+	name   string
+	format string
+}
+
+// invocation holds everything a command needs to run.
+type invocation struct {
+	config  config
+	options options
+	backend backend
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func noFlags(fs *flag.FlagSet, opts *options) {}
+
+func rangeFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.from, "from", "", "start of the time range")
+	fs.StringVar(&opts.to, "to", "", "end of the time range")
+}
+
+func sendFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.at, "at", "", "time at which the event occurred (default now)")
+}
+
+func importFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.format, "format", "", "input format, ndjson or csv (default from the file extension, else ndjson)")
+}
+
+func exportFlags(fs *flag.FlagSet, opts *options) {
+	rangeFlags(fs, opts)
+	fs.StringVar(&opts.name, "name", "", "only export events with this name")
+}
+
+// run executes the command line `args`, returning the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "eventsctl: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	inv := &invocation{config: defaultConfig(os.Getenv), stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("eventsctl "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	inv.config.register(fs)
+	cmd.flags(fs, &inv.options)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if !validOutputs[inv.config.output] {
+		fmt.Fprintf(stderr, "eventsctl: unknown output format %q\n", inv.config.output)
+		return 2
+	}
+
+	b, err := openBackend(inv.config)
+	if err != nil {
+		fmt.Fprintf(stderr, "eventsctl: %s\n", err)
+		return 1
+	}
+	inv.backend = b
+
+	if err := cmd.run(inv, fs.Args()); err != nil {
+		if err == errUsage {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "eventsctl: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseTime parses a timestamp given on the command line. Unlike the
+// service, any time zone offset is accepted.
+func parseTime(flagName, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("-%s is required", flagName)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s %q is not an ISO8601 timestamp", flagName, value)
+	}
+	return t, nil
+}
+
+func parseRange(opts options) (time.Time, time.Time, error) {
+	from, err := parseTime("from", opts.from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseTime("to", opts.to)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, to, nil
+}
+
+func send(inv *invocation, args []string) error {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return errUsage
+	}
+
+	at := time.Now()
+	if inv.options.at != "" {
+		var err error
+		if at, err = parseTime("at", inv.options.at); err != nil {
+			return err
+		}
+	}
+
+	return inv.backend.Send(args[0], at)
+}
+
+func count(inv *invocation, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	from, to, err := parseRange(inv.options)
+	if err != nil {
+		return err
+	}
+
+	counts, err := inv.backend.Count(from, to)
+	if err != nil {
+		return err
+	}
+	return writeCounts(inv.stdout, inv.config.output, counts)
+}
+
+func names(inv *invocation, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	names, err := inv.backend.Names()
+	if err != nil {
+		return err
+	}
+	return writeNames(inv.stdout, inv.config.output, names)
+}
+
+func importEvents(inv *invocation, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	input, path := inv.stdin, "-"
+	if len(args) == 1 && args[0] != "-" {
+		path = args[0]
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	format := inv.options.format
+	if format == "" {
+		format = formatNDJSON
+		if strings.HasSuffix(strings.ToLower(path), ".csv") {
+			format = formatCSV
+		}
+	}
+	reader, err := newEventReader(format, input)
+	if err != nil {
+		return err
+	}
+
+	imp := inv.backend.Importer(func(event client.Event, err error) {
+		fmt.Fprintf(inv.stderr, "eventsctl: failed to import %s at %s: %s\n", event.Name, formatTime(event.Time), err)
+	})
+
+	var readErr error
+	for {
+		event, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		if err := imp.Add(event); err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	stats, err := imp.Close()
+	fmt.Fprintf(inv.stderr, "imported %d events, %d failed\n", stats.Sent, stats.Failed)
+	if readErr != nil {
+		return readErr
+	}
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d events could not be imported", stats.Failed)
+	}
+	return nil
+}
+
+func export(inv *invocation, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	from, to, err := parseRange(inv.options)
+	if err != nil {
+		return err
+	}
+
+	writer := newEventWriter(inv.stdout, inv.config.output)
+	if err := inv.backend.Export(from, to, inv.options.name, writer.Write); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func buildRollups(inv *invocation, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	built, err := inv.backend.BuildRollups()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(inv.stdout, "built the rollups of %d names\n", built)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
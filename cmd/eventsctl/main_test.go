@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/interfaces/web"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// useStore makes the -store flag open `store`, for the duration of a test.
+func useStore(t *testing.T, store domain.EventStore) {
+	original := openStore
+	openStore = func(c config) (domain.EventStore, error) { return store, nil }
+	t.Cleanup(func() { openStore = original })
+}
+
+// startServer runs the real WebService against `store`, without
+// authentication, returning its URL.
+func startServer(t *testing.T, store domain.EventStore) string {
+	service := web.WebService{EventInteractor: &usecases.EventInteractor{Store: store}}
+	server := httptest.NewServer(service.Handler())
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func eventsctl(t *testing.T, stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// backends returns the flags selecting each backend, both operating on
+// `store`.
+func backends(t *testing.T, store domain.EventStore) map[string][]string {
+	useStore(t, store)
+	return map[string][]string{
+		"remote": {"-server", startServer(t, store)},
+		"store":  {"-store", "memory"},
+	}
+}
+
+func TestSendAndCount(t *testing.T) {
+	for name, flags := range backends(t, storetest.NewEventStore()) {
+		t.Run(name, func(t *testing.T) {
+			tenant := append([]string{"-tenant", name}, flags...)
+
+			for _, at := range []string{"2015-02-11T15:01:00Z", "2015-02-11T10:01:30-05:00"} {
+				args := append([]string{"send", "-at", at}, tenant...)
+				if code, _, stderr := eventsctl(t, "", append(args, "signup")...); code != 0 {
+					t.Fatalf("send failed: %s", stderr)
+				}
+			}
+
+			args := append([]string{"count", "-from", "2015-02-11T15:00:00Z", "-to", "2015-02-11T16:00:00Z", "-output", "csv"}, tenant...)
+			code, stdout, stderr := eventsctl(t, "", args...)
+			if code != 0 {
+				t.Fatalf("count failed: %s", stderr)
+			}
+			if expected := "name,count\nsignup,2\n"; stdout != expected {
+				t.Errorf("expected output %q, got %q", expected, stdout)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	store := storetest.NewEventStore()
+	for _, name := range []string{"b", "a", "b"} {
+		store.ForTenant(usecases.DefaultTenant).Put(domain.Event{Name: name, Timestamp: 1423666860})
+	}
+
+	for name, flags := range backends(t, store) {
+		t.Run(name, func(t *testing.T) {
+			code, stdout, stderr := eventsctl(t, "", append([]string{"names", "-output", "json"}, flags...)...)
+			if code != 0 {
+				t.Fatalf("names failed: %s", stderr)
+			}
+			if expected := "[\n    \"a\",\n    \"b\"\n]\n"; stdout != expected {
+				t.Errorf("expected output %q, got %q", expected, stdout)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	inputs := map[string]string{
+		"ndjson": "{\"name\": \"a\", \"timestamp\": \"2015-02-11T15:01:00Z\"}\n\n{\"name\": \"b\", \"timestamp\": \"2015-02-11T15:02:00Z\"}\n",
+		"csv":    "timestamp,name\n2015-02-11T15:01:00Z,a\n2015-02-11T15:02:00Z,b\n",
+	}
+
+	for format, input := range inputs {
+		for _, name := range []string{"remote", "store"} {
+			t.Run(format+"/"+name, func(t *testing.T) {
+				flags := backends(t, storetest.NewEventStore())[name]
+				args := append([]string{"import", "-format", format}, flags...)
+				if code, _, stderr := eventsctl(t, input, args...); code != 0 {
+					t.Fatalf("import failed: %s", stderr)
+				}
+
+				args = append([]string{"count", "-from", "2015-02-11T15:00:00Z", "-to", "2015-02-11T16:00:00Z"}, flags...)
+				_, stdout, _ := eventsctl(t, "", args...)
+				if expected := "NAME  COUNT\na     1\nb     1\n"; stdout != expected {
+					t.Errorf("expected output %q, got %q", expected, stdout)
+				}
+			})
+		}
+	}
+}
+
+func TestImportFromFile(t *testing.T) {
+	store := storetest.NewEventStore()
+	useStore(t, store)
+
+	dir, _ := ioutil.TempDir("", "eventsctl")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.csv")
+	ioutil.WriteFile(path, []byte("name,timestamp\na,2015-02-11T15:01:00Z\n"), 0644)
+
+	if code, _, stderr := eventsctl(t, "", "import", "-store", "memory", path); code != 0 {
+		t.Fatalf("import failed: %s", stderr)
+	}
+	if names, _ := store.ForTenant(usecases.DefaultTenant).Names(); len(names) != 1 {
+		t.Errorf("expected event to be imported as csv, got names %v", names)
+	}
+}
+
+func TestImportRejectsMalformedInput(t *testing.T) {
+	useStore(t, storetest.NewEventStore())
+
+	code, _, stderr := eventsctl(t, "{\"name\": \"a\", \"timestamp\": \"yesterday\"}\n", "import", "-store", "memory")
+	if code != 1 || !strings.Contains(stderr, "line 1") {
+		t.Errorf("expected failure naming line 1, got %d: %s", code, stderr)
+	}
+}
+
+func TestExport(t *testing.T) {
+	store := storetest.NewEventStore()
+	for _, event := range []domain.Event{
+		{Name: "b", Timestamp: 1423666860},
+		{Name: "a", Timestamp: 1423666920},
+		{Name: "a", Timestamp: 1423666861},
+		{Name: "a", Timestamp: 1423670000},
+	} {
+		store.ForTenant(usecases.DefaultTenant).Put(event)
+	}
+
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			[]string{"-output", "json"},
+			"{\"name\":\"a\",\"timestamp\":\"2015-02-11T15:01:01Z\"}\n{\"name\":\"a\",\"timestamp\":\"2015-02-11T15:02:00Z\"}\n{\"name\":\"b\",\"timestamp\":\"2015-02-11T15:01:00Z\"}\n",
+		},
+		{
+			[]string{"-output", "csv", "-name", "b"},
+			"name,timestamp\nb,2015-02-11T15:01:00Z\n",
+		},
+		{
+			[]string{"-name", "b"},
+			"NAME  TIMESTAMP\nb     2015-02-11T15:01:00Z\n",
+		},
+	}
+
+	for name, flags := range backends(t, store) {
+		for _, c := range cases {
+			args := append([]string{"export", "-from", "2015-02-11T15:00:00Z", "-to", "2015-02-11T15:30:00Z"}, flags...)
+			code, stdout, stderr := eventsctl(t, "", append(args, c.args...)...)
+			if code != 0 {
+				t.Fatalf("%s export failed: %s", name, stderr)
+			}
+			if stdout != c.expected {
+				t.Errorf("expected %s output %q, got %q", name, c.expected, stdout)
+			}
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"unknown"},
+		{"send"},
+		{"count", "-output", "xml"},
+	}
+
+	for _, args := range cases {
+		if code, _, _ := eventsctl(t, "", args...); code != 2 {
+			t.Errorf("expected exit code 2 for %v, got %d", args, code)
+		}
+	}
+}
+
+func TestBuildRollups(t *testing.T) {
+	flags := backends(t, storetest.NewEventStore())
+
+	// the in-memory store has no rollups to build
+	code, stdout, stderr := eventsctl(t, "", append([]string{"build-rollups"}, flags["store"]...)...)
+	if code != 0 || stdout != "built the rollups of 0 names\n" {
+		t.Errorf("unexpected result %d, %q, %q", code, stdout, stderr)
+	}
+
+	if code, _, _ := eventsctl(t, "", append([]string{"build-rollups"}, flags["remote"]...)...); code != 1 {
+		t.Errorf("expected building rollups through the service to fail, got exit code %d", code)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	useStore(t, storetest.NewEventStore())
+
+	cases := [][]string{
+		{"count", "-store", "memory", "-from", "2015-02-11T15:00:00Z"},
+		{"count", "-store", "memory", "-from", "2015-02-11T16:00:00Z", "-to", "2015-02-11T15:00:00Z"},
+		{"send", "-store", "memory", "-at", "yesterday", "signup"},
+		{"send", "-store", "memory", "-tenant", "not a tenant", "signup"},
+	}
+
+	for _, args := range cases {
+		if code, _, _ := eventsctl(t, "", args...); code != 1 {
+			t.Errorf("expected exit code 1 for %v, got %d", args, code)
+		}
+	}
+}
@@ -1,13 +1,169 @@
 // Package domain defines the primitive entities present in the events service.
 package domain
 
+import "context"
+
 type EventStore interface {
 	CountInTimeRange(name string, start, end int64) (int, error)
 	Names() ([]string, error)
 	Put(event Event) error
+
+	// ForTenant returns an EventStore whose data is kept separate from that
+	// of every other tenant.
+	ForTenant(tenant string) EventStore
+}
+
+// EventCounter is implemented by EventStores which can count the events of
+// every name at once, more cheaply than counting each name in turn.
+type EventCounter interface {
+	// CountAllInTimeRange returns the number of events of each name with a
+	// timestamp between `start` and `end`, omitting names with none.
+	CountAllInTimeRange(start, end int64) (map[string]int, error)
+}
+
+// BatchCounter is implemented by EventStores which can count the events of
+// one name in many time ranges at once, more cheaply than counting each
+// range in turn.
+type BatchCounter interface {
+	// CountInTimeRanges returns the number of events with a given name and
+	// a timestamp in each of `ranges`, which hold inclusive start and end
+	// timestamps.
+	CountInTimeRanges(name string, ranges [][2]int64) ([]int, error)
+}
+
+// NameNormalizer is implemented by EventStores which store names in a
+// normal form, so that several names given to them refer to the same events.
+type NameNormalizer interface {
+	// NormalizeName returns the form in which `name` is stored.
+	NormalizeName(name string) string
+}
+
+// EventScanner is implemented by EventStores which can enumerate the events
+// they hold, as well as count them.
+type EventScanner interface {
+	// ScanInTimeRange calls `fn` with each event with a given name and
+	// timestamp between `start` and `end`, in timestamp order, stopping at
+	// the first error returned by `fn`.
+	ScanInTimeRange(name string, start, end int64, fn func(Event) error) error
+}
+
+// UniqueCounter is implemented by EventStores which can estimate how many
+// distinct actors triggered events.
+type UniqueCounter interface {
+	// CountUniqueInTimeRange returns an estimate of the number of distinct
+	// actors of the events with a given name and timestamp between `start`
+	// and `end`. Events without an actor are not counted.
+	CountUniqueInTimeRange(name string, start, end int64) (int, error)
+}
+
+// ValueSummarizer is implemented by EventStores which can summarise the
+// values carried by events.
+type ValueSummarizer interface {
+	// SummarizeInTimeRange returns a Sketch of the values of the events with
+	// a given name and timestamp between `start` and `end`. Events without a
+	// value are omitted.
+	SummarizeInTimeRange(name string, start, end int64) (*Sketch, error)
+}
+
+// EventSubscriber is implemented by EventStores which can notify
+// subscribers of events as they are stored, including events stored through
+// other instances of the service.
+type EventSubscriber interface {
+	// Subscribe returns a Subscription to events stored from now on. If
+	// `afterID` is non-zero, events already stored with a greater ID are
+	// delivered first, so that a subscriber can resume where it left off.
+	Subscribe(afterID int64) (Subscription, error)
+}
+
+// Subscription delivers stored events in the order they are stored.
+type Subscription interface {
+	// Next waits for the next event, or until `ctx` is done. Once Next has
+	// returned an error other than that of `ctx`, the subscription is broken
+	// and must be closed.
+	Next(ctx context.Context) (StoredEvent, error)
+	Close() error
 }
 
+// Event records an occurrence of Name at Timestamp, optionally identifying
+// the Actor, such as a user, which triggered it, and carrying a measured
+// Value, such as a duration or an amount.
 type Event struct {
 	Name      string
 	Timestamp int64
+	Actor     string
+	Value     *float64
+}
+
+// StoredEvent is an event along with the ID assigned to it when it was
+// stored. IDs increase in the order events are stored for each tenant.
+type StoredEvent struct {
+	ID int64
+	Event
+}
+
+// APIKeyStore persists API keys, which are identified by a hash of the
+// secret key presented by clients rather than by the key itself.
+type APIKeyStore interface {
+	Get(hash string) (APIKey, error)
+	Put(key APIKey) error
+}
+
+// APIKey is bound to a single tenant, unless its Tenant is empty, in which
+// case it may be used with any tenant. Zero rate limits defer to the limits
+// configured for the service as a whole.
+type APIKey struct {
+	Hash       string
+	Scopes     []string
+	Tenant     string
+	ReadLimit  RateLimit
+	WriteLimit RateLimit
+}
+
+// RateLimit describes a token bucket which holds up to Burst tokens and is
+// refilled at Rate tokens per second. Each request consumes one token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// IsZero reports whether the limit is unset.
+func (limit RateLimit) IsZero() bool {
+	return limit.Rate <= 0 || limit.Burst <= 0
+}
+
+// AlertRuleStore persists alert rules, each of which belongs to a single
+// tenant. Get, Update, SetFiring and Delete return ErrNotFound for unknown
+// rules.
+type AlertRuleStore interface {
+	Get(tenant, id string) (AlertRule, error)
+	List(tenant string) ([]AlertRule, error)
+
+	// All returns the rules of every tenant.
+	All() ([]AlertRule, error)
+	Put(rule AlertRule) error
+
+	// Update sets the event name, threshold, window and webhook URL of an
+	// existing rule, returning the rule as stored, in a single step which
+	// cannot bring back a rule deleted meanwhile.
+	Update(rule AlertRule) (AlertRule, error)
+	Delete(tenant, id string) error
+
+	// SetFiring records whether a rule is firing, reporting whether this
+	// changed it. Of several callers making the same change at once, only
+	// one sees it as changed.
+	SetFiring(tenant, id string, firing bool) (bool, error)
+}
+
+// AlertRule fires while more than Threshold events named EventName were
+// stored in the last Window seconds. Changes in whether it is firing are
+// posted to WebhookURL, signed with Secret.
+type AlertRule struct {
+	ID         string
+	Tenant     string
+	EventName  string
+	Threshold  int
+	Window     int64
+	WebhookURL string
+	Secret     string
+	Firing     bool
 }
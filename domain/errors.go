@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of failure which a store may report. Errors returned by stores wrap
+// one of these, so callers can test for them with errors.Is.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflicting update")
+	ErrUnavailable = errors.New("store unavailable")
+	ErrTimeout     = errors.New("store timed out")
+)
+
+// StoreError records a failed store operation, classified as one of the
+// kinds above, along with the underlying cause.
+type StoreError struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (err *StoreError) Error() string {
+	return fmt.Sprintf("error %s: %v", err.Op, err.Err)
+}
+
+// Is reports whether `target` is the kind of failure `err` was classified as.
+func (err *StoreError) Is(target error) bool {
+	return err.Kind != nil && target == err.Kind
+}
+
+func (err *StoreError) Unwrap() error {
+	return err.Err
+}
+
+// ValidationError describes a value which the service will not accept, and
+// names the field which held it.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (err ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", err.Field, err.Reason)
+}
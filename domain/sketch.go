@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"math"
+	"sort"
+)
+
+// SketchAccuracy is the relative accuracy of the quantiles estimated by a
+// Sketch: an estimate of a quantile whose true value is v lies within
+// SketchAccuracy*|v| of v.
+const SketchAccuracy = 0.01
+
+// sketchGamma is the ratio between the bounds of each bin of a Sketch.
+var sketchGamma = (1 + SketchAccuracy) / (1 - SketchAccuracy)
+
+// sketchMinValue is the smallest magnitude distinguished from zero.
+const sketchMinValue = 1e-9
+
+// Sketch summarises a set of values: their number, sum, minimum and maximum
+// exactly, and their distribution approximately, by counting the values in
+// bins whose bounds grow geometrically. Bin i of Positive counts values in
+// (gamma^(i-1), gamma^i], and of Negative the values whose magnitudes are.
+// Sketches of disjoint sets of values merge into a sketch of their union
+// with no loss of accuracy, so that sketches of short periods can be
+// combined into sketches of longer ones.
+type Sketch struct {
+	Count    int64
+	Sum      float64
+	Min, Max float64
+	Positive map[int]int64
+	Negative map[int]int64
+	Zero     int64
+}
+
+// NewSketch returns an empty Sketch.
+func NewSketch() *Sketch {
+	return &Sketch{Positive: map[int]int64{}, Negative: map[int]int64{}}
+}
+
+// SketchBin returns the bin which counts `value` in a Sketch: an index into
+// Positive if sign is 1, or Negative if it is -1, or the Zero bin if sign
+// is 0.
+func SketchBin(value float64) (sign int, index int) {
+	switch {
+	case value >= sketchMinValue:
+		return 1, sketchIndex(value)
+	case value <= -sketchMinValue:
+		return -1, sketchIndex(-value)
+	}
+	return 0, 0
+}
+
+func sketchIndex(magnitude float64) int {
+	return int(math.Ceil(math.Log(magnitude) / math.Log(sketchGamma)))
+}
+
+// sketchValue returns the value which represents bin `index`, the one with
+// the least relative error from every value in the bin.
+func sketchValue(index int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(index)) / (sketchGamma + 1)
+}
+
+// Add adds a value to the sketch.
+func (sketch *Sketch) Add(value float64) {
+	sketch.AddStats(1, value, value, value)
+	sketch.AddBin(value, 1)
+}
+
+// AddStats adds the count, sum, minimum and maximum of some values to those
+// of the sketch, without counting them in its bins.
+func (sketch *Sketch) AddStats(count int64, sum, min, max float64) {
+	if count == 0 {
+		return
+	}
+	if sketch.Count == 0 || min < sketch.Min {
+		sketch.Min = min
+	}
+	if sketch.Count == 0 || max > sketch.Max {
+		sketch.Max = max
+	}
+	sketch.Count += count
+	sketch.Sum += sum
+}
+
+// AddBin counts `n` more values in the bin which counts `value`, without
+// changing the count, sum, minimum or maximum of the sketch.
+func (sketch *Sketch) AddBin(value float64, n int64) {
+	switch sign, index := SketchBin(value); sign {
+	case 1:
+		sketch.Positive[index] += n
+	case -1:
+		sketch.Negative[index] += n
+	default:
+		sketch.Zero += n
+	}
+}
+
+// Merge adds every value summarised by `other` to the sketch.
+func (sketch *Sketch) Merge(other *Sketch) {
+	sketch.AddStats(other.Count, other.Sum, other.Min, other.Max)
+	for index, n := range other.Positive {
+		sketch.Positive[index] += n
+	}
+	for index, n := range other.Negative {
+		sketch.Negative[index] += n
+	}
+	sketch.Zero += other.Zero
+}
+
+// Mean returns the mean of the values, or zero if there are none.
+func (sketch *Sketch) Mean() float64 {
+	if sketch.Count == 0 {
+		return 0
+	}
+	return sketch.Sum / float64(sketch.Count)
+}
+
+// Quantile estimates the value below which a fraction `q` of the values
+// lie, or returns zero if there are none. Estimates are always between the
+// minimum and maximum.
+func (sketch *Sketch) Quantile(q float64) float64 {
+	binned := sketch.Zero
+	for _, n := range sketch.Positive {
+		binned += n
+	}
+	for _, n := range sketch.Negative {
+		binned += n
+	}
+	if binned == 0 {
+		return 0
+	}
+
+	// the rank of the quantile, counting from zero, among the binned values
+	rank := int64(q * float64(binned-1))
+
+	seen := int64(0)
+	for _, index := range sortedIndexes(sketch.Negative, true) {
+		if seen += sketch.Negative[index]; seen > rank {
+			return sketch.clamp(-sketchValue(index))
+		}
+	}
+	if seen += sketch.Zero; seen > rank {
+		return sketch.clamp(0)
+	}
+	for _, index := range sortedIndexes(sketch.Positive, false) {
+		if seen += sketch.Positive[index]; seen > rank {
+			return sketch.clamp(sketchValue(index))
+		}
+	}
+	return sketch.Max
+}
+
+func (sketch *Sketch) clamp(value float64) float64 {
+	if sketch.Count == 0 {
+		return value
+	}
+	return math.Max(sketch.Min, math.Min(sketch.Max, value))
+}
+
+// sortedIndexes returns the indexes of `bins` in ascending order, or
+// descending if `reverse` is set.
+func sortedIndexes(bins map[int]int64, reverse bool) []int {
+	indexes := make([]int, 0, len(bins))
+	for index := range bins {
+		indexes = append(indexes, index)
+	}
+	if reverse {
+		sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	} else {
+		sort.Ints(indexes)
+	}
+	return indexes
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketchStats(t *testing.T) {
+	sketch := NewSketch()
+	for _, value := range []float64{3, -1.5, 0, 12} {
+		sketch.Add(value)
+	}
+
+	if sketch.Count != 4 || sketch.Sum != 13.5 || sketch.Min != -1.5 || sketch.Max != 12 {
+		t.Errorf("unexpected stats %+v", sketch)
+	}
+	if mean := sketch.Mean(); mean != 3.375 {
+		t.Errorf("expected mean 3.375, got %v", mean)
+	}
+}
+
+func TestSketchQuantilesAreAccurate(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	sketch := NewSketch()
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = random.ExpFloat64()*250 - 20
+		sketch.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0, 0.5, 0.95, 0.99, 1} {
+		expected := values[int(q*float64(len(values)-1))]
+		if estimate := sketch.Quantile(q); math.Abs(estimate-expected) > SketchAccuracy*math.Abs(expected) {
+			t.Errorf("expected quantile %v within %v of %v, got %v", q, SketchAccuracy, expected, estimate)
+		}
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	first, second, both := NewSketch(), NewSketch(), NewSketch()
+	for i := 1; i <= 100; i++ {
+		value, part := float64(i*i), first
+		if i%3 != 0 {
+			value, part = -value, second
+		}
+		part.Add(value)
+		both.Add(value)
+	}
+
+	merged := NewSketch()
+	merged.Merge(first)
+	merged.Merge(second)
+
+	if merged.Count != both.Count || merged.Sum != both.Sum || merged.Min != both.Min || merged.Max != both.Max {
+		t.Errorf("expected stats of %+v, got %+v", both, merged)
+	}
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		if merged.Quantile(q) != both.Quantile(q) {
+			t.Errorf("expected quantile %v of %v, got %v", q, both.Quantile(q), merged.Quantile(q))
+		}
+	}
+}
+
+func TestEmptySketch(t *testing.T) {
+	sketch := NewSketch()
+	if sketch.Mean() != 0 || sketch.Quantile(0.5) != 0 {
+		t.Errorf("expected zero mean and quantiles, got %v and %v", sketch.Mean(), sketch.Quantile(0.5))
+	}
+}
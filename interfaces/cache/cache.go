@@ -0,0 +1,195 @@
+// Package cache caches the results of counting events over time ranges
+// which have already closed.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entryKey identifies a count of the events of one name, or of every name
+// if `all` is set, over a time range.
+type entryKey struct {
+	tenant     string
+	name       string
+	all        bool
+	start, end int64
+}
+
+type entry struct {
+	key     entryKey
+	count   int
+	counts  map[string]int
+	expires time.Time
+
+	// ready is set once the count has been stored, and stale if an event
+	// was stored in the range while it was being counted
+	ready bool
+	stale bool
+}
+
+// Cache holds counts for the EventStores of every tenant, discarding the
+// least recently used once it holds MaxEntries. It is safe for concurrent
+// use.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[entryKey]*list.Element
+	lru     *list.List
+
+	// byName indexes entries by tenant and name, with counts of every name
+	// under the empty name, so that storing an event need only visit the
+	// entries it may affect
+	byName map[string]map[string]map[*list.Element]bool
+
+	hits, misses, invalidations, evictions int64
+}
+
+// New returns a Cache which keeps counts for `ttl` and holds at most
+// `maxEntries` of them.
+func New(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[entryKey]*list.Element{},
+		lru:        list.New(),
+		byName:     map[string]map[string]map[*list.Element]bool{},
+	}
+}
+
+// Metrics reports the number of lookups which were answered from the cache
+// and which were not, the number of entries invalidated by events stored in
+// their range and evicted to make room for others, and the number held.
+func (cache *Cache) Metrics() map[string]int64 {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return map[string]int64{
+		"count_cache.hits":          cache.hits,
+		"count_cache.misses":        cache.misses,
+		"count_cache.invalidations": cache.invalidations,
+		"count_cache.evictions":     cache.evictions,
+		"count_cache.entries":       int64(cache.lru.Len()),
+	}
+}
+
+// lookup returns the entry for `key` if it holds a count. Otherwise, if the
+// range has closed and no other caller is counting it, it returns a new
+// pending entry for the caller to fill once it has counted the range.
+func (cache *Cache) lookup(key entryKey) (e *entry, hit bool) {
+	now := cache.now()
+	if key.end >= now.Unix() {
+		return nil, false
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		e := element.Value.(*entry)
+		if e.ready && now.Before(e.expires) {
+			cache.hits++
+			cache.lru.MoveToFront(element)
+			return e, true
+		}
+		cache.misses++
+		if !e.ready {
+			// another caller is counting the range
+			return nil, false
+		}
+		cache.remove(element)
+	} else {
+		cache.misses++
+	}
+
+	e = &entry{key: key}
+	element := cache.lru.PushFront(e)
+	cache.entries[key] = element
+	cache.index(key.tenant, key.indexName())[element] = true
+
+	for cache.maxEntries > 0 && cache.lru.Len() > cache.maxEntries {
+		cache.evictions++
+		cache.remove(cache.lru.Back())
+	}
+	return e, false
+}
+
+// fill stores a count in a pending entry, unless an event has since been
+// stored in its range.
+func (cache *Cache) fill(e *entry, count int, counts map[string]int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if e.stale {
+		return
+	}
+	e.count, e.counts = count, counts
+	e.expires = cache.now().Add(cache.ttl)
+	e.ready = true
+}
+
+// abandon discards a pending entry whose range could not be counted.
+func (cache *Cache) abandon(e *entry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[e.key]; ok && element.Value == e {
+		cache.remove(element)
+	}
+}
+
+// invalidate discards every count of `tenant` which includes an event with
+// the given name and timestamp.
+func (cache *Cache) invalidate(tenant, name string, timestamp int64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, indexName := range []string{name, ""} {
+		for element := range cache.byName[tenant][indexName] {
+			e := element.Value.(*entry)
+			if e.key.start <= timestamp && timestamp <= e.key.end {
+				cache.invalidations++
+				e.stale = true
+				cache.remove(element)
+			}
+		}
+	}
+}
+
+func (cache *Cache) index(tenant, name string) map[*list.Element]bool {
+	if cache.byName[tenant] == nil {
+		cache.byName[tenant] = map[string]map[*list.Element]bool{}
+	}
+	if cache.byName[tenant][name] == nil {
+		cache.byName[tenant][name] = map[*list.Element]bool{}
+	}
+	return cache.byName[tenant][name]
+}
+
+func (cache *Cache) remove(element *list.Element) {
+	key := element.Value.(*entry).key
+	cache.lru.Remove(element)
+	delete(cache.entries, key)
+
+	names := cache.byName[key.tenant]
+	delete(names[key.indexName()], element)
+	if len(names[key.indexName()]) == 0 {
+		delete(names, key.indexName())
+	}
+	if len(names) == 0 {
+		delete(cache.byName, key.tenant)
+	}
+}
+
+// indexName is the name under which an entry is indexed for invalidation.
+func (key entryKey) indexName() string {
+	if key.all {
+		return ""
+	}
+	return key.name
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// EventStore decorates a domain.EventStore, answering counts over time
+// ranges which ended in the past from a Cache. Counts are cached under names
+// as the decorated store normalizes them, if it implements
+// domain.NameNormalizer, so names it treats alike share their counts.
+// Storing an event discards the cached counts whose range includes it.
+// Events stored through other instances of the service are not seen, so
+// counts may be out of date for up to the cache's TTL if events arrive late.
+type EventStore struct {
+	store  domain.EventStore
+	cache  *Cache
+	tenant string
+}
+
+// NewEventStore returns an EventStore caching the counts of `store` in
+// `cache`.
+func NewEventStore(store domain.EventStore, cache *Cache) *EventStore {
+	return &EventStore{store: store, cache: cache}
+}
+
+func (store *EventStore) ForTenant(tenant string) domain.EventStore {
+	return &EventStore{store: store.store.ForTenant(tenant), cache: store.cache, tenant: tenant}
+}
+
+func (store *EventStore) CountInTimeRange(name string, start, end int64) (int, error) {
+	e, hit := store.cache.lookup(entryKey{tenant: store.tenant, name: store.normalize(name), start: start, end: end})
+	if hit {
+		return e.count, nil
+	}
+
+	count, err := store.store.CountInTimeRange(name, start, end)
+	if e != nil {
+		if err != nil {
+			store.cache.abandon(e)
+		} else {
+			store.cache.fill(e, count, nil)
+		}
+	}
+	return count, err
+}
+
+// CountAllInTimeRange returns the number of events of each name with a
+// timestamp between `start` and `end`, omitting names with none. Names are
+// counted in turn if the decorated store cannot count them all at once.
+func (store *EventStore) CountAllInTimeRange(start, end int64) (map[string]int, error) {
+	e, hit := store.cache.lookup(entryKey{tenant: store.tenant, all: true, start: start, end: end})
+	if hit {
+		return copyCounts(e.counts), nil
+	}
+
+	counts, err := store.countAll(start, end)
+	if e != nil {
+		if err != nil {
+			store.cache.abandon(e)
+		} else {
+			store.cache.fill(e, 0, copyCounts(counts))
+		}
+	}
+	return counts, err
+}
+
+func (store *EventStore) countAll(start, end int64) (map[string]int, error) {
+	if counter, ok := store.store.(domain.EventCounter); ok {
+		return counter.CountAllInTimeRange(start, end)
+	}
+
+	names, err := store.store.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, name := range names {
+		count, err := store.store.CountInTimeRange(name, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			counts[name] = count
+		}
+	}
+	return counts, nil
+}
+
+// normalize returns `name` as the decorated store normalizes it.
+func (store *EventStore) normalize(name string) string {
+	if normalizer, ok := store.store.(domain.NameNormalizer); ok {
+		return normalizer.NormalizeName(name)
+	}
+	return name
+}
+
+func (store *EventStore) Names() ([]string, error) {
+	return store.store.Names()
+}
+
+func (store *EventStore) Put(event domain.Event) error {
+	if err := store.store.Put(event); err != nil {
+		return err
+	}
+	store.cache.invalidate(store.tenant, store.normalize(event.Name), event.Timestamp)
+	return nil
+}
+
+func (store *EventStore) ScanInTimeRange(name string, start, end int64, fn func(domain.Event) error) error {
+	scanner, ok := store.store.(domain.EventScanner)
+	if !ok {
+		return usecases.UnsupportedError{Operation: "exporting events"}
+	}
+	return scanner.ScanInTimeRange(name, start, end, fn)
+}
+
+func (store *EventStore) CountUniqueInTimeRange(name string, start, end int64) (int, error) {
+	counter, ok := store.store.(domain.UniqueCounter)
+	if !ok {
+		return 0, usecases.UnsupportedError{Operation: "counting unique actors"}
+	}
+	return counter.CountUniqueInTimeRange(name, start, end)
+}
+
+func (store *EventStore) SummarizeInTimeRange(name string, start, end int64) (*domain.Sketch, error) {
+	summarizer, ok := store.store.(domain.ValueSummarizer)
+	if !ok {
+		return nil, usecases.UnsupportedError{Operation: "aggregating values"}
+	}
+	return summarizer.SummarizeInTimeRange(name, start, end)
+}
+
+func (store *EventStore) Subscribe(afterID int64) (domain.Subscription, error) {
+	subscriber, ok := store.store.(domain.EventSubscriber)
+	if !ok {
+		return nil, usecases.UnsupportedError{Operation: "streaming events"}
+	}
+	return subscriber.Subscribe(afterID)
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for name, count := range counts {
+		copied[name] = count
+	}
+	return copied
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// 2015-02-11T15:01:00Z and the range of the minute which follows it
+const (
+	minute    = int64(1423666860)
+	minuteEnd = minute + 59
+)
+
+func newTestStore(ttl time.Duration, maxEntries int) (*EventStore, *storetest.EventStore, *Cache, *time.Time) {
+	now := time.Unix(minute, 0).Add(time.Hour)
+	cache := New(ttl, maxEntries)
+	cache.now = func() time.Time { return now }
+
+	stub := storetest.NewEventStore()
+	return NewEventStore(stub, cache), stub, cache, &now
+}
+
+// tenant returns the store of `tenant` within `stub`.
+func tenant(stub *storetest.EventStore, tenant string) *storetest.EventStore {
+	return stub.ForTenant(tenant).(*storetest.EventStore)
+}
+
+func TestCountsOverClosedRangesAreCached(t *testing.T) {
+	store, stub, cache, _ := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+	acme.Put(domain.Event{Name: "test", Timestamp: minute})
+
+	for i := 0; i < 3; i++ {
+		if count, err := acme.CountInTimeRange("test", minute, minuteEnd); err != nil || count != 1 {
+			t.Fatalf("expected 1 event, got %d, %v", count, err)
+		}
+	}
+
+	if tenant(stub, "acme").Counted() != 1 {
+		t.Errorf("expected a single count of the store, got %d", tenant(stub, "acme").Counted())
+	}
+	metrics := cache.Metrics()
+	if metrics["count_cache.hits"] != 2 || metrics["count_cache.misses"] != 1 || metrics["count_cache.entries"] != 1 {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+}
+
+func TestCountsOverOpenRangesAreNotCached(t *testing.T) {
+	store, stub, cache, now := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+
+	acme.CountInTimeRange("test", minute, now.Unix())
+	acme.CountInTimeRange("test", minute, now.Unix())
+
+	if tenant(stub, "acme").Counted() != 2 {
+		t.Errorf("expected every count to reach the store, got %d", tenant(stub, "acme").Counted())
+	}
+	if metrics := cache.Metrics(); metrics["count_cache.misses"] != 0 || metrics["count_cache.entries"] != 0 {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+}
+
+func TestCachedCountsExpire(t *testing.T) {
+	store, stub, _, now := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+
+	acme.CountInTimeRange("test", minute, minuteEnd)
+	*now = now.Add(time.Minute)
+	acme.CountInTimeRange("test", minute, minuteEnd)
+
+	if tenant(stub, "acme").Counted() != 2 {
+		t.Errorf("expected the expired count to be refreshed, got %d counts", tenant(stub, "acme").Counted())
+	}
+}
+
+func TestPutInvalidatesAffectedCounts(t *testing.T) {
+	store, _, cache, _ := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+	globex := store.ForTenant("globex").(*EventStore)
+
+	acme.CountInTimeRange("test", minute, minuteEnd)
+	acme.CountInTimeRange("other", minute, minuteEnd)
+	acme.CountAllInTimeRange(minute, minuteEnd)
+	globex.CountInTimeRange("test", minute, minuteEnd)
+
+	// outside the cached range, then inside it
+	acme.Put(domain.Event{Name: "test", Timestamp: minuteEnd + 1})
+	if metrics := cache.Metrics(); metrics["count_cache.invalidations"] != 0 {
+		t.Fatalf("expected no invalidations, got %v", metrics)
+	}
+	acme.Put(domain.Event{Name: "test", Timestamp: minuteEnd})
+
+	if count, _ := acme.CountInTimeRange("test", minute, minuteEnd); count != 1 {
+		t.Errorf("expected the late event to be counted, got %d", count)
+	}
+	if counts, _ := acme.CountAllInTimeRange(minute, minuteEnd); !reflect.DeepEqual(counts, map[string]int{"test": 1}) {
+		t.Errorf("expected the late event to be counted, got %v", counts)
+	}
+
+	metrics := cache.Metrics()
+	if metrics["count_cache.invalidations"] != 2 || metrics["count_cache.hits"] != 0 {
+		t.Errorf("expected only the counts of test for acme to be invalidated, got %v", metrics)
+	}
+	acme.CountInTimeRange("other", minute, minuteEnd)
+	globex.CountInTimeRange("test", minute, minuteEnd)
+	if metrics := cache.Metrics(); metrics["count_cache.hits"] != 2 {
+		t.Errorf("expected unaffected counts to remain cached, got %v", metrics)
+	}
+}
+
+func TestCountsAreCachedUnderNormalizedNames(t *testing.T) {
+	store, stub, cache, _ := newTestStore(time.Minute, 10)
+	stub.Normalize = func(name string) string { return strings.Join(strings.Fields(name), "-") }
+	acme := store.ForTenant("acme").(*EventStore)
+
+	acme.Put(domain.Event{Name: "page view", Timestamp: minute})
+	acme.CountInTimeRange("page-view", minute, minuteEnd)
+	if count, _ := acme.CountInTimeRange(" page  view ", minute, minuteEnd); count != 1 {
+		t.Errorf("expected 1 event, got %d", count)
+	}
+	if metrics := cache.Metrics(); metrics["count_cache.hits"] != 1 || metrics["count_cache.entries"] != 1 {
+		t.Errorf("expected names stored alike to share a count, got %v", metrics)
+	}
+
+	acme.Put(domain.Event{Name: "page\tview", Timestamp: minute})
+	if count, _ := acme.CountInTimeRange("page-view", minute, minuteEnd); count != 2 {
+		t.Errorf("expected the new event to invalidate the count, got %d", count)
+	}
+}
+
+func TestPutWhileCountingIsNotCached(t *testing.T) {
+	store, stub, _, _ := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+	tenant(stub, "acme").DuringCount = func() {
+		tenant(stub, "acme").DuringCount = nil
+		acme.Put(domain.Event{Name: "test", Timestamp: minute})
+	}
+
+	if count, _ := acme.CountInTimeRange("test", minute, minuteEnd); count != 0 {
+		t.Fatalf("expected the count to precede the event, got %d", count)
+	}
+	if count, _ := acme.CountInTimeRange("test", minute, minuteEnd); count != 1 {
+		t.Errorf("expected the count made before the event not to be cached, got %d", count)
+	}
+}
+
+func TestCountErrorsAreNotCached(t *testing.T) {
+	store, stub, cache, _ := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+	tenant(stub, "acme").Err = errStub
+
+	if _, err := acme.CountInTimeRange("test", minute, minuteEnd); err != errStub {
+		t.Errorf("expected store error, got %v", err)
+	}
+	if metrics := cache.Metrics(); metrics["count_cache.entries"] != 0 {
+		t.Errorf("expected nothing to be cached, got %v", metrics)
+	}
+}
+
+func TestLeastRecentlyUsedCountsAreEvicted(t *testing.T) {
+	store, stub, cache, _ := newTestStore(time.Minute, 2)
+	acme := store.ForTenant("acme").(*EventStore)
+
+	acme.CountInTimeRange("a", minute, minuteEnd)
+	acme.CountInTimeRange("b", minute, minuteEnd)
+	acme.CountInTimeRange("a", minute, minuteEnd)
+	acme.CountInTimeRange("c", minute, minuteEnd)
+	acme.CountInTimeRange("a", minute, minuteEnd)
+
+	if tenant(stub, "acme").Counted() != 3 {
+		t.Errorf("expected a to remain cached, got %d counts", tenant(stub, "acme").Counted())
+	}
+	if metrics := cache.Metrics(); metrics["count_cache.evictions"] != 1 || metrics["count_cache.entries"] != 2 {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+}
+
+func TestCachedCountsAreCopied(t *testing.T) {
+	store, _, _, _ := newTestStore(time.Minute, 10)
+	acme := store.ForTenant("acme").(*EventStore)
+	acme.Put(domain.Event{Name: "test", Timestamp: minute})
+
+	counts, _ := acme.CountAllInTimeRange(minute, minuteEnd)
+	counts["test"] = 100
+
+	if counts, _ := acme.CountAllInTimeRange(minute, minuteEnd); counts["test"] != 1 {
+		t.Errorf("expected the cached counts to be unaffected, got %v", counts)
+	}
+}
+
+func TestUnsupportedCapabilities(t *testing.T) {
+	store := NewEventStore(storetest.BasicEventStore{EventStore: storetest.NewEventStore()}, New(time.Minute, 10))
+	acme := store.ForTenant("acme").(*EventStore)
+
+	if err := acme.ScanInTimeRange("test", minute, minuteEnd, nil); err != (usecases.UnsupportedError{Operation: "exporting events"}) {
+		t.Errorf("expected UnsupportedError, got %v", err)
+	}
+	if _, err := acme.Subscribe(0); err == nil {
+		t.Error("expected UnsupportedError")
+	}
+}
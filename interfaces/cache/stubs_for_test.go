@@ -0,0 +1,5 @@
+package cache
+
+import "errors"
+
+var errStub = errors.New("error from EventStore")
@@ -0,0 +1,227 @@
+package datastore
+
+import (
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// alertRulesKey names a set of the keys of every tenant's alert rules.
+const alertRulesKey = "alert_rules"
+
+// setFiringScript sets the firing field of an existing rule, replying 1 if
+// it changed, 0 if it was already set to that value and -1 if the rule does
+// not exist. Running as a script makes the check and set atomic.
+var setFiringScript = redis.NewScript(1, `
+local current = redis.call("HGET", KEYS[1], "firing")
+if not current then
+	return -1
+end
+if current == ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "firing", ARGV[1])
+return 1
+`)
+
+// updateRuleScript sets the settings of an existing rule, keeping its secret
+// and whether it is firing, and replies with all of its fields, or with nil
+// if the rule does not exist. Running as a script stops a rule being brought
+// back by an update racing its deletion.
+var updateRuleScript = redis.NewScript(1, `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+redis.call("HMSET", KEYS[1], "event_name", ARGV[1], "threshold", ARGV[2], "window", ARGV[3], "webhook_url", ARGV[4])
+return redis.call("HGETALL", KEYS[1])
+`)
+
+type RedisAlertRuleStore struct {
+	pool *redis.Pool
+}
+
+// Get returns the rule of `tenant` identified by `id`, as well as any error
+// encountered. domain.ErrNotFound is returned if no such rule exists.
+func (store *RedisAlertRuleStore) Get(tenant, id string) (domain.AlertRule, error) {
+	return store.get(alertRuleKey(tenant, id))
+}
+
+func (store *RedisAlertRuleStore) get(key string) (domain.AlertRule, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.StringMap(conn.Do("HGETALL", key))
+	if err != nil {
+		return domain.AlertRule{}, storeError("getting alert rule", err)
+	}
+	if len(fields) == 0 {
+		return domain.AlertRule{}, domain.ErrNotFound
+	}
+	return ruleFromFields(fields)
+}
+
+// ruleFromFields returns the rule stored in a hash with the given fields.
+func ruleFromFields(fields map[string]string) (domain.AlertRule, error) {
+	var err error
+	rule := domain.AlertRule{
+		ID:         fields["id"],
+		Tenant:     fields["tenant"],
+		EventName:  fields["event_name"],
+		WebhookURL: fields["webhook_url"],
+		Secret:     fields["secret"],
+		Firing:     fields["firing"] == "1",
+	}
+	if rule.Threshold, err = strconv.Atoi(fields["threshold"]); err != nil {
+		return domain.AlertRule{}, storeError("getting alert rule", err)
+	}
+	if rule.Window, err = strconv.ParseInt(fields["window"], 10, 64); err != nil {
+		return domain.AlertRule{}, storeError("getting alert rule", err)
+	}
+	return rule, nil
+}
+
+// List returns every rule of `tenant`, as well as any error encountered.
+func (store *RedisAlertRuleStore) List(tenant string) ([]domain.AlertRule, error) {
+	return store.list(tenantKey(tenant, alertRulesKey))
+}
+
+// All returns the rules of every tenant, as well as any error encountered.
+func (store *RedisAlertRuleStore) All() ([]domain.AlertRule, error) {
+	return store.list(alertRulesKey)
+}
+
+// list returns the rules whose keys are members of the set `index`.
+func (store *RedisAlertRuleStore) list(index string) ([]domain.AlertRule, error) {
+	conn := store.pool.Get()
+	keys, err := redis.Strings(conn.Do("SMEMBERS", index))
+	conn.Close()
+	if err != nil {
+		return nil, storeError("listing alert rules", err)
+	}
+
+	rules := []domain.AlertRule{}
+	for _, key := range keys {
+		rule, err := store.get(key)
+		if err == domain.ErrNotFound {
+			// deleted since the set was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Put stores an alert rule in redis, returning any error encountered.
+func (store *RedisAlertRuleStore) Put(rule domain.AlertRule) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	key := alertRuleKey(rule.Tenant, rule.ID)
+
+	firing := "0"
+	if rule.Firing {
+		firing = "1"
+	}
+
+	conn.Send("MULTI")
+	conn.Send(
+		"HMSET", key,
+		"id", rule.ID,
+		"tenant", rule.Tenant,
+		"event_name", rule.EventName,
+		"threshold", rule.Threshold,
+		"window", rule.Window,
+		"webhook_url", rule.WebhookURL,
+		"secret", rule.Secret,
+		"firing", firing)
+	conn.Send("SADD", tenantKey(rule.Tenant, alertRulesKey), key)
+	conn.Send("SADD", alertRulesKey, key)
+
+	if _, err := conn.Do("EXEC"); err != nil {
+		return storeError("storing alert rule", err)
+	}
+	return nil
+}
+
+// Update sets the event name, threshold, window and webhook URL of an
+// existing rule to those of `rule`, returning the rule as stored. It returns
+// domain.ErrNotFound if no such rule exists.
+func (store *RedisAlertRuleStore) Update(rule domain.AlertRule) (domain.AlertRule, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.StringMap(updateRuleScript.Do(
+		conn, alertRuleKey(rule.Tenant, rule.ID),
+		rule.EventName, rule.Threshold, rule.Window, rule.WebhookURL))
+	if err == redis.ErrNil {
+		return domain.AlertRule{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.AlertRule{}, storeError("updating alert rule", err)
+	}
+	return ruleFromFields(fields)
+}
+
+// Delete deletes the rule of `tenant` identified by `id`, returning
+// domain.ErrNotFound if no such rule exists.
+func (store *RedisAlertRuleStore) Delete(tenant, id string) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	key := alertRuleKey(tenant, id)
+
+	conn.Send("MULTI")
+	conn.Send("DEL", key)
+	conn.Send("SREM", tenantKey(tenant, alertRulesKey), key)
+	conn.Send("SREM", alertRulesKey, key)
+
+	replies, err := redis.Ints(conn.Do("EXEC"))
+	if err != nil {
+		return storeError("deleting alert rule", err)
+	}
+	if replies[0] == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SetFiring records whether the rule of `tenant` identified by `id` is
+// firing, reporting whether this changed it.
+func (store *RedisAlertRuleStore) SetFiring(tenant, id string, firing bool) (bool, error) {
+	value := "0"
+	if firing {
+		value = "1"
+	}
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Int(setFiringScript.Do(conn, alertRuleKey(tenant, id), value))
+	if err != nil {
+		return false, storeError("updating alert rule", err)
+	}
+	if reply < 0 {
+		return false, domain.ErrNotFound
+	}
+	return reply == 1, nil
+}
+
+func alertRuleKey(tenant, id string) string {
+	return tenantKey(tenant, "alert_rule:"+id)
+}
+
+// NewRedisAlertRuleStore opens a pool of TCP connections to a redis server
+// at the given address and port. It returns an intialised
+// RedisAlertRuleStore struct as well as any error encountered.
+func NewRedisAlertRuleStore(addr, port string) (RedisAlertRuleStore, error) {
+	pool, err := newPool(addr, port)
+	if err != nil {
+		return RedisAlertRuleStore{}, err
+	}
+	return RedisAlertRuleStore{pool: pool}, nil
+}
@@ -0,0 +1,131 @@
+package datastore
+
+import (
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+var testAlertRule = domain.AlertRule{
+	ID:         "a1b2c3",
+	Tenant:     "acme",
+	EventName:  "payment_failed",
+	Threshold:  100,
+	Window:     300,
+	WebhookURL: "https://example.com/hooks/alerts",
+	Secret:     "s3cret",
+}
+
+func TestAlertRulePutAndGet(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAlertRuleStore("127.0.0.1", "12313")
+	if err := store.Put(testAlertRule); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	stored, err := store.Get("acme", "a1b2c3")
+	if err != nil || stored != testAlertRule {
+		t.Errorf("expected %+v, got %+v", testAlertRule, stored)
+	}
+
+	if _, err := store.Get("other", "a1b2c3"); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound for another tenant, got %v", err)
+	}
+}
+
+func TestAlertRuleListAndAll(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAlertRuleStore("127.0.0.1", "12313")
+	other := testAlertRule
+	other.Tenant = "other"
+	store.Put(testAlertRule)
+	store.Put(other)
+
+	rules, err := store.List("acme")
+	if err != nil || len(rules) != 1 || rules[0] != testAlertRule {
+		t.Errorf("expected only the rule of acme, got %+v", rules)
+	}
+
+	if rules, err := store.All(); err != nil || len(rules) != 2 {
+		t.Errorf("expected the rules of every tenant, got %+v", rules)
+	}
+}
+
+func TestAlertRuleDelete(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAlertRuleStore("127.0.0.1", "12313")
+	store.Put(testAlertRule)
+
+	if err := store.Delete("acme", "a1b2c3"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if rules, _ := store.All(); len(rules) != 0 {
+		t.Errorf("expected no rules, got %+v", rules)
+	}
+	if err := store.Delete("acme", "a1b2c3"); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAlertRuleUpdate(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAlertRuleStore("127.0.0.1", "12313")
+	store.Put(testAlertRule)
+	store.SetFiring("acme", "a1b2c3", true)
+
+	update := domain.AlertRule{
+		ID:         "a1b2c3",
+		Tenant:     "acme",
+		EventName:  "refund_issued",
+		Threshold:  5,
+		Window:     60,
+		WebhookURL: "https://example.com/hooks/refunds",
+	}
+	expected := update
+	expected.Secret, expected.Firing = testAlertRule.Secret, true
+
+	updated, err := store.Update(update)
+	if err != nil || updated != expected {
+		t.Errorf("expected %+v, got %+v (%v)", expected, updated, err)
+	}
+	if stored, _ := store.Get("acme", "a1b2c3"); stored != expected {
+		t.Errorf("expected %+v to be stored, got %+v", expected, stored)
+	}
+
+	store.Delete("acme", "a1b2c3")
+	if _, err := store.Update(update); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if rules, _ := store.All(); len(rules) != 0 {
+		t.Errorf("expected the deleted rule to stay deleted, got %+v", rules)
+	}
+}
+
+func TestAlertRuleSetFiring(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAlertRuleStore("127.0.0.1", "12313")
+	store.Put(testAlertRule)
+
+	if changed, err := store.SetFiring("acme", "a1b2c3", true); err != nil || !changed {
+		t.Errorf("expected the rule to change, got %t, %v", changed, err)
+	}
+	if changed, err := store.SetFiring("acme", "a1b2c3", true); err != nil || changed {
+		t.Errorf("expected the rule not to change again, got %t, %v", changed, err)
+	}
+	if stored, _ := store.Get("acme", "a1b2c3"); !stored.Firing {
+		t.Error("expected the rule to be firing")
+	}
+	if _, err := store.SetFiring("acme", "missing", true); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
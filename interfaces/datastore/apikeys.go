@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+type RedisAPIKeyStore struct {
+	pool *redis.Pool
+}
+
+// Get returns the API key identified by `hash`, as well as any error
+// encountered. domain.ErrNotFound is returned if no such key exists. Keys
+// stored without rate limits have zero limits.
+func (store *RedisAPIKeyStore) Get(hash string) (domain.APIKey, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do(
+		"HMGET", apiKeyKey(hash), "scopes", "tenant",
+		"read_rate", "read_burst", "write_rate", "write_burst"))
+	if err != nil {
+		return domain.APIKey{}, storeError("getting API key", err)
+	}
+
+	var scopes string
+	key := domain.APIKey{Hash: hash}
+	if _, err := redis.Scan(values, &scopes, &key.Tenant,
+		&key.ReadLimit.Rate, &key.ReadLimit.Burst,
+		&key.WriteLimit.Rate, &key.WriteLimit.Burst); err != nil {
+		return domain.APIKey{}, storeError("getting API key", err)
+	}
+	if scopes == "" {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	key.Scopes = strings.Split(scopes, ",")
+	return key, nil
+}
+
+// Put stores an API key in redis, returning any error encountered.
+func (store *RedisAPIKeyStore) Put(key domain.APIKey) error {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do(
+		"HMSET", apiKeyKey(key.Hash),
+		"scopes", strings.Join(key.Scopes, ","),
+		"tenant", key.Tenant,
+		"read_rate", key.ReadLimit.Rate,
+		"read_burst", key.ReadLimit.Burst,
+		"write_rate", key.WriteLimit.Rate,
+		"write_burst", key.WriteLimit.Burst)
+	if err != nil {
+		return storeError("storing API key", err)
+	}
+	return nil
+}
+
+func apiKeyKey(hash string) string {
+	return fmt.Sprintf("apikey:%s", hash)
+}
+
+// NewRedisAPIKeyStore opens a pool of TCP connections to a redis server at
+// the given address and port. It returns an intialised RedisAPIKeyStore
+// struct as well as any error encountered.
+func NewRedisAPIKeyStore(addr, port string) (RedisAPIKeyStore, error) {
+	pool, err := newPool(addr, port)
+	if err != nil {
+		return RedisAPIKeyStore{}, err
+	}
+	return RedisAPIKeyStore{pool: pool}, nil
+}
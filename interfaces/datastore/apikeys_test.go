@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestAPIKeyPutAndGet(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAPIKeyStore("127.0.0.1", "12313")
+	key := domain.APIKey{
+		Hash:       "abc123",
+		Scopes:     []string{"events:read", "events:write"},
+		Tenant:     "acme",
+		ReadLimit:  domain.RateLimit{Rate: 2.5, Burst: 10},
+		WriteLimit: domain.RateLimit{Rate: 0.5, Burst: 3},
+	}
+
+	if err := store.Put(key); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	stored, err := store.Get("abc123")
+	if err != nil || !reflect.DeepEqual(stored, key) {
+		t.Errorf("expected %v, got %v", key, stored)
+	}
+}
+
+func TestAPIKeyStoredByHash(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	store := RedisAPIKeyStore{pool: testPool()}
+	store.Put(domain.APIKey{Hash: "abc123", Scopes: []string{"admin"}})
+
+	scopes, err := redis.String(conn.Do("HGET", "apikey:abc123", "scopes"))
+	if err != nil || scopes != "admin" {
+		t.Error("API key not stored by hash")
+	}
+}
+
+func TestAPIKeyGetWithoutLimits(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	// keys stored before rate limits were stored have no limit fields
+	conn.Do("HMSET", "apikey:abc123", "scopes", "events:read", "tenant", "acme")
+
+	store := RedisAPIKeyStore{pool: testPool()}
+	key, err := store.Get("abc123")
+	if err != nil || !key.ReadLimit.IsZero() || !key.WriteLimit.IsZero() {
+		t.Errorf("expected a key without limits, got %+v, %v", key, err)
+	}
+}
+
+func TestAPIKeyGetNotFound(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisAPIKeyStore("127.0.0.1", "12313")
+	if _, err := store.Get("missing"); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAPIKeyGetConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	store, _ := NewRedisAPIKeyStore("127.0.0.1", "12313")
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	if _, err := store.Get("abc123"); err == nil || err == domain.ErrNotFound {
+		t.Fail()
+	}
+}
@@ -0,0 +1,62 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Timeout bounds how long connecting to redis, and each read or write on a
+// connection, may take before failing with domain.ErrTimeout.
+var Timeout = 5 * time.Second
+
+// poolMaxIdle is the number of idle connections each pool keeps open for
+// reuse.
+var poolMaxIdle = 16
+
+// dial opens a TCP connection to a redis server at the given address and port.
+func dial(addr, port string) (redis.Conn, error) {
+	return redis.Dial(
+		"tcp",
+		fmt.Sprintf("%s:%s", addr, port),
+		redis.DialConnectTimeout(Timeout),
+		redis.DialReadTimeout(Timeout),
+		redis.DialWriteTimeout(Timeout))
+}
+
+// newPool returns a pool of connections to a redis server at the given
+// address and port, as well as any error encountered reaching it. A
+// redis.Conn must not be used by more than one goroutine at once, or the
+// commands of concurrent requests interleave within each other's
+// transactions, so every operation takes a connection of its own from the
+// pool and returns it when done.
+func newPool(addr, port string) (*redis.Pool, error) {
+	pool := &redis.Pool{
+		Dial:        func() (redis.Conn, error) { return dial(addr, port) },
+		MaxIdle:     poolMaxIdle,
+		IdleTimeout: time.Minute,
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		return nil, storeError("connecting to redis", err)
+	}
+	return pool, nil
+}
+
+// dialSubscriber opens a connection for receiving published messages. Unlike
+// connections opened by dial, reads have no timeout, since a subscriber may
+// wait indefinitely for a message.
+func dialSubscriber(addr, port string) (redis.Conn, error) {
+	conn, err := redis.Dial(
+		"tcp",
+		fmt.Sprintf("%s:%s", addr, port),
+		redis.DialConnectTimeout(Timeout),
+		redis.DialWriteTimeout(Timeout))
+	if err != nil {
+		return nil, storeError("connecting to redis", err)
+	}
+	return conn, nil
+}
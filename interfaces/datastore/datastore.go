@@ -2,14 +2,16 @@
 package datastore
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/garyburd/redigo/redis"
 
 	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
 )
 
 func sanitizeName(name string) string {
@@ -18,73 +20,291 @@ func sanitizeName(name string) string {
 }
 
 type RedisEventStore struct {
-	conn  redis.Conn
-	idgen IdGenerator
+	pool   *redis.Pool
+	tenant string
+	hub    *eventHub
+}
+
+// ForTenant returns a RedisEventStore which prefixes every key it reads or
+// writes with `tenant`, so that tenants sharing a redis server never see
+// each other's events. The default tenant's keys are not prefixed, so it
+// owns the data stored before the service had tenants.
+func (store *RedisEventStore) ForTenant(tenant string) domain.EventStore {
+	if tenant == usecases.DefaultTenant {
+		tenant = ""
+	}
+	return &RedisEventStore{pool: store.pool, tenant: tenant, hub: store.hub}
+}
+
+// key formats a redis key, prefixing it with the store's tenant if it has one.
+func (store *RedisEventStore) key(format string, args ...interface{}) string {
+	return tenantKey(store.tenant, fmt.Sprintf(format, args...))
+}
+
+// tenantKey prefixes `key` with `tenant`, unless it is empty or the default
+// tenant, whose keys are not prefixed.
+func tenantKey(tenant, key string) string {
+	if tenant == "" || tenant == usecases.DefaultTenant {
+		return key
+	}
+	return fmt.Sprintf("tenant:%s:%s", tenant, key)
+}
+
+// NormalizeName returns `name` as the store keys it, trimmed of surrounding
+// whitespace and with each run of whitespace replaced by a dash.
+func (store *RedisEventStore) NormalizeName(name string) string {
+	return sanitizeName(name)
 }
 
 // CountInTimeRange returns an integer count of all events with a given name
 // and timestamp between `start` and `end`, as well as any error encountered.
+// Whole minutes, hours and days within the range are counted from rollups,
+// and only the unaligned edges of the range from the events. Names stored
+// before rollups were introduced are counted from the events alone until
+// BuildRollups has built their rollups.
 func (store *RedisEventStore) CountInTimeRange(name string, start, end int64) (int, error) {
-	index := fmt.Sprintf("events:%s:by-timestamp", sanitizeName(name))
-	count, err := redis.Int(store.conn.Do("ZCOUNT", index, start, end))
+	counts, err := store.CountInTimeRanges(name, [][2]int64{{start, end}})
 	if err != nil {
-		return 0, errors.New("error getting event count")
+		return 0, err
+	}
+	return counts[0], nil
+}
+
+// CountInTimeRanges counts the events with a given name in each of
+// `ranges`, as CountInTimeRange does, in a single transaction.
+func (store *RedisEventStore) CountInTimeRanges(name string, ranges [][2]int64) ([]int, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	return store.countRanges(conn, sanitizeName(name), ranges)
+}
+
+// countAllScript counts the events of every name in the set KEYS[1] with
+// timestamps between ARGV[2] and ARGV[3], replying with alternating names and
+// non-zero counts. Each name's index is found by sanitizing it as
+// sanitizeName does and prefixing it with ARGV[1].
+var countAllScript = redis.NewScript(1, `
+local counts = {}
+for _, name in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	local sanitized = name:gsub("^[ \t\n\f\r]+", ""):gsub("[ \t\n\f\r]+$", ""):gsub("[ \t\n\f\r]+", "-")
+	local count = redis.call("ZCOUNT", ARGV[1] .. sanitized .. ":by-timestamp", ARGV[2], ARGV[3])
+	if count > 0 then
+		table.insert(counts, name)
+		table.insert(counts, count)
+	end
+end
+return counts
+`)
+
+// CountAllInTimeRange returns the number of events of each name with a
+// timestamp between `start` and `end`, omitting names with none, as well as
+// any error encountered. Every name is counted by a script run on the redis
+// server, so that counting costs a single round trip however many names
+// there are. Within the script each name is counted with ZCOUNT, which
+// takes logarithmic time, so summing rollups would not make it any cheaper.
+func (store *RedisEventStore) CountAllInTimeRange(start, end int64) (map[string]int, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Values(countAllScript.Do(
+		conn, store.key("event_names"), store.key("events:"), start, end))
+	if err != nil {
+		return nil, storeError("getting event counts", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i+1 < len(values); i += 2 {
+		name, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, storeError("getting event counts", err)
+		}
+		if counts[name], err = redis.Int(values[i+1], nil); err != nil {
+			return nil, storeError("getting event counts", err)
+		}
+	}
+	return counts, nil
+}
+
+// scanPageSize is the number of events fetched from redis at a time while
+// scanning, which bounds the memory used however many events match.
+var scanPageSize = 1000
+
+// ScanInTimeRange calls `fn` with each event with a given name and timestamp
+// between `start` and `end`, in timestamp order. Events are fetched a page at
+// a time, each page starting from the last timestamp seen rather than from
+// an offset into the whole range, so that fetching a page does not get
+// slower as the scan progresses. A connection is only held while fetching
+// each page, not while `fn` handles it.
+func (store *RedisEventStore) ScanInTimeRange(name string, start, end int64, fn func(domain.Event) error) error {
+	index := store.key("events:%s:by-timestamp", sanitizeName(name))
+
+	// the number of events already seen with timestamp `start`
+	skip := 0
+	for {
+		conn := store.pool.Get()
+		values, err := redis.Strings(conn.Do(
+			"ZRANGEBYSCORE", index, start, end, "WITHSCORES", "LIMIT", skip, scanPageSize))
+		conn.Close()
+		if err != nil {
+			return storeError("scanning events", err)
+		}
+
+		for i := 1; i < len(values); i += 2 {
+			timestamp, err := strconv.ParseInt(values[i], 10, 64)
+			if err != nil {
+				return storeError("scanning events", err)
+			}
+			if timestamp != start {
+				start, skip = timestamp, 0
+			}
+			skip++
+
+			if err := fn(domain.Event{Name: name, Timestamp: timestamp}); err != nil {
+				return err
+			}
+		}
+
+		if len(values) < 2*scanPageSize {
+			return nil
+		}
 	}
-	return count, nil
 }
 
 // Names returns a string slice containing all previously stored event names,
 // as well as any error encountered.
 func (store *RedisEventStore) Names() ([]string, error) {
-	names, err := redis.Strings(store.conn.Do("SMEMBERS", "event_names"))
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	names, err := redis.Strings(conn.Do("SMEMBERS", store.key("event_names")))
 	if err != nil {
-		return []string{}, errors.New("error getting event names")
+		return []string{}, storeError("getting event names", err)
 	}
 	return names, nil
 }
 
-// Put stores a new event in redis, returning any error encountered.
+// Put stores a new event in redis, returning any error encountered. The
+// event's ID is allocated by the transaction which stores and publishes it,
+// which is retried if another event is stored meanwhile, so that events are
+// published in the order of their IDs.
 func (store *RedisEventStore) Put(event domain.Event) error {
-	id, err := store.idgen.Next()
-	if err != nil {
-		return errors.New("error generating event ID")
-	}
+	conn := store.pool.Get()
+	defer conn.Close()
 
-	key := fmt.Sprintf("event:%d", id)
-	return store.store(key, event)
+	counter := store.key("next_event_id")
+	index := store.key("events:%s:by-timestamp", sanitizeName(event.Name))
+	for {
+		if _, err := conn.Do("WATCH", counter); err != nil {
+			return storeError("generating event ID", err)
+		}
+		last, err := redis.Int64(conn.Do("GET", counter))
+		if err != nil && err != redis.ErrNil {
+			conn.Do("UNWATCH")
+			return storeError("generating event ID", err)
+		}
+
+		// every event stored changes the counter, so the index is as read
+		// until the transaction commits
+		stored, err := redis.Int(conn.Do("ZCARD", index))
+		if err != nil {
+			conn.Do("UNWATCH")
+			return storeError("storing event", err)
+		}
+
+		if ok, err := store.store(conn, last+1, event, stored == 0); err != nil || ok {
+			return err
+		}
+	}
 }
 
-func (store *RedisEventStore) store(key string, event domain.Event) error {
-	index := fmt.Sprintf("events:%s:by-timestamp", sanitizeName(event.Name))
+// store stores `event` under `id` on `conn`, which must be watching the ID
+// counter, reporting false if the counter changed before the transaction
+// could be committed. If `first` is set, no events of the same name are
+// stored yet, so the rollups of the name will hold every event.
+func (store *RedisEventStore) store(conn redis.Conn, id int64, event domain.Event, first bool) (bool, error) {
+	key := store.key("event:%d", id)
+	index := store.key("events:%s:by-timestamp", sanitizeName(event.Name))
+	message, _ := json.Marshal(streamMessage{ID: id, Name: event.Name, Timestamp: event.Timestamp})
 
 	// storing an event triggers a redis transaction comprising multiple operations
-	store.conn.Send("MULTI")
+	conn.Send("MULTI")
+
+	// claim the ID, which aborts any transaction claiming it concurrently
+	conn.Send("SET", store.key("next_event_id"), id)
 
 	// add the event name to a set of all known event names (will do nothing if name already exists)
-	store.conn.Send("SADD", "event_names", event.Name)
+	conn.Send("SADD", store.key("event_names"), event.Name)
 
 	// store the event data in a hash, uniquely identified by `key`
-	store.conn.Send("HMSET", key, "name", event.Name, "timestamp", event.Timestamp)
+	fields := redis.Args{}.Add(key, "name", event.Name, "timestamp", event.Timestamp)
+	if event.Actor != "" {
+		fields = fields.Add("actor", event.Actor)
+	}
+	if event.Value != nil {
+		fields = fields.Add("value", formatValue(*event.Value))
+	}
+	conn.Send("HMSET", fields...)
 
 	// add the event key to a sorted set of events with the same name,
 	// sorted by timestamp to allow for efficient range queries
-	store.conn.Send("ZADD", index, event.Timestamp, key)
+	conn.Send("ZADD", index, event.Timestamp, key)
 
-	if _, err := store.conn.Do("EXEC"); err != nil {
-		return errors.New("error storing event")
+	// count the event in the rollups of its name, which allow counts over
+	// long time ranges without visiting every event
+	for _, unit := range rollupUnits {
+		rollup := store.key("events:%s:rollup:%s", sanitizeName(event.Name), unit.name)
+		conn.Send("HINCRBY", rollup, floorDiv(event.Timestamp, unit.seconds), 1)
+	}
+	if first {
+		conn.Send("SADD", store.key(rollupNamesKey), sanitizeName(event.Name))
+	}
+
+	// add the actor to HyperLogLogs of the same buckets, which estimate the
+	// number of distinct actors over any range of them
+	if event.Actor != "" {
+		for _, unit := range rollupUnits {
+			bucket := floorDiv(event.Timestamp, unit.seconds)
+			conn.Send("PFADD", store.actorsKey(sanitizeName(event.Name), unit.name, bucket), event.Actor)
+		}
+	}
+
+	// add the value to sketches of the same buckets, which summarise the
+	// values over any range of them
+	if event.Value != nil {
+		store.sendValue(conn, sanitizeName(event.Name), event)
+	}
+
+	// add the event key to a sorted set of all events, sorted by ID, so
+	// that subscribers can resume from the last event they received
+	conn.Send("ZADD", store.key("events:by-id"), id, key)
+
+	// notify subscribers connected to any instance of the service
+	conn.Send("PUBLISH", store.key(streamChannel), message)
+
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, storeError("storing event", err)
+	}
+	// a failing command does not abort the transaction, but has its error
+	// as its reply
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return false, storeError("storing event", err)
+		}
 	}
-	return nil
+	return true, nil
 }
 
-// NewRedisEventStore opens a TCP connection to a redis server at the given
-// address and port. It returns an intialised RedisEventStore struct as well
-// as any error encountered.
+// NewRedisEventStore opens a pool of TCP connections to a redis server at the
+// given address and port. It returns an intialised RedisEventStore struct as
+// well as any error encountered.
 func NewRedisEventStore(addr, port string) (RedisEventStore, error) {
-	address := fmt.Sprintf("%s:%s", addr, port)
-	conn, err := redis.Dial("tcp", address)
+	pool, err := newPool(addr, port)
 	if err != nil {
-		return RedisEventStore{}, errors.New("error connecting to redis")
+		return RedisEventStore{}, err
 	}
-	idgen := RedisIdGenerator{conn: conn, name: "next_event_id"}
-	return RedisEventStore{conn: conn, idgen: &idgen}, nil
+	return RedisEventStore{pool: pool, hub: newEventHub(addr, port)}, nil
 }
@@ -2,6 +2,8 @@ package datastore
 
 import (
 	"errors"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/garyburd/redigo/redis"
@@ -9,18 +11,6 @@ import (
 	"github.com/declantraynor/go-events-service/domain"
 )
 
-type PassingIdGenerator struct{}
-
-func (stub *PassingIdGenerator) Next() (int64, error) {
-	return 1, nil
-}
-
-type FailingIdGenerator struct{}
-
-func (stub *FailingIdGenerator) Next() (int64, error) {
-	return 0, errors.New("error from IdGenerator->Next")
-}
-
 func TestNewRedisEventStore(t *testing.T) {
 	server := startRedis("12313")
 	defer stopRedis(server)
@@ -31,10 +21,9 @@ func TestNewRedisEventStore(t *testing.T) {
 }
 
 func TestNewRedisEventConnectionError(t *testing.T) {
-	expectedError := "error connecting to redis"
 	_, err := NewRedisEventStore("127.0.0.1", "6379")
-	if err == nil || err.Error() != expectedError {
-		t.Errorf("expected error %q, got %q", expectedError, err.Error())
+	if !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
 	}
 }
 
@@ -70,6 +59,36 @@ func TestCountInTimeRange(t *testing.T) {
 	}
 }
 
+func TestCountAllInTimeRange(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	acme := store.ForTenant("acme").(*RedisEventStore)
+	events := []domain.Event{
+		{Name: "test", Timestamp: 1423666860},
+		{Name: "test", Timestamp: 1423666861},
+		{Name: "name with spaces", Timestamp: 1423666862},
+		{Name: "foo", Timestamp: 1423666900},
+	}
+	for _, event := range events {
+		acme.Put(event)
+	}
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+
+	counts, err := acme.CountAllInTimeRange(1423666860, 1423666870)
+	expected := map[string]int{"test": 2, "name with spaces": 1}
+	if err != nil || !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v, %v", expected, counts, err)
+	}
+
+	for name, count := range expected {
+		if single, _ := acme.CountInTimeRange(name, 1423666860, 1423666870); single != count {
+			t.Errorf("expected counts of %q to agree, got %d and %d", name, count, single)
+		}
+	}
+}
+
 func TestCountInTimeRangeConnectionError(t *testing.T) {
 	server := startRedis("12313")
 	store, _ := NewRedisEventStore("127.0.0.1", "12313")
@@ -77,8 +96,79 @@ func TestCountInTimeRangeConnectionError(t *testing.T) {
 	// simulate redis connection loss
 	stopRedis(server)
 
-	if _, err := store.CountInTimeRange("test", 1423666860, 1423666870); err == nil {
-		t.Fail()
+	if _, err := store.CountInTimeRange("test", 1423666860, 1423666870); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestScanInTimeRange(t *testing.T) {
+	timestamps := []int64{1423666859, 1423666860, 1423666860, 1423666860, 1423666861, 1423666862, 1423666870, 1423666871}
+
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	// use small pages so that pages begin and end among events sharing a timestamp
+	defer func(size int) { scanPageSize = size }(scanPageSize)
+	scanPageSize = 2
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	for _, timestamp := range timestamps {
+		store.Put(domain.Event{Name: "test", Timestamp: timestamp})
+	}
+	store.Put(domain.Event{Name: "other", Timestamp: 1423666860})
+
+	var scanned []int64
+	err := store.ScanInTimeRange("test", 1423666860, 1423666870, func(event domain.Event) error {
+		if event.Name != "test" {
+			t.Errorf("expected event named test, got %q", event.Name)
+		}
+		scanned = append(scanned, event.Timestamp)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := timestamps[1:7]
+	if len(scanned) != len(expected) {
+		t.Fatalf("expected timestamps %v, got %v", expected, scanned)
+	}
+	for i := range expected {
+		if scanned[i] != expected[i] {
+			t.Fatalf("expected timestamps %v, got %v", expected, scanned)
+		}
+	}
+}
+
+func TestScanInTimeRangeStopsAtError(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666861})
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.ScanInTimeRange("test", 1423666860, 1423666870, func(event domain.Event) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected scan to stop at first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestScanInTimeRangeConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	err := store.ScanInTimeRange("test", 1423666860, 1423666870, func(domain.Event) error { return nil })
+	if !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
 	}
 }
 
@@ -113,7 +203,7 @@ func TestNames(t *testing.T) {
 
 	for _, name := range expected {
 		if !stringInSlice(name, names) {
-			t.Errorf("expected value %q not present in names")
+			t.Errorf("expected value %q not present in names", name)
 		}
 	}
 }
@@ -125,7 +215,7 @@ func TestPut(t *testing.T) {
 	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
 	defer conn.Close()
 
-	store := RedisEventStore{conn: conn, idgen: &PassingIdGenerator{}}
+	store := RedisEventStore{pool: testPool()}
 	event := domain.Event{Name: "test", Timestamp: 1423666860}
 
 	if err := store.Put(event); err != nil {
@@ -152,6 +242,44 @@ func TestPut(t *testing.T) {
 
 }
 
+func TestConcurrentPutAndCount(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	base := int64(1423612800)
+
+	// counting a range spanning rollups while events are stored builds them
+	// in a watched transaction, which must not take in other goroutines'
+	// commands
+	var wg sync.WaitGroup
+	errs := make(chan error, 800)
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				event := domain.Event{Name: "test", Timestamp: base + int64(worker*3600+i*60)}
+				if err := store.Put(event); err != nil {
+					errs <- err
+				}
+				if _, err := store.CountInTimeRange("test", base, base+86399); err != nil {
+					errs <- err
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if count, err := store.CountInTimeRange("test", base, base+86399); err != nil || count != 400 {
+		t.Errorf("expected %d events, got %d, %v", 400, count, err)
+	}
+}
+
 func TestNamesConnectionError(t *testing.T) {
 	server := startRedis("12313")
 	store, _ := NewRedisEventStore("127.0.0.1", "12313")
@@ -159,18 +287,15 @@ func TestNamesConnectionError(t *testing.T) {
 	// simulate redis connection loss
 	stopRedis(server)
 
-	if _, err := store.Names(); err == nil {
-		t.Fail()
+	if _, err := store.Names(); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
 	}
 }
 
 func TestPutConnectionError(t *testing.T) {
 	server := startRedis("12313")
 
-	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
-	defer conn.Close()
-
-	store := RedisEventStore{conn: conn, idgen: &PassingIdGenerator{}}
+	store := RedisEventStore{pool: testPool()}
 	event := domain.Event{Name: "test", Timestamp: 1423666860}
 
 	// simulate redis connection loss
@@ -181,18 +306,26 @@ func TestPutConnectionError(t *testing.T) {
 	}
 }
 
-func TestPutIdGeneratorError(t *testing.T) {
+func TestPutAllocatesIDsInOrder(t *testing.T) {
 	server := startRedis("12313")
 	defer stopRedis(server)
 
 	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
 	defer conn.Close()
 
-	store := RedisEventStore{conn: conn, idgen: &FailingIdGenerator{}}
-	event := domain.Event{Name: "test", Timestamp: 1423666860}
+	store := RedisEventStore{pool: testPool()}
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666861})
+	store.Put(domain.Event{Name: "other", Timestamp: 1423666860})
 
-	if err := store.Put(event); err == nil {
-		t.Fail()
+	ids, err := redis.Strings(conn.Do("ZRANGE", "events:by-id", 0, -1, "WITHSCORES"))
+	expected := []string{"event:1", "1", "event:2", "2"}
+	if err != nil || !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected events by ID %v, got %v (%v)", expected, ids, err)
+	}
+
+	name, err := redis.String(conn.Do("HGET", "event:2", "name"))
+	if err != nil || name != "other" {
+		t.Errorf("expected event:2 to be named other, got %q (%v)", name, err)
 	}
 }
 
@@ -214,3 +347,105 @@ func TestSanitizeName(t *testing.T) {
 		}
 	}
 }
+
+func TestForTenantPrefixesKeys(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	store := RedisEventStore{pool: testPool()}
+	event := domain.Event{Name: "test", Timestamp: 1423666860}
+
+	if err := store.ForTenant("acme").Put(event); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	keys, _ := redis.Strings(conn.Do("KEYS", "*"))
+	expected := []string{
+		"tenant:acme:next_event_id",
+		"tenant:acme:event_names",
+		"tenant:acme:event:1",
+		"tenant:acme:events:test:by-timestamp",
+		"tenant:acme:events:by-id",
+		"tenant:acme:events:test:rollup:minute",
+		"tenant:acme:events:test:rollup:hour",
+		"tenant:acme:events:test:rollup:day",
+	}
+	if len(keys) != len(expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+	for _, key := range expected {
+		if !stringInSlice(key, keys) {
+			t.Errorf("expected key %q not present in %v", key, keys)
+		}
+	}
+}
+
+func TestDefaultTenantKeysAreNotPrefixed(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	// an event stored before the service had tenants
+	conn.Do("SADD", "event_names", "test")
+	conn.Do("HMSET", "event:1", "name", "test", "timestamp", 1423666860)
+	conn.Do("ZADD", "events:test:by-timestamp", 1423666860, "event:1")
+
+	store := RedisEventStore{pool: testPool()}
+	scoped := store.ForTenant("default")
+	if count, _ := scoped.CountInTimeRange("test", 1423666860, 1423666870); count != 1 {
+		t.Errorf("expected the unprefixed event to be counted, got %d", count)
+	}
+	if names, _ := scoped.Names(); len(names) != 1 || names[0] != "test" {
+		t.Errorf("expected the unprefixed name, got %v", names)
+	}
+
+	if err := scoped.Put(domain.Event{Name: "test", Timestamp: 1423666861}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if keys, _ := redis.Strings(conn.Do("KEYS", "tenant:*")); len(keys) != 0 {
+		t.Errorf("expected no prefixed keys, got %v", keys)
+	}
+}
+
+func TestTenantsAreIsolated(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	acme := store.ForTenant("acme")
+	globex := store.ForTenant("globex")
+
+	acme.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+	acme.Put(domain.Event{Name: "test", Timestamp: 1423666861})
+	acme.Put(domain.Event{Name: "foo", Timestamp: 1423666862})
+	globex.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+	globex.Put(domain.Event{Name: "bar", Timestamp: 1423666863})
+
+	if count, _ := acme.CountInTimeRange("test", 1423666860, 1423666870); count != 2 {
+		t.Errorf("expected %d events for acme, got %d", 2, count)
+	}
+	if count, _ := globex.CountInTimeRange("test", 1423666860, 1423666870); count != 1 {
+		t.Errorf("expected %d events for globex, got %d", 1, count)
+	}
+	if count, _ := globex.CountInTimeRange("foo", 1423666860, 1423666870); count != 0 {
+		t.Errorf("expected %d foo events for globex, got %d", 0, count)
+	}
+
+	acmeNames, _ := acme.Names()
+	if len(acmeNames) != 2 || stringInSlice("bar", acmeNames) {
+		t.Errorf("unexpected names for acme %v", acmeNames)
+	}
+	globexNames, _ := globex.Names()
+	if len(globexNames) != 2 || stringInSlice("foo", globexNames) {
+		t.Errorf("unexpected names for globex %v", globexNames)
+	}
+
+	if names, _ := store.Names(); len(names) != 0 {
+		t.Errorf("expected no unscoped names, got %v", names)
+	}
+}
@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"net"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// unavailableReplies are prefixes of redis error replies which indicate the
+// server is temporarily unable to serve requests.
+var unavailableReplies = []string{"LOADING", "BUSY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN"}
+
+// storeError wraps an error encountered while performing `op`, classifying
+// it as one of the domain's kinds of store failure.
+func storeError(op string, err error) error {
+	return &domain.StoreError{Op: op, Kind: classify(err), Err: err}
+}
+
+func classify(err error) error {
+	if err == redis.ErrNil {
+		// EXEC replies nil when a watched key changed during a transaction
+		return domain.ErrConflict
+	}
+
+	if reply, ok := err.(redis.Error); ok {
+		for _, prefix := range unavailableReplies {
+			if strings.HasPrefix(string(reply), prefix) {
+				return domain.ErrUnavailable
+			}
+		}
+		// any other error reply indicates a fault in the request itself
+		return nil
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return domain.ErrTimeout
+	}
+
+	// anything else is a failure to communicate with the server
+	return domain.ErrUnavailable
+}
@@ -0,0 +1,56 @@
+package datastore
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+type timeoutError struct{}
+
+func (err timeoutError) Error() string   { return "i/o timeout" }
+func (err timeoutError) Timeout() bool   { return true }
+func (err timeoutError) Temporary() bool { return true }
+
+func TestStoreErrorClassification(t *testing.T) {
+	cases := []struct {
+		cause error
+		kind  error
+	}{
+		{io.EOF, domain.ErrUnavailable},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, domain.ErrUnavailable},
+		{&net.OpError{Op: "read", Err: timeoutError{}}, domain.ErrTimeout},
+		{redis.ErrNil, domain.ErrConflict},
+		{redis.Error("LOADING Redis is loading the dataset in memory"), domain.ErrUnavailable},
+		{redis.Error("BUSY Redis is busy running a script"), domain.ErrUnavailable},
+		{redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), nil},
+	}
+
+	kinds := []error{domain.ErrUnavailable, domain.ErrTimeout, domain.ErrConflict, domain.ErrNotFound}
+
+	for _, c := range cases {
+		err := storeError("testing", c.cause)
+
+		if !errors.Is(err, c.cause) {
+			t.Errorf("%v: expected cause to be wrapped", c.cause)
+		}
+
+		for _, kind := range kinds {
+			if errors.Is(err, kind) != (kind == c.kind) {
+				t.Errorf("%v: expected kind %v, got errors.Is(%v) == %t", c.cause, c.kind, kind, errors.Is(err, kind))
+			}
+		}
+	}
+}
+
+func TestStoreErrorMessage(t *testing.T) {
+	err := storeError("storing event", io.EOF)
+	if err.Error() != "error storing event: EOF" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
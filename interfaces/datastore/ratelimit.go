@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// tokenBucketScript refills and takes a token from the bucket stored at
+// KEYS[1] in a single atomic step, so that replicas sharing a redis server
+// also share each client's limit. It returns whether the request is allowed
+// and, if not, how many milliseconds to wait before retrying.
+var tokenBucketScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil then
+	tokens = burst
+	updated = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - updated) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tokens, "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, wait}
+`)
+
+type RedisRateLimiter struct {
+	pool *redis.Pool
+	now  func() time.Time
+}
+
+// Allow takes a token from the bucket identified by `key`, returning whether
+// one was available, how long to wait before retrying if not, and any error
+// encountered.
+func (limiter *RedisRateLimiter) Allow(key string, limit domain.RateLimit) (bool, time.Duration, error) {
+	now := limiter.now().UnixNano() / int64(time.Millisecond)
+
+	conn := limiter.pool.Get()
+	defer conn.Close()
+
+	result, err := redis.Values(tokenBucketScript.Do(
+		conn, fmt.Sprintf("ratelimit:%s", key), limit.Rate, limit.Burst, now))
+	if err != nil {
+		return false, 0, storeError("checking rate limit", err)
+	}
+
+	var allowed, wait int64
+	if _, err := redis.Scan(result, &allowed, &wait); err != nil {
+		return false, 0, storeError("checking rate limit", err)
+	}
+
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// NewRedisRateLimiter opens a pool of TCP connections to a redis server at
+// the given address and port. It returns an intialised RedisRateLimiter
+// struct as well as any error encountered.
+func NewRedisRateLimiter(addr, port string) (RedisRateLimiter, error) {
+	pool, err := newPool(addr, port)
+	if err != nil {
+		return RedisRateLimiter{}, err
+	}
+	return RedisRateLimiter{pool: pool, now: time.Now}, nil
+}
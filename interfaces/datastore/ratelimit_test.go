@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestRedisRateLimiter(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	now := time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)
+	limiter, _ := NewRedisRateLimiter("127.0.0.1", "12313")
+	limiter.now = func() time.Time { return now }
+	limit := domain.RateLimit{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if allowed, _, err := limiter.Allow("client", limit); !allowed || err != nil {
+			t.Errorf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	allowed, retryAfter, _ := limiter.Allow("client", limit)
+	if allowed {
+		t.Error("expected request to be rejected once the bucket is empty")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("expected retry after %v, got %v", 500*time.Millisecond, retryAfter)
+	}
+
+	if allowed, _, _ := limiter.Allow("other", limit); !allowed {
+		t.Error("expected request from another client to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if allowed, _, _ := limiter.Allow("client", limit); !allowed {
+		t.Error("expected request to be allowed after the bucket refills")
+	}
+}
+
+func TestRedisRateLimiterSharesState(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	first, _ := NewRedisRateLimiter("127.0.0.1", "12313")
+	second, _ := NewRedisRateLimiter("127.0.0.1", "12313")
+	limit := domain.RateLimit{Rate: 1, Burst: 1}
+
+	if allowed, _, _ := first.Allow("client", limit); !allowed {
+		t.Error("expected first request to be allowed")
+	}
+	if allowed, _, _ := second.Allow("client", limit); allowed {
+		t.Error("expected limit to be shared between limiters")
+	}
+}
+
+func TestRedisRateLimiterConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	limiter, _ := NewRedisRateLimiter("127.0.0.1", "12313")
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	if _, _, err := limiter.Allow("client", domain.RateLimit{Rate: 1, Burst: 1}); err == nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,360 @@
+package datastore
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// rollupUnits are the buckets, from finest to coarsest, in which events of
+// each name are counted as they are stored. Bucket N of a unit holds the
+// number of events with timestamps in [N*seconds, (N+1)*seconds).
+var rollupUnits = []struct {
+	name    string
+	seconds int64
+}{
+	{"minute", 60},
+	{"hour", 60 * 60},
+	{"day", 24 * 60 * 60},
+}
+
+// rollupNamesKey names the set of event names whose rollups hold every
+// stored event, and so may be used for counting.
+const rollupNamesKey = "rollup_names"
+
+// rollupBuildAttempts bounds how many times building the rollups of a name
+// is attempted when events of that name are stored concurrently.
+var rollupBuildAttempts = 3
+
+// rollupPageSize is the number of events read at a time while building
+// rollups.
+var rollupPageSize = 1000
+
+// countPlan describes how to count the events in a time range: the sum of
+// some rollup buckets of each unit, plus raw counts of the unaligned edges.
+type countPlan struct {
+	// raw holds inclusive ranges of timestamps to count from the index
+	raw [][2]int64
+
+	// buckets holds, for each of rollupUnits, the buckets to sum
+	buckets [][]int64
+}
+
+// planCount plans a count of the events with timestamps between `start` and
+// `end`, covering as much of the range as possible with the coarsest rollups
+// aligned within it.
+func planCount(start, end int64) countPlan {
+	plan := countPlan{buckets: make([][]int64, len(rollupUnits))}
+	if start <= end {
+		plan.cover(start, end+1, len(rollupUnits)-1)
+	}
+	return plan
+}
+
+// cover adds to the plan the half-open range of timestamps [lo, hi), using
+// buckets of rollup unit `level` or finer.
+func (plan *countPlan) cover(lo, hi int64, level int) {
+	if lo >= hi {
+		return
+	}
+	if level < 0 {
+		plan.raw = append(plan.raw, [2]int64{lo, hi - 1})
+		return
+	}
+
+	unit := rollupUnits[level].seconds
+	first, last := ceilDiv(lo, unit), floorDiv(hi, unit)
+	if first >= last {
+		plan.cover(lo, hi, level-1)
+		return
+	}
+
+	for bucket := first; bucket < last; bucket++ {
+		plan.buckets[level] = append(plan.buckets[level], bucket)
+	}
+	plan.cover(lo, first*unit, level-1)
+	plan.cover(last*unit, hi, level-1)
+}
+
+// spansRollup reports whether the time range between `start` and `end`
+// contains a whole bucket of the finest rollup unit.
+func spansRollup(start, end int64) bool {
+	unit := rollupUnits[0].seconds
+	return start <= end && ceilDiv(start, unit) < floorDiv(end+1, unit)
+}
+
+// countRanges counts the events named `name`, which must be sanitized, with
+// timestamps in each of `ranges`. If any range spans a rollup bucket and the
+// rollups of the name have been built, the ranges are first narrowed to the
+// timestamps of the first and last such events, so that the number of
+// buckets summed depends on the span of the stored events rather than that
+// of the ranges.
+func (store *RedisEventStore) countRanges(conn redis.Conn, name string, ranges [][2]int64) ([]int, error) {
+	index := store.key("events:%s:by-timestamp", name)
+	counts := make([]int, len(ranges))
+
+	spans := false
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, r := range ranges {
+		spans = spans || spansRollup(r[0], r[1])
+		if r[0] < lo {
+			lo = r[0]
+		}
+		if r[1] > hi {
+			hi = r[1]
+		}
+	}
+
+	built := false
+	if spans {
+		var err error
+		if built, err = redis.Bool(conn.Do("SISMEMBER", store.key(rollupNamesKey), name)); err != nil {
+			return nil, storeError("getting event count", err)
+		}
+	}
+	if built {
+		first, last, ok, err := storedExtent(conn, index, lo, hi)
+		if err != nil {
+			return nil, storeError("getting event count", err)
+		}
+		if !ok {
+			return counts, nil
+		}
+		lo, hi = first, last
+	}
+
+	plans := make([]countPlan, len(ranges))
+	for i, r := range ranges {
+		start, end := r[0], r[1]
+		if built {
+			if start < lo {
+				start = lo
+			}
+			if end > hi {
+				end = hi
+			}
+			plans[i] = planCount(start, end)
+		} else if start <= end {
+			plans[i] = countPlan{raw: [][2]int64{{start, end}}}
+		}
+	}
+
+	// counting in a transaction sees each stored event either wholly or not
+	// at all, in the index as well as in the rollups
+	conn.Send("MULTI")
+	for _, plan := range plans {
+		for _, r := range plan.raw {
+			conn.Send("ZCOUNT", index, r[0], r[1])
+		}
+		for level, buckets := range plan.buckets {
+			if len(buckets) == 0 {
+				continue
+			}
+			args := redis.Args{}.Add(store.key("events:%s:rollup:%s", name, rollupUnits[level].name))
+			conn.Send("HMGET", args.AddFlat(buckets)...)
+		}
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, storeError("getting event count", err)
+	}
+
+	for i, plan := range plans {
+		for range plan.raw {
+			n, err := redis.Int(replies[0], nil)
+			if err != nil {
+				return nil, storeError("getting event count", err)
+			}
+			counts[i] += n
+			replies = replies[1:]
+		}
+		for _, buckets := range plan.buckets {
+			if len(buckets) == 0 {
+				continue
+			}
+			values, err := redis.Ints(replies[0], nil)
+			if err != nil {
+				return nil, storeError("getting event count", err)
+			}
+			for _, n := range values {
+				counts[i] += n
+			}
+			replies = replies[1:]
+		}
+	}
+	return counts, nil
+}
+
+// BuildRollups builds the rollups of the names of the store's tenant whose
+// events were stored before rollups were introduced, returning the number
+// of names built. Until then, those names are counted from their events
+// alone. It reads every event of those names, and so is meant to be run
+// once, as a migration, rather than while serving requests.
+func (store *RedisEventStore) BuildRollups() (int, error) {
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("SMEMBERS", store.key("event_names"))
+	conn.Send("SMEMBERS", store.key(rollupNamesKey))
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, storeError("building rollups", err)
+	}
+	names, _ := redis.Strings(replies[0], nil)
+	built, _ := redis.Strings(replies[1], nil)
+
+	done := map[string]bool{}
+	for _, name := range built {
+		done[name] = true
+	}
+
+	count, busy := 0, 0
+	for _, name := range names {
+		name = sanitizeName(name)
+		if done[name] {
+			continue
+		}
+		done[name] = true
+
+		ok, err := store.buildRollups(conn, name)
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			busy++
+			continue
+		}
+		count++
+	}
+	if busy > 0 {
+		return count, fmt.Errorf("the rollups of %d names could not be built while their events were being stored; run the build again", busy)
+	}
+	return count, nil
+}
+
+// buildRollups counts the events already stored under `name`, which must be
+// sanitized, into its rollups, so that they may be used for counting from
+// now on. Events stored concurrently cause the build to be retried, up to
+// rollupBuildAttempts times; it reports whether the rollups were built. The
+// build watches the index on `conn`, which must not be shared with any other
+// goroutine, or the watch would guard their commands too.
+func (store *RedisEventStore) buildRollups(conn redis.Conn, name string) (bool, error) {
+	index := store.key("events:%s:by-timestamp", name)
+
+	for attempt := 0; attempt < rollupBuildAttempts; attempt++ {
+		if _, err := conn.Do("WATCH", index); err != nil {
+			return false, storeError("building rollups", err)
+		}
+
+		counts, err := countBuckets(conn, index)
+		if err != nil {
+			conn.Do("UNWATCH")
+			return false, storeError("building rollups", err)
+		}
+
+		conn.Send("MULTI")
+		for level, unit := range rollupUnits {
+			key := store.key("events:%s:rollup:%s", name, unit.name)
+			conn.Send("DEL", key)
+
+			args := redis.Args{}.Add(key)
+			for bucket, n := range counts[level] {
+				args = args.Add(bucket, n)
+				if len(args) > 2*rollupPageSize {
+					conn.Send("HMSET", args...)
+					args = redis.Args{}.Add(key)
+				}
+			}
+			if len(args) > 1 {
+				conn.Send("HMSET", args...)
+			}
+		}
+		conn.Send("SADD", store.key(rollupNamesKey), name)
+
+		reply, err := conn.Do("EXEC")
+		if err != nil {
+			return false, storeError("building rollups", err)
+		}
+		if reply != nil {
+			return true, nil
+		}
+		// an event was stored while counting, so count again
+	}
+	return false, nil
+}
+
+// countBuckets reads the timestamp of every event in `index`, returning the
+// number in each bucket of each of rollupUnits.
+func countBuckets(conn redis.Conn, index string) ([]map[int64]int, error) {
+	counts := make([]map[int64]int, len(rollupUnits))
+	for level := range counts {
+		counts[level] = map[int64]int{}
+	}
+
+	for offset := 0; ; offset += rollupPageSize {
+		values, err := redis.Strings(conn.Do(
+			"ZRANGE", index, offset, offset+rollupPageSize-1, "WITHSCORES"))
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 1; i < len(values); i += 2 {
+			timestamp, err := strconv.ParseInt(values[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			for level, unit := range rollupUnits {
+				counts[level][floorDiv(timestamp, unit.seconds)]++
+			}
+		}
+
+		if len(values) < 2*rollupPageSize {
+			return counts, nil
+		}
+	}
+}
+
+// storedExtent narrows the time range between `start` and `end` to the
+// timestamps of the first and last events in `index`, so that the number of
+// buckets visited depends on the span of the stored events rather than that
+// of the range. It reports false if the index is empty.
+func storedExtent(conn redis.Conn, index string, start, end int64) (int64, int64, bool, error) {
+	conn.Send("MULTI")
+	conn.Send("ZRANGE", index, 0, 0, "WITHSCORES")
+	conn.Send("ZREVRANGE", index, 0, 0, "WITHSCORES")
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	first, _ := redis.Strings(replies[0], nil)
+	last, _ := redis.Strings(replies[1], nil)
+	if len(first) < 2 || len(last) < 2 {
+		return 0, 0, false, nil
+	}
+	if min, err := strconv.ParseInt(first[1], 10, 64); err == nil && min > start {
+		start = min
+	}
+	if max, err := strconv.ParseInt(last[1], 10, 64); err == nil && max < end {
+		end = max
+	}
+	return start, end, true, nil
+}
+
+// floorDiv and ceilDiv divide rounding towards negative and positive
+// infinity respectively, so that timestamps before the epoch fall into the
+// right buckets.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func ceilDiv(a, b int64) int64 {
+	return -floorDiv(-a, b)
+}
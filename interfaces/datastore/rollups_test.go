@@ -0,0 +1,171 @@
+package datastore
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestPlanCount(t *testing.T) {
+	// 2015-02-11T15:01:30Z to 2015-02-13T01:00:29Z
+	plan := planCount(1423666890, 1423789229)
+
+	expected := []int{
+		58,    // minutes from 15:02 to 16:00 on the 11th
+		8 + 1, // hours to midnight on the 11th, and to 01:00 on the 13th
+		1,     // the whole of the 12th
+	}
+	for level, n := range expected {
+		if len(plan.buckets[level]) != n {
+			t.Errorf("expected %d %s buckets, got %v", n, rollupUnits[level].name, plan.buckets[level])
+		}
+	}
+	if len(plan.raw) != 2 || plan.raw[0] != [2]int64{1423666890, 1423666919} || plan.raw[1] != [2]int64{1423789200, 1423789229} {
+		t.Errorf("expected raw counts of the unaligned edges, got %v", plan.raw)
+	}
+}
+
+func TestPlanCountCoversRangeExactly(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		start := random.Int63n(1<<31) - 1<<30
+		end := start + random.Int63n(3*86400)
+
+		covered := map[int64]int{}
+		plan := planCount(start, end)
+		for _, r := range plan.raw {
+			if r[1]-r[0] >= rollupUnits[0].seconds {
+				t.Fatalf("raw range %v of [%d, %d] spans a whole minute", r, start, end)
+			}
+			covered[r[0]]++
+			covered[r[1]+1]--
+		}
+		for level, buckets := range plan.buckets {
+			unit := rollupUnits[level].seconds
+			for _, bucket := range buckets {
+				covered[bucket*unit]++
+				covered[(bucket+1)*unit]--
+			}
+		}
+
+		// every second in the range is covered exactly once
+		depth := 0
+		for second := start; second <= end+1; second++ {
+			depth += covered[second]
+			if (second <= end && depth != 1) || (second > end && depth != 0) {
+				t.Fatalf("plan of [%d, %d] covers %d %d times", start, end, second, depth)
+			}
+		}
+	}
+}
+
+func TestSpansRollup(t *testing.T) {
+	cases := []struct {
+		start, end int64
+		spans      bool
+	}{
+		{1423666860, 1423666919, true},
+		{1423666861, 1423666920, false},
+		{1423666861, 1423666980, true},
+		{-60, -1, true},
+		{1423666920, 1423666860, false},
+	}
+	for _, c := range cases {
+		if spansRollup(c.start, c.end) != c.spans {
+			t.Errorf("expected [%d, %d] spanning a minute to be %t", c.start, c.end, c.spans)
+		}
+	}
+}
+
+func TestCountInTimeRangeMatchesRawCounts(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	random := rand.New(rand.NewSource(1))
+	base := int64(1423612800)
+	for i := 0; i < 2000; i++ {
+		store.Put(domain.Event{Name: "test", Timestamp: base + random.Int63n(5*86400)})
+	}
+
+	for i := 0; i < 200; i++ {
+		start := base - 3600 + random.Int63n(6*86400)
+		end := start + random.Int63n(3*86400)
+
+		raw, _ := redis.Int(conn.Do("ZCOUNT", "events:test:by-timestamp", start, end))
+		if count, err := store.CountInTimeRange("test", start, end); err != nil || count != raw {
+			t.Fatalf("expected %d events in [%d, %d], got %d, %v", raw, start, end, count, err)
+		}
+	}
+
+	if built, _ := redis.Bool(conn.Do("SISMEMBER", "rollup_names", "test")); !built {
+		t.Error("expected rollups to be used for counting")
+	}
+}
+
+func TestCountInTimeRangesMatchesSingleCounts(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	random := rand.New(rand.NewSource(1))
+	base := int64(1423612800)
+	for i := 0; i < 500; i++ {
+		store.Put(domain.Event{Name: "test", Timestamp: base + random.Int63n(2*86400)})
+	}
+
+	ranges := [][2]int64{{base - 86400, base - 1}, {base + 90, base + 3599}, {base + 30, base + 40}, {base + 3600, base + 2*86400}}
+	counts, err := store.CountInTimeRanges("test", ranges)
+	if err != nil || len(counts) != len(ranges) {
+		t.Fatalf("expected %d counts, got %v, %v", len(ranges), counts, err)
+	}
+	for i, r := range ranges {
+		if count, _ := store.CountInTimeRange("test", r[0], r[1]); counts[i] != count {
+			t.Errorf("expected %d events in [%d, %d], got %d", count, r[0], r[1], counts[i])
+		}
+	}
+}
+
+func TestBuildRollupsOfExistingEvents(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	conn, _ := redis.Dial("tcp", "127.0.0.1:12313")
+	defer conn.Close()
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	for _, timestamp := range []int64{1423666860, 1423666861, 1423670400, 1423756800} {
+		store.Put(domain.Event{Name: "test", Timestamp: timestamp})
+	}
+	store.Put(domain.Event{Name: "other", Timestamp: 1423666860})
+
+	// simulate events stored before rollups were introduced
+	conn.Do("DEL", "events:test:rollup:minute", "events:test:rollup:hour", "events:test:rollup:day")
+	conn.Do("SREM", "rollup_names", "test")
+
+	// names without rollups are counted from their events
+	if count, err := store.CountInTimeRange("test", 1423612800, 1423785600); err != nil || count != 4 {
+		t.Errorf("expected %d events, got %d, %v", 4, count, err)
+	}
+	if days, _ := redis.Int(conn.Do("HGET", "events:test:rollup:day", 16477)); days != 0 {
+		t.Errorf("expected counting not to build rollups, got %d events on 2015-02-11", days)
+	}
+
+	if built, err := store.BuildRollups(); err != nil || built != 1 {
+		t.Errorf("expected the rollups of 1 name to be built, got %d, %v", built, err)
+	}
+	if days, _ := redis.Int(conn.Do("HGET", "events:test:rollup:day", 16477)); days != 3 {
+		t.Errorf("expected rollups to be rebuilt, got %d events on 2015-02-11", days)
+	}
+
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666862})
+	if count, _ := store.CountInTimeRange("test", 1423612800, 1423785600); count != 5 {
+		t.Errorf("expected %d events, got %d", 5, count)
+	}
+}
@@ -0,0 +1,294 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// streamChannel is the redis channel, relative to a store's tenant, on which
+// each stored event is published.
+const streamChannel = "events:stream"
+
+// subscriberBuffer is the number of published events held for a subscriber
+// which has not yet received them. A subscriber which falls further behind
+// is disconnected, and may resume from the last event it received. While a
+// subscriber is replaying stored events, those published which do not fit
+// are dropped instead, and replayed once it reaches them.
+var subscriberBuffer = 256
+
+// errSlowSubscriber is returned by Next when a subscriber has fallen too far
+// behind the events being published.
+var errSlowSubscriber = errors.New("subscriber fell too far behind")
+
+// streamMessage is the message published for each stored event.
+type streamMessage struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Subscribe returns a Subscription to events stored from now on by any
+// instance of the service sharing this redis server. If `afterID` is
+// non-zero, events already stored with a greater ID are delivered first.
+func (store *RedisEventStore) Subscribe(afterID int64) (domain.Subscription, error) {
+	if store.hub == nil {
+		return nil, storeError("subscribing to events", errors.New("store has no address to subscribe with"))
+	}
+
+	sub := &redisSubscription{
+		store:     store,
+		channel:   store.key(streamChannel),
+		lastID:    afterID,
+		replaying: afterID > 0,
+		live:      make(chan domain.StoredEvent, subscriberBuffer),
+		failed:    make(chan struct{}),
+	}
+
+	// register for live events before replaying stored ones, so that no
+	// event is missed in between
+	if err := store.hub.add(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// redisSubscription delivers any stored events being replayed, followed by
+// events received live from the hub.
+type redisSubscription struct {
+	store   *RedisEventStore
+	channel string
+
+	// lastID is the ID of the last event replayed
+	lastID int64
+	replay []domain.StoredEvent
+
+	// replaying is set until the replay has caught up with the events
+	// published, and missed when a published event was dropped meanwhile;
+	// both are guarded by the hub
+	replaying bool
+	missed    bool
+
+	live      chan domain.StoredEvent
+	failed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func (sub *redisSubscription) Next(ctx context.Context) (domain.StoredEvent, error) {
+	for sub.replaying && len(sub.replay) == 0 {
+		more, err := sub.fetchReplay()
+		if err != nil {
+			return domain.StoredEvent{}, err
+		}
+		if !more {
+			sub.store.hub.endReplay(sub)
+		}
+	}
+	if len(sub.replay) > 0 {
+		event := sub.replay[0]
+		sub.replay = sub.replay[1:]
+		sub.lastID = event.ID
+		return event, nil
+	}
+
+	for {
+		select {
+		case event := <-sub.live:
+			// events stored while replaying are received both ways
+			if event.ID <= sub.lastID {
+				continue
+			}
+			return event, nil
+		case <-sub.failed:
+			return domain.StoredEvent{}, sub.err
+		case <-ctx.Done():
+			return domain.StoredEvent{}, ctx.Err()
+		}
+	}
+}
+
+// fetchReplay fetches the next page of stored events with IDs greater than
+// the last replayed, reporting whether there may be more.
+func (sub *redisSubscription) fetchReplay() (bool, error) {
+	store := sub.store
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Strings(conn.Do(
+		"ZRANGEBYSCORE", store.key("events:by-id"), "("+strconv.FormatInt(sub.lastID, 10), "+inf",
+		"WITHSCORES", "LIMIT", 0, scanPageSize))
+	if err != nil {
+		return false, storeError("replaying events", err)
+	}
+
+	// fetch the fields of the whole page in a single round trip
+	conn.Send("MULTI")
+	for i := 0; i+1 < len(values); i += 2 {
+		conn.Send("HMGET", values[i], "name", "timestamp")
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return false, storeError("replaying events", err)
+	}
+
+	for i := 0; i+1 < len(values); i += 2 {
+		fields, err := redis.Values(replies[i/2], nil)
+		if err != nil {
+			return false, storeError("replaying events", err)
+		}
+
+		var event domain.StoredEvent
+		if _, err := redis.Scan(fields, &event.Name, &event.Timestamp); err != nil {
+			return false, storeError("replaying events", err)
+		}
+		if event.ID, err = strconv.ParseInt(values[i+1], 10, 64); err != nil {
+			return false, storeError("replaying events", err)
+		}
+		sub.replay = append(sub.replay, event)
+	}
+
+	return len(values) == 2*scanPageSize, nil
+}
+
+func (sub *redisSubscription) Close() error {
+	sub.store.hub.remove(sub)
+	return nil
+}
+
+// fail breaks the subscription, causing Next to return `err`.
+func (sub *redisSubscription) fail(err error) {
+	sub.closeOnce.Do(func() {
+		sub.err = err
+		close(sub.failed)
+	})
+}
+
+// eventHub receives the events published to every tenant over a single
+// redis connection, which it opens when the first subscriber is added, and
+// passes each event to the subscribers to its channel.
+type eventHub struct {
+	addr string
+	port string
+
+	mu          sync.Mutex
+	conn        *redis.PubSubConn
+	subscribers map[string]map[*redisSubscription]bool
+}
+
+func newEventHub(addr, port string) *eventHub {
+	return &eventHub{addr: addr, port: port, subscribers: map[string]map[*redisSubscription]bool{}}
+}
+
+func (hub *eventHub) add(sub *redisSubscription) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.conn == nil {
+		conn, err := dialSubscriber(hub.addr, hub.port)
+		if err != nil {
+			return err
+		}
+		psc := &redis.PubSubConn{Conn: conn}
+		if err := psc.PSubscribe("*" + streamChannel); err != nil {
+			conn.Close()
+			return storeError("subscribing to events", err)
+		}
+		hub.conn = psc
+		go hub.receive(psc)
+	}
+
+	if hub.subscribers[sub.channel] == nil {
+		hub.subscribers[sub.channel] = map[*redisSubscription]bool{}
+	}
+	hub.subscribers[sub.channel][sub] = true
+	return nil
+}
+
+func (hub *eventHub) remove(sub *redisSubscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	delete(hub.subscribers[sub.channel], sub)
+	if len(hub.subscribers[sub.channel]) == 0 {
+		delete(hub.subscribers, sub.channel)
+	}
+}
+
+func (hub *eventHub) receive(psc *redis.PubSubConn) {
+	for {
+		switch message := psc.Receive().(type) {
+		case redis.PMessage:
+			var published streamMessage
+			if err := json.Unmarshal(message.Data, &published); err != nil {
+				continue
+			}
+			hub.dispatch(message.Channel, domain.StoredEvent{
+				ID:    published.ID,
+				Event: domain.Event{Name: published.Name, Timestamp: published.Timestamp},
+			})
+		case error:
+			hub.fail(psc, storeError("receiving events", message))
+			return
+		}
+	}
+}
+
+// dispatch passes `event` to each subscriber to `channel`, disconnecting
+// any whose buffer is full rather than waiting for it, unless it is still
+// replaying and so will fetch the event itself.
+func (hub *eventHub) dispatch(channel string, event domain.StoredEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for sub := range hub.subscribers[channel] {
+		select {
+		case sub.live <- event:
+		default:
+			if sub.replaying {
+				sub.missed = true
+				continue
+			}
+			sub.fail(errSlowSubscriber)
+			delete(hub.subscribers[channel], sub)
+		}
+	}
+}
+
+// endReplay ends the replay of `sub`, which has fetched every stored event,
+// unless events published meanwhile were dropped, which are yet to be
+// fetched.
+func (hub *eventHub) endReplay(sub *redisSubscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if sub.missed {
+		sub.missed = false
+		return
+	}
+	sub.replaying = false
+}
+
+// fail breaks every subscription after the connection `psc` is lost, so that
+// the next subscriber added opens a new connection.
+func (hub *eventHub) fail(psc *redis.PubSubConn, err error) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	psc.Close()
+	if hub.conn == psc {
+		hub.conn = nil
+	}
+	for channel, subs := range hub.subscribers {
+		for sub := range subs {
+			sub.fail(err)
+		}
+		delete(hub.subscribers, channel)
+	}
+}
@@ -0,0 +1,168 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func next(t *testing.T, sub domain.Subscription) domain.StoredEvent {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	event, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error waiting for event: %s", err)
+	}
+	return event
+}
+
+func TestSubscribeReceivesNewEvents(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	// events are published by one instance of the service and received by another
+	publisher, _ := NewRedisEventStore("127.0.0.1", "12313")
+	subscriber, _ := NewRedisEventStore("127.0.0.1", "12313")
+
+	publisher.ForTenant("acme").Put(domain.Event{Name: "before", Timestamp: 1423666860})
+
+	sub, err := subscriber.ForTenant("acme").(domain.EventSubscriber).Subscribe(0)
+	if err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+	defer sub.Close()
+
+	publisher.ForTenant("other").Put(domain.Event{Name: "other", Timestamp: 1423666860})
+	publisher.ForTenant("acme").Put(domain.Event{Name: "after", Timestamp: 1423666861})
+
+	expected := domain.StoredEvent{ID: 2, Event: domain.Event{Name: "after", Timestamp: 1423666861}}
+	if event := next(t, sub); event != expected {
+		t.Errorf("expected %+v, got %+v", expected, event)
+	}
+}
+
+func TestSubscribeReplaysFromID(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	defer func(size int) { scanPageSize = size }(scanPageSize)
+	scanPageSize = 2
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	for i := int64(0); i < 5; i++ {
+		store.Put(domain.Event{Name: "test", Timestamp: 1423666860 + i})
+	}
+
+	sub, err := store.Subscribe(2)
+	if err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+	defer sub.Close()
+
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666870})
+
+	for _, id := range []int64{3, 4, 5, 6} {
+		if event := next(t, sub); event.ID != id {
+			t.Errorf("expected event %d, got %+v", id, event)
+		}
+	}
+}
+
+func TestSubscribeReplaysBacklogLargerThanBuffer(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	backlog := int64(subscriberBuffer + 44)
+	for i := int64(0); i < backlog; i++ {
+		store.Put(domain.Event{Name: "test", Timestamp: 1423666860 + i})
+	}
+
+	sub, err := store.Subscribe(1)
+	if err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+	defer sub.Close()
+
+	// more events are published during the replay than the buffer holds
+	for i := 0; i < subscriberBuffer+10; i++ {
+		store.Put(domain.Event{Name: "test", Timestamp: 1423666960})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for id := int64(2); id <= backlog+int64(subscriberBuffer+10); id++ {
+		if event := next(t, sub); event.ID != id {
+			t.Fatalf("expected event %d, got %+v", id, event)
+		}
+	}
+}
+
+func TestSubscribeReceivesConcurrentEventsInOrder(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	sub, _ := store.Subscribe(0)
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				store.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := int64(1); id <= 100; id++ {
+		if event := next(t, sub); event.ID != id {
+			t.Fatalf("expected event %d, got %+v", id, event)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	defer func(size int) { subscriberBuffer = size }(subscriberBuffer)
+	subscriberBuffer = 1
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	sub, _ := store.Subscribe(0)
+	defer sub.Close()
+
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666860})
+	store.Put(domain.Event{Name: "test", Timestamp: 1423666861})
+
+	// the first event was buffered, the second overflowed the buffer
+	time.Sleep(50 * time.Millisecond)
+	next(t, sub)
+	if _, err := sub.Next(context.Background()); err != errSlowSubscriber {
+		t.Errorf("expected errSlowSubscriber, got %v", err)
+	}
+}
+
+func TestSubscribeConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	sub, _ := store.Subscribe(0)
+	defer sub.Close()
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := sub.Next(ctx); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
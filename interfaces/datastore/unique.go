@@ -0,0 +1,78 @@
+package datastore
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// actorsKey names the HyperLogLog of the actors of events named `name`,
+// which must be sanitized, in bucket `bucket` of rollup unit `unit`.
+func (store *RedisEventStore) actorsKey(name, unit string, bucket int64) string {
+	return store.key("events:%s:actors:%s:%d", name, unit, bucket)
+}
+
+// uniqueScript estimates the number of distinct actors in the union of the
+// HyperLogLogs KEYS[3] onwards and of the events in the index KEYS[2] with
+// timestamps in the inclusive ranges given by pairs of ARGV. The union is
+// built in KEYS[1], which is deleted before the script returns, so it is
+// never seen by other clients. Sources are merged in chunks, since Lua
+// cannot unpack an unbounded number of values at once.
+var uniqueScript = redis.NewScript(-1, `
+local union = KEYS[1]
+redis.call("DEL", union)
+for i = 3, #KEYS, 1000 do
+	redis.call("PFMERGE", union, unpack(KEYS, i, math.min(i + 999, #KEYS)))
+end
+for i = 1, #ARGV, 2 do
+	for _, key in ipairs(redis.call("ZRANGEBYSCORE", KEYS[2], ARGV[i], ARGV[i + 1])) do
+		local actor = redis.call("HGET", key, "actor")
+		if actor then
+			redis.call("PFADD", union, actor)
+		end
+	end
+end
+local count = redis.call("PFCOUNT", union)
+redis.call("DEL", union)
+return count
+`)
+
+// CountUniqueInTimeRange returns an estimate of the number of distinct
+// actors of the events with a given name and timestamp between `start` and
+// `end`, as well as any error encountered. As each event with an actor is
+// stored, its actor is added to a HyperLogLog for each rollup bucket it
+// falls in. The estimate merges the coarsest buckets which fit within the
+// range, narrowed to the timestamps of the first and last such events as
+// counting is, with the exact actors of the events at its unaligned edges.
+func (store *RedisEventStore) CountUniqueInTimeRange(name string, start, end int64) (int, error) {
+	name = sanitizeName(name)
+	index := store.key("events:%s:by-timestamp", name)
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	start, end, ok, err := storedExtent(conn, index, start, end)
+	if err != nil {
+		return 0, storeError("getting unique actor count", err)
+	}
+	if !ok {
+		return 0, nil
+	}
+	plan := planCount(start, end)
+
+	keys := []interface{}{store.key("events:%s:actors:union", name), index}
+	for level, buckets := range plan.buckets {
+		for _, bucket := range buckets {
+			keys = append(keys, store.actorsKey(name, rollupUnits[level].name, bucket))
+		}
+	}
+
+	args := append([]interface{}{len(keys)}, keys...)
+	for _, r := range plan.raw {
+		args = append(args, r[0], r[1])
+	}
+
+	count, err := redis.Int(uniqueScript.Do(conn, args...))
+	if err != nil {
+		return 0, storeError("getting unique actor count", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestCountUniqueInTimeRange(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	events := []domain.Event{
+		// 2015-02-11T15:01:00Z, then across the following hours
+		{Name: "login", Timestamp: 1423666860, Actor: "alice"},
+		{Name: "login", Timestamp: 1423666890, Actor: "bob"},
+		{Name: "login", Timestamp: 1423670400, Actor: "alice"},
+		{Name: "login", Timestamp: 1423674000, Actor: "carol"},
+		{Name: "login", Timestamp: 1423674030},
+		{Name: "login", Timestamp: 1423674059, Actor: "dave"},
+		{Name: "logout", Timestamp: 1423666860, Actor: "erin"},
+	}
+	for _, event := range events {
+		if err := store.Put(event); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	cases := []struct {
+		start, end int64
+		expected   int
+	}{
+		{1423666860, 1423666919, 2}, // a whole minute
+		{1423666870, 1423670400, 2}, // minutes with unaligned edges
+		{1423666860, 1423674059, 4}, // minutes and an hour
+		{1423666800, 1423699199, 4}, // the rest of the day
+		{0, 1 << 40, 4},
+		{1423674060, 1423680000, 0},
+	}
+	for _, c := range cases {
+		count, err := store.CountUniqueInTimeRange("login", c.start, c.end)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if count != c.expected {
+			t.Errorf("expected %d unique actors in [%d, %d], got %d", c.expected, c.start, c.end, count)
+		}
+	}
+
+	if count, _ := store.CountUniqueInTimeRange("unknown", 0, 1<<40); count != 0 {
+		t.Errorf("expected no actors of unknown events, got %d", count)
+	}
+}
+
+func TestCountUniqueInTimeRangeConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	if _, err := store.CountUniqueInTimeRange("login", 1423666860, 1423666870); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package datastore
 import (
 	"log"
 
+	"github.com/garyburd/redigo/redis"
 	"github.com/stvp/tempredis"
 )
 
@@ -25,6 +26,16 @@ func stopRedis(server *tempredis.Server) {
 	}
 }
 
+// testPool returns a pool of connections to the redis server started for
+// tests.
+func testPool() *redis.Pool {
+	pool, err := newPool("127.0.0.1", "12313")
+	if err != nil {
+		log.Fatal("Unable to connect to tempredis for test")
+	}
+	return pool
+}
+
 func stringInSlice(value string, slice []string) bool {
 	for _, item := range slice {
 		if item == value {
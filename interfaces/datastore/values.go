@@ -0,0 +1,189 @@
+package datastore
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// valuesKey names the hash holding a sketch of the values of events named
+// `name`, which must be sanitized, in bucket `bucket` of rollup unit `unit`.
+// Its fields hold the count, sum, min and max of the values, and the number
+// of values in each bin of the sketch, under binField.
+func (store *RedisEventStore) valuesKey(name, unit string, bucket int64) string {
+	return store.key("events:%s:values:%s:%d", name, unit, bucket)
+}
+
+// binField names the field of a values hash counting the bin of `value`.
+func binField(value float64) string {
+	switch sign, index := domain.SketchBin(value); sign {
+	case 1:
+		return "p:" + strconv.Itoa(index)
+	case -1:
+		return "n:" + strconv.Itoa(index)
+	}
+	return "z"
+}
+
+// addValueScript adds the value ARGV[1], in bin field ARGV[2], to the values
+// hash of every key. Redis cannot otherwise keep a minimum and maximum
+// within a transaction.
+var addValueScript = redis.NewScript(-1, `
+local value = tonumber(ARGV[1])
+for _, key in ipairs(KEYS) do
+	local count = redis.call("HINCRBY", key, "count", 1)
+	redis.call("HINCRBYFLOAT", key, "sum", ARGV[1])
+	if count == 1 or value < tonumber(redis.call("HGET", key, "min")) then
+		redis.call("HSET", key, "min", ARGV[1])
+	end
+	if count == 1 or value > tonumber(redis.call("HGET", key, "max")) then
+		redis.call("HSET", key, "max", ARGV[1])
+	end
+	redis.call("HINCRBY", key, ARGV[2], 1)
+end
+`)
+
+// sendValue queues the commands which add the value of `event`, named
+// `name` once sanitized, to the values hashes of the buckets it falls in.
+func (store *RedisEventStore) sendValue(conn redis.Conn, name string, event domain.Event) {
+	args := redis.Args{len(rollupUnits)}
+	for _, unit := range rollupUnits {
+		args = args.Add(store.valuesKey(name, unit.name, floorDiv(event.Timestamp, unit.seconds)))
+	}
+	args = args.Add(formatValue(*event.Value), binField(*event.Value))
+	addValueScript.Send(conn, args...)
+}
+
+// SummarizeInTimeRange returns a Sketch of the values of events with a given
+// name and timestamp between `start` and `end`, as well as any error
+// encountered. The sketches of the coarsest buckets which fit within the
+// range, narrowed to the timestamps of the first and last such events as
+// counting is, are merged with the exact values of the events at its
+// unaligned edges.
+func (store *RedisEventStore) SummarizeInTimeRange(name string, start, end int64) (*domain.Sketch, error) {
+	name = sanitizeName(name)
+	index := store.key("events:%s:by-timestamp", name)
+	sketch := domain.NewSketch()
+
+	conn := store.pool.Get()
+	defer conn.Close()
+
+	start, end, ok, err := storedExtent(conn, index, start, end)
+	if err != nil {
+		return nil, storeError("summarizing values", err)
+	}
+	if !ok {
+		return sketch, nil
+	}
+	plan := planCount(start, end)
+
+	conn.Send("MULTI")
+	for _, r := range plan.raw {
+		conn.Send("ZRANGEBYSCORE", index, r[0], r[1])
+	}
+	for level, buckets := range plan.buckets {
+		for _, bucket := range buckets {
+			conn.Send("HGETALL", store.valuesKey(name, rollupUnits[level].name, bucket))
+		}
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, storeError("summarizing values", err)
+	}
+
+	var edges []string
+	for _, reply := range replies[:len(plan.raw)] {
+		keys, err := redis.Strings(reply, nil)
+		if err != nil {
+			return nil, storeError("summarizing values", err)
+		}
+		edges = append(edges, keys...)
+	}
+	for _, reply := range replies[len(plan.raw):] {
+		fields, err := redis.StringMap(reply, nil)
+		if err != nil {
+			return nil, storeError("summarizing values", err)
+		}
+		if err := mergeValues(sketch, fields); err != nil {
+			return nil, storeError("summarizing values", err)
+		}
+	}
+
+	if len(edges) == 0 {
+		return sketch, nil
+	}
+	conn.Send("MULTI")
+	for _, key := range edges {
+		conn.Send("HGET", key, "value")
+	}
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, storeError("summarizing values", err)
+	}
+	for _, reply := range values {
+		if reply == nil {
+			continue
+		}
+		value, err := redis.Float64(reply, nil)
+		if err != nil {
+			return nil, storeError("summarizing values", err)
+		}
+		sketch.Add(value)
+	}
+	return sketch, nil
+}
+
+// mergeValues merges the sketch held in the fields of a values hash into
+// `sketch`.
+func mergeValues(sketch *domain.Sketch, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	stats := map[string]float64{}
+	for _, field := range []string{"count", "sum", "min", "max"} {
+		value, err := strconv.ParseFloat(fields[field], 64)
+		if err != nil {
+			return err
+		}
+		stats[field] = value
+	}
+
+	part := domain.NewSketch()
+	part.AddStats(int64(stats["count"]), stats["sum"], stats["min"], stats["max"])
+	for field, value := range fields {
+		var bins map[int]int64
+		switch {
+		case field == "z":
+		case strings.HasPrefix(field, "p:"):
+			bins = part.Positive
+		case strings.HasPrefix(field, "n:"):
+			bins = part.Negative
+		default:
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if bins == nil {
+			part.Zero += n
+			continue
+		}
+		index, err := strconv.Atoi(field[2:])
+		if err != nil {
+			return err
+		}
+		bins[index] += n
+	}
+	sketch.Merge(part)
+	return nil
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
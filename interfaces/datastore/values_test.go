@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestSummarizeInTimeRange(t *testing.T) {
+	server := startRedis("12313")
+	defer stopRedis(server)
+
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+	value := func(v float64) *float64 { return &v }
+	events := []domain.Event{
+		// 2015-02-11T15:01:00Z, then across the following hours
+		{Name: "checkout", Timestamp: 1423666860, Value: value(10)},
+		{Name: "checkout", Timestamp: 1423666890, Value: value(-2.5)},
+		{Name: "checkout", Timestamp: 1423670400, Value: value(0)},
+		{Name: "checkout", Timestamp: 1423674000, Value: value(40)},
+		{Name: "checkout", Timestamp: 1423674030},
+		{Name: "checkout", Timestamp: 1423674059, Value: value(0.125)},
+		{Name: "refund", Timestamp: 1423666860, Value: value(1000)},
+	}
+	for _, event := range events {
+		if err := store.Put(event); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	cases := []struct {
+		start, end    int64
+		count         int64
+		sum, min, max float64
+	}{
+		{1423666860, 1423666919, 2, 7.5, -2.5, 10},    // a whole minute
+		{1423666870, 1423670400, 2, -2.5, -2.5, 0},    // minutes with unaligned edges
+		{1423666860, 1423674059, 5, 47.625, -2.5, 40}, // minutes and an hour
+		{1423666800, 1423699199, 5, 47.625, -2.5, 40}, // the rest of the day
+		{0, 1 << 40, 5, 47.625, -2.5, 40},             // narrowed to the stored events
+		{1423674060, 1423680000, 0, 0, 0, 0},          // after the last event
+	}
+	for _, c := range cases {
+		sketch, err := store.SummarizeInTimeRange("checkout", c.start, c.end)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if sketch.Count != c.count || sketch.Sum != c.sum || sketch.Min != c.min || sketch.Max != c.max {
+			t.Errorf("expected count %d, sum %v, min %v and max %v in [%d, %d], got %+v",
+				c.count, c.sum, c.min, c.max, c.start, c.end, sketch)
+		}
+	}
+
+	sketch, _ := store.SummarizeInTimeRange("checkout", 1423666800, 1423699199)
+	if median := sketch.Quantile(0.5); math.Abs(median-0.125) > domain.SketchAccuracy*0.125 {
+		t.Errorf("expected a median of about 0.125, got %v", median)
+	}
+}
+
+func TestSummarizeInTimeRangeConnectionError(t *testing.T) {
+	server := startRedis("12313")
+	store, _ := NewRedisEventStore("127.0.0.1", "12313")
+
+	// simulate redis connection loss
+	stopRedis(server)
+
+	if _, err := store.SummarizeInTimeRange("checkout", 1423666860, 1423666870); !errors.Is(err, domain.ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	expected := domain.NewSketch()
+	for _, v := range []float64{3, -1, 0, 3} {
+		expected.Add(v)
+	}
+
+	fields := map[string]string{"count": "4", "sum": "5", "min": "-1", "max": "3"}
+	for _, v := range []float64{3, -1, 0, 3} {
+		n, _ := strconv.Atoi(fields[binField(v)])
+		fields[binField(v)] = strconv.Itoa(n + 1)
+	}
+
+	sketch := domain.NewSketch()
+	if err := mergeValues(sketch, fields); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(sketch, expected) {
+		t.Errorf("expected %+v, got %+v", expected, sketch)
+	}
+}
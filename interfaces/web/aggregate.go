@@ -0,0 +1,52 @@
+package web
+
+import "net/http"
+
+// AggregateResource describes the result of applying the function Fn to the
+// values of Count events named Name. Value is null if no events had values,
+// other than for sums, which are zero.
+type AggregateResource struct {
+	Name  string   `json:"name"`
+	Fn    string   `json:"fn"`
+	Count int64    `json:"count"`
+	Value *float64 `json:"value"`
+}
+
+// Aggregate applies an aggregate function, such as a sum or a percentile,
+// to the values of the events with a given name in a time range.
+func (service *WebService) Aggregate(res http.ResponseWriter, req *http.Request) {
+	name := req.FormValue("name")
+	if name == "" {
+		service.renderMissingParameter(res, req, "name")
+		return
+	}
+
+	fn := req.FormValue("fn")
+	if fn == "" {
+		service.renderMissingParameter(res, req, "fn")
+		return
+	}
+
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	aggregate, err := service.EventInteractor.AggregateValues(tenant, name, timeRange, fn)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	service.RenderJSON(res, AggregateResource{
+		Name:  name,
+		Fn:    fn,
+		Count: aggregate.Count,
+		Value: aggregate.Value,
+	}, http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const aggregateURL = "http://example.com/v1/events/aggregate?from=2015-02-11T15:01:00Z&to=2015-02-11T15:01:59Z"
+
+func serveAggregate(t *testing.T, query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", aggregateURL+query, nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var body map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	return response, body
+}
+
+func TestAggregate(t *testing.T) {
+	response, body := serveAggregate(t, "&name=checkout&fn=p95")
+
+	if response.Code != http.StatusOK || body["fn"] != "p95" || body["count"] != 7.0 || body["value"] != 42.5 {
+		t.Errorf("expected the p95 of 7 values, got %d %s", response.Code, response.Body)
+	}
+}
+
+func TestAggregateWithoutValues(t *testing.T) {
+	response, body := serveAggregate(t, "&name=unknown&fn=max")
+
+	if value, ok := body["value"]; response.Code != http.StatusOK || !ok || value != nil {
+		t.Errorf("expected a null value, got %d %s", response.Code, response.Body)
+	}
+}
+
+func TestAggregateRequiresFunction(t *testing.T) {
+	response, _ := serveAggregate(t, "&name=checkout")
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "fn" {
+		t.Errorf("expected fn to be required, got %d %s", response.Code, response.Body)
+	}
+}
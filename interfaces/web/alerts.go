@@ -0,0 +1,183 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+type AlertInteractor interface {
+	CreateRule(tenant string, options usecases.AlertRuleOptions) (domain.AlertRule, error)
+	GetRule(tenant, id string) (domain.AlertRule, error)
+	ListRules(tenant string) ([]domain.AlertRule, error)
+	UpdateRule(tenant, id string, options usecases.AlertRuleOptions) (domain.AlertRule, error)
+	DeleteRule(tenant, id string) error
+}
+
+type AlertRuleRequestResource struct {
+	EventName  string `json:"event_name"`
+	Threshold  *int   `json:"threshold"`
+	Window     string `json:"window"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// AlertRuleResource describes a rule. Its Secret, with which notifications
+// are signed, is only included when the rule is created.
+type AlertRuleResource struct {
+	ID         string `json:"id"`
+	EventName  string `json:"event_name"`
+	Threshold  int    `json:"threshold"`
+	Window     string `json:"window"`
+	WebhookURL string `json:"webhook_url"`
+	Firing     bool   `json:"firing"`
+	Secret     string `json:"secret,omitempty"`
+}
+
+type AlertRuleListResource struct {
+	Rules []AlertRuleResource `json:"rules"`
+}
+
+func newAlertRuleResource(rule domain.AlertRule) AlertRuleResource {
+	return AlertRuleResource{
+		ID:         rule.ID,
+		EventName:  rule.EventName,
+		Threshold:  rule.Threshold,
+		Window:     (time.Duration(rule.Window) * time.Second).String(),
+		WebhookURL: rule.WebhookURL,
+		Firing:     rule.Firing,
+	}
+}
+
+// CreateAlertRule stores a new alert rule for the request's tenant.
+func (service *WebService) CreateAlertRule(res http.ResponseWriter, req *http.Request) {
+	tenant, options, ok := service.alertRuleRequest(res, req)
+	if !ok {
+		return
+	}
+
+	rule, err := service.AlertInteractor.CreateRule(tenant, options)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	resource := newAlertRuleResource(rule)
+	resource.Secret = rule.Secret
+	res.Header().Set("Location", apiVersionPrefix+"/alerts/"+rule.ID)
+	service.RenderJSON(res, resource, http.StatusCreated)
+}
+
+// ListAlertRules lists every alert rule of the request's tenant.
+func (service *WebService) ListAlertRules(res http.ResponseWriter, req *http.Request) {
+	tenant, ok := service.alertTenant(res, req)
+	if !ok {
+		return
+	}
+
+	rules, err := service.AlertInteractor.ListRules(tenant)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	list := AlertRuleListResource{Rules: []AlertRuleResource{}}
+	for _, rule := range rules {
+		list.Rules = append(list.Rules, newAlertRuleResource(rule))
+	}
+	service.RenderJSON(res, list, http.StatusOK)
+}
+
+// GetAlertRule describes the alert rule identified in the path.
+func (service *WebService) GetAlertRule(res http.ResponseWriter, req *http.Request) {
+	tenant, ok := service.alertTenant(res, req)
+	if !ok {
+		return
+	}
+
+	rule, err := service.AlertInteractor.GetRule(tenant, PathParam(req, "id"))
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+	service.RenderJSON(res, newAlertRuleResource(rule), http.StatusOK)
+}
+
+// UpdateAlertRule replaces the definition of the alert rule identified in
+// the path.
+func (service *WebService) UpdateAlertRule(res http.ResponseWriter, req *http.Request) {
+	tenant, options, ok := service.alertRuleRequest(res, req)
+	if !ok {
+		return
+	}
+
+	rule, err := service.AlertInteractor.UpdateRule(tenant, PathParam(req, "id"), options)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+	service.RenderJSON(res, newAlertRuleResource(rule), http.StatusOK)
+}
+
+// DeleteAlertRule deletes the alert rule identified in the path.
+func (service *WebService) DeleteAlertRule(res http.ResponseWriter, req *http.Request) {
+	tenant, ok := service.alertTenant(res, req)
+	if !ok {
+		return
+	}
+
+	if err := service.AlertInteractor.DeleteRule(tenant, PathParam(req, "id")); err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// alertTenant returns the tenant of a request to manage alert rules,
+// rendering an error if the service is not configured to manage them.
+func (service *WebService) alertTenant(res http.ResponseWriter, req *http.Request) (string, bool) {
+	if service.AlertInteractor == nil {
+		service.renderError(res, req, usecases.UnsupportedError{Operation: "alerting"})
+		return "", false
+	}
+	return service.tenant(res, req)
+}
+
+// alertRuleRequest decodes the rule described by a request body, rendering
+// an error if it is missing a required field.
+func (service *WebService) alertRuleRequest(res http.ResponseWriter, req *http.Request) (string, usecases.AlertRuleOptions, bool) {
+	tenant, ok := service.alertTenant(res, req)
+	if !ok {
+		return "", usecases.AlertRuleOptions{}, false
+	}
+
+	rule := AlertRuleRequestResource{}
+	if !service.decodeJSON(res, req, &rule) {
+		return "", usecases.AlertRuleOptions{}, false
+	}
+
+	threshold := ""
+	if rule.Threshold != nil {
+		threshold = strconv.Itoa(*rule.Threshold)
+	}
+
+	params := requireFields(map[string]string{
+		"event_name":  rule.EventName,
+		"threshold":   threshold,
+		"window":      rule.Window,
+		"webhook_url": rule.WebhookURL,
+	})
+	if params != nil {
+		service.renderInvalidParams(res, req, params)
+		return "", usecases.AlertRuleOptions{}, false
+	}
+
+	return tenant, usecases.AlertRuleOptions{
+		EventName:  rule.EventName,
+		Threshold:  *rule.Threshold,
+		Window:     rule.Window,
+		WebhookURL: rule.WebhookURL,
+	}, true
+}
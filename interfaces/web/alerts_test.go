@@ -0,0 +1,131 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const alertRuleBody = `{"event_name": "payment_failed", "threshold": 100, "window": "5m", "webhook_url": "https://example.com/hooks"}`
+
+// serveAlerts sends a request to the alert rule endpoints of `service`,
+// checking it against the service's OpenAPI description.
+func serveAlerts(t *testing.T, service *WebService, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var request *http.Request
+	if body == "" {
+		request, _ = http.NewRequest(method, "http://example.com"+path, nil)
+	} else {
+		request, _ = http.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, service, request, response)
+	return response
+}
+
+func TestCreateAlertRule(t *testing.T) {
+	service := WebService{AlertInteractor: new(StubAlertInteractor)}
+	response := serveAlerts(t, &service, "POST", "/v1/alerts", alertRuleBody)
+
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected response code %d, got %d", http.StatusCreated, response.Code)
+	}
+
+	var rule AlertRuleResource
+	json.Unmarshal(response.Body.Bytes(), &rule)
+	expected := AlertRuleResource{
+		ID:         "rule1",
+		EventName:  "payment_failed",
+		Threshold:  100,
+		Window:     "5m0s",
+		WebhookURL: "https://example.com/hooks",
+		Secret:     "s3cret",
+	}
+	if rule != expected {
+		t.Errorf("expected %+v, got %+v", expected, rule)
+	}
+	if location := response.Header().Get("Location"); location != "/v1/alerts/rule1" {
+		t.Errorf("expected Location /v1/alerts/rule1, got %q", location)
+	}
+}
+
+func TestCreateAlertRuleRequiresFields(t *testing.T) {
+	service := WebService{AlertInteractor: new(StubAlertInteractor)}
+	response := serveAlerts(t, &service, "POST", "/v1/alerts", `{"event_name": "payment_failed"}`)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || len(problem.InvalidParams) != 3 {
+		t.Errorf("expected threshold, webhook_url and window to be required, got %s", response.Body)
+	}
+}
+
+func TestCreateAlertRuleInvalidWindow(t *testing.T) {
+	service := WebService{AlertInteractor: new(StubAlertInteractor)}
+	body := strings.Replace(alertRuleBody, `"5m"`, `"soon"`, 1)
+	response := serveAlerts(t, &service, "POST", "/v1/alerts", body)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "window" {
+		t.Errorf("expected window to be rejected, got %s", response.Body)
+	}
+}
+
+func TestAlertRuleLifecycle(t *testing.T) {
+	service := WebService{AlertInteractor: new(StubAlertInteractor)}
+	serveAlerts(t, &service, "POST", "/v1/alerts", alertRuleBody)
+
+	response := serveAlerts(t, &service, "GET", "/v1/alerts", "")
+	var list AlertRuleListResource
+	json.Unmarshal(response.Body.Bytes(), &list)
+	if len(list.Rules) != 1 || list.Rules[0].Secret != "" {
+		t.Errorf("expected one rule without its secret, got %s", response.Body)
+	}
+
+	body := strings.Replace(alertRuleBody, "100", "5", 1)
+	response = serveAlerts(t, &service, "PUT", "/v1/alerts/rule1", body)
+	var rule AlertRuleResource
+	json.Unmarshal(response.Body.Bytes(), &rule)
+	if response.Code != http.StatusOK || rule.ID != "rule1" || rule.Threshold != 5 {
+		t.Errorf("expected rule1 to be updated, got %s", response.Body)
+	}
+
+	response = serveAlerts(t, &service, "DELETE", "/v1/alerts/rule1", "")
+	if response.Code != http.StatusNoContent {
+		t.Errorf("expected response code %d, got %d", http.StatusNoContent, response.Code)
+	}
+
+	response = serveAlerts(t, &service, "GET", "/v1/alerts/rule1", "")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected response code %d, got %d", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestAlertRulesAreScopedToTenant(t *testing.T) {
+	service := WebService{AlertInteractor: new(StubAlertInteractor)}
+	serveAlerts(t, &service, "POST", "/v1/alerts", alertRuleBody)
+
+	request, _ := http.NewRequest("GET", "http://example.com/v1/alerts/rule1", nil)
+	request.Header.Set(TenantHeader, "other")
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected response code %d, got %d", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestAlertRulesUnsupported(t *testing.T) {
+	service := WebService{}
+	response := serveAlerts(t, &service, "GET", "/v1/alerts", "")
+
+	if response.Code != http.StatusNotImplemented {
+		t.Errorf("expected response code %d, got %d", http.StatusNotImplemented, response.Code)
+	}
+}
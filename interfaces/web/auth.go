@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+type AuthInteractor interface {
+	Authenticate(key string) (domain.APIKey, error)
+	Authorize(key domain.APIKey, scope string) error
+	CreateKey(options usecases.KeyOptions) (string, error)
+}
+
+type KeyRequestResource struct {
+	Scopes     []string `json:"scopes"`
+	Tenant     string   `json:"tenant,omitempty"`
+	ReadLimit  string   `json:"read_limit,omitempty"`
+	WriteLimit string   `json:"write_limit,omitempty"`
+}
+
+type KeyResource struct {
+	Key        string   `json:"key"`
+	Scopes     []string `json:"scopes"`
+	Tenant     string   `json:"tenant,omitempty"`
+	ReadLimit  string   `json:"read_limit,omitempty"`
+	WriteLimit string   `json:"write_limit,omitempty"`
+}
+
+// APIKeyFromContext returns the API key stored in `ctx` by RequireScope, and
+// whether one was present.
+func APIKeyFromContext(ctx context.Context) (domain.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey).(domain.APIKey)
+	return key, ok
+}
+
+// RequireScope is middleware which only calls `next` if the request carries
+// an API key, in an `Authorization: Bearer` header, which has been granted
+// `scope`. Requests are not authenticated if the service has no
+// AuthInteractor.
+func (service *WebService) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if service.AuthInteractor == nil {
+			next(res, req)
+			return
+		}
+
+		key, err := service.AuthInteractor.Authenticate(bearerToken(req))
+		if err == nil {
+			err = service.AuthInteractor.Authorize(key, scope)
+		}
+
+		if err != nil {
+			service.renderError(res, req, err)
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), apiKeyKey, key)
+		next(res, req.WithContext(ctx))
+	}
+}
+
+// CreateKey issues a new API key with the scopes given in the request body.
+// The key is only ever returned in this response.
+func (service *WebService) CreateKey(res http.ResponseWriter, req *http.Request) {
+	if service.AuthInteractor == nil {
+		service.renderError(res, req, usecases.UnsupportedError{Operation: "issuing API keys"})
+		return
+	}
+
+	keyRequest := KeyRequestResource{}
+	if !service.decodeJSON(res, req, &keyRequest) {
+		return
+	}
+
+	readLimit, err := usecases.ParseRateLimit(keyRequest.ReadLimit)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	writeLimit, err := usecases.ParseRateLimit(keyRequest.WriteLimit)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	options := usecases.KeyOptions{
+		Tenant:     keyRequest.Tenant,
+		Scopes:     keyRequest.Scopes,
+		ReadLimit:  readLimit,
+		WriteLimit: writeLimit,
+	}
+
+	key, err := service.AuthInteractor.CreateKey(options)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	service.RenderJSON(
+		res,
+		KeyResource{
+			Key:        key,
+			Scopes:     keyRequest.Scopes,
+			Tenant:     keyRequest.Tenant,
+			ReadLimit:  keyRequest.ReadLimit,
+			WriteLimit: keyRequest.WriteLimit,
+		},
+		http.StatusCreated)
+}
+
+func bearerToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+func TestRequireScope(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		AuthInteractor:  new(StubAuthInteractor),
+	}
+
+	cases := []struct {
+		authorization  string
+		scope          string
+		expectedStatus int
+	}{
+		{"Bearer secret", usecases.ScopeRead, http.StatusOK},
+		{"bearer secret", usecases.ScopeRead, http.StatusOK},
+		{"", usecases.ScopeRead, http.StatusUnauthorized},
+		{"Basic secret", usecases.ScopeRead, http.StatusUnauthorized},
+		{"Bearer wrong", usecases.ScopeRead, http.StatusUnauthorized},
+		{"Bearer secret", usecases.ScopeWrite, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		handler := service.RequireScope(c.scope, service.Count)
+		request, _ := http.NewRequest(
+			"GET",
+			"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+			nil)
+		if c.authorization != "" {
+			request.Header.Set("Authorization", c.authorization)
+		}
+
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		if response.Code != c.expectedStatus {
+			t.Errorf("%q with scope %q: expected response code %d, got %d",
+				c.authorization, c.scope, c.expectedStatus, response.Code)
+		}
+
+		if c.expectedStatus == http.StatusUnauthorized && response.Header().Get("WWW-Authenticate") == "" {
+			t.Error("expected WWW-Authenticate header")
+		}
+
+		if c.expectedStatus != http.StatusOK {
+			errorResource := ErrorResource{}
+			if err := json.Unmarshal(response.Body.Bytes(), &errorResource); err != nil || errorResource.Code == "" {
+				t.Errorf("expected ErrorResource, got %s", response.Body.String())
+			}
+		}
+	}
+}
+
+func TestRequireScopeStoresKeyInContext(t *testing.T) {
+	service := WebService{AuthInteractor: new(StubAuthInteractor)}
+
+	var found bool
+	handler := service.RequireScope(usecases.ScopeRead, func(res http.ResponseWriter, req *http.Request) {
+		_, found = APIKeyFromContext(req.Context())
+	})
+
+	request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	handler(httptest.NewRecorder(), request)
+
+	if !found {
+		t.Error("expected API key in request context")
+	}
+}
+
+func TestRequireScopeAuthInteractorError(t *testing.T) {
+	service := WebService{AuthInteractor: new(StubAuthInteractorWithError)}
+	handler := service.RequireScope(usecases.ScopeRead, service.Count)
+
+	request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	response := httptest.NewRecorder()
+	handler(response, request)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("expected response code %d, got %d", http.StatusInternalServerError, response.Code)
+	}
+}
+
+func TestRequireScopeWithoutAuthInteractor(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	handler := service.RequireScope(usecases.ScopeWrite, service.Create)
+
+	requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`)
+	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	handler(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Errorf("expected response code %d, got %d", http.StatusCreated, response.Code)
+	}
+}
+
+func TestCreateKey(t *testing.T) {
+	service := WebService{AuthInteractor: new(StubAuthInteractor)}
+
+	requestBody := strings.NewReader(`{"scopes": ["events:read"]}`)
+	request, _ := http.NewRequest("POST", "http://example.com/keys", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	service.CreateKey(response, request)
+
+	if response.Code != http.StatusCreated {
+		t.Errorf("expected response code %d, got %d", http.StatusCreated, response.Code)
+	}
+
+	key := KeyResource{}
+	json.Unmarshal(response.Body.Bytes(), &key)
+	if key.Key != "new-key" {
+		t.Errorf("expected key in response, got %s", response.Body.String())
+	}
+}
+
+func TestCreateKeyWithoutAuthInteractor(t *testing.T) {
+	service := WebService{}
+
+	requestBody := strings.NewReader(`{"scopes": ["events:read"]}`)
+	request, _ := http.NewRequest("POST", "http://example.com/keys", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	service.CreateKey(response, request)
+
+	if response.Code != http.StatusNotImplemented {
+		t.Errorf("expected response code %d, got %d", http.StatusNotImplemented, response.Code)
+	}
+}
+
+func TestCreateKeyInvalidScopes(t *testing.T) {
+	service := WebService{AuthInteractor: new(StubAuthInteractor)}
+
+	for _, body := range []string{`{"scopes": []}`, `{"scopes": `} {
+		request, _ := http.NewRequest("POST", "http://example.com/keys", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		service.CreateKey(response, request)
+
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("expected response code %d, got %d", http.StatusBadRequest, response.Code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// ComparisonResource describes the number of events of one name in a time
+// range and in the period it is compared with. PercentChange is null if
+// there were no events in the comparison period.
+type ComparisonResource struct {
+	Count           int      `json:"count"`
+	ComparisonCount int      `json:"comparison_count"`
+	Delta           int      `json:"delta"`
+	PercentChange   *float64 `json:"percent_change"`
+}
+
+// compare renders the counts of each name in a time range compared with
+// those in the period named `compare`.
+func (service *WebService) compare(res http.ResponseWriter, req *http.Request, tenant string, timeRange usecases.TimeRange, compare string) {
+	comparisons, err := service.EventInteractor.CompareEventsInTimeRange(tenant, timeRange, compare)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	resource := map[string]ComparisonResource{}
+	for name, comparison := range comparisons {
+		resource[name] = ComparisonResource{
+			Count:           comparison.Count,
+			ComparisonCount: comparison.ComparisonCount,
+			Delta:           comparison.Delta,
+			PercentChange:   comparison.PercentChange,
+		}
+	}
+	service.RenderJSON(res, resource, http.StatusOK)
+}
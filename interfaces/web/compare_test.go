@@ -0,0 +1,46 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const compareURL = "http://example.com/v1/events/count?from=2015-02-11T15:00:00Z&to=2015-02-11T15:59:59Z"
+
+func TestCountCompare(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", compareURL+"&compare=previous_period", nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var comparisons map[string]map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &comparisons)
+	expected := map[string]map[string]interface{}{
+		"foo": {"count": 0.0, "comparison_count": 5.0, "delta": -5.0, "percent_change": -100.0},
+		"bar": {"count": 7.0, "comparison_count": 4.0, "delta": 3.0, "percent_change": 75.0},
+		"baz": {"count": 2.0, "comparison_count": 0.0, "delta": 2.0, "percent_change": nil},
+	}
+	if response.Code != http.StatusOK || !reflect.DeepEqual(comparisons, expected) {
+		t.Errorf("expected %v, got %d %s", expected, response.Code, response.Body)
+	}
+}
+
+func TestCountCompareInvalidPeriod(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", compareURL+"&compare=yesterday", nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "compare" {
+		t.Errorf("expected compare to be rejected, got %d %s", response.Code, response.Body)
+	}
+}
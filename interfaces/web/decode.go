@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the largest request body accepted by a WebService
+// which has no MaxBodyBytes configured.
+const DefaultMaxBodyBytes = 1 << 20
+
+// decodeJSON strictly decodes a JSON request body into `dest`. The request
+// must have a JSON Content-Type, a body no larger than the service's limit,
+// and contain exactly one JSON object with no fields unknown to `dest`. If
+// the body cannot be decoded, an error response is rendered and false is
+// returned.
+func (service *WebService) decodeJSON(res http.ResponseWriter, req *http.Request, dest interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		service.RenderProblem(res, req, NewProblem(
+			http.StatusUnsupportedMediaType,
+			CodeUnsupportedMediaType,
+			"Content-Type must be application/json"))
+		return false
+	}
+
+	limit := service.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	defer req.Body.Close()
+	decoder := json.NewDecoder(http.MaxBytesReader(res, req.Body, limit))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(dest)
+	if err == nil {
+		// anything other than whitespace after the object is an error
+		if _, err = decoder.Token(); err == io.EOF {
+			return true
+		} else if err == nil {
+			err = errors.New("unexpected data after JSON object")
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		service.RenderProblem(res, req, NewProblem(
+			http.StatusRequestEntityTooLarge,
+			CodeBodyTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", limit)))
+	case err == io.EOF:
+		service.RenderProblem(res, req, NewProblem(http.StatusBadRequest, CodeEmptyBody, ""))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		service.renderInvalidParams(res, req, []InvalidParam{
+			{Name: typeErr.Field, Reason: "must be of type " + typeErr.Type.Kind().String()},
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		service.renderInvalidParams(res, req, []InvalidParam{{Name: field, Reason: "is not allowed"}})
+	default:
+		service.RenderProblem(res, req, NewProblem(http.StatusBadRequest, CodeMalformedJSON, err.Error()))
+	}
+	return false
+}
+
+// requireFields returns an InvalidParam naming each of `fields` whose value
+// is blank, ordered by name.
+func requireFields(fields map[string]string) []InvalidParam {
+	var missing []InvalidParam
+	for field, value := range fields {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, InvalidParam{Name: field, Reason: "is required"})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return missing
+}
+
+// renderInvalidParams renders a problem listing each offending field. The
+// problem's Field is set too when there is only one.
+func (service *WebService) renderInvalidParams(res http.ResponseWriter, req *http.Request, params []InvalidParam) {
+	detail := make([]string, len(params))
+	for i, param := range params {
+		detail[i] = param.Name + " " + param.Reason
+	}
+
+	problem := NewProblem(http.StatusBadRequest, CodeInvalidParams, strings.Join(detail, ", "))
+	problem.InvalidParams = params
+	if len(params) == 1 {
+		problem.Field = params[0].Name
+	}
+	service.RenderProblem(res, req, problem)
+}
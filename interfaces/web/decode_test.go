@@ -0,0 +1,123 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateRequestHardening(t *testing.T) {
+	cases := []struct {
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedParams []InvalidParam
+	}{
+		{
+			"application/json; charset=utf-8",
+			`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusCreated,
+			nil,
+		},
+		{
+			"",
+			`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusUnsupportedMediaType,
+			nil,
+		},
+		{
+			"text/plain",
+			`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusUnsupportedMediaType,
+			nil,
+		},
+		{
+			"application/json",
+			`{"name": "` + strings.Repeat("a", 128) + `", "timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusRequestEntityTooLarge,
+			nil,
+		},
+		{
+			"application/json",
+			``,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"application/json",
+			`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"} {"name": "other"}`,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"application/json",
+			`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00", "extra": 1}`,
+			http.StatusBadRequest,
+			[]InvalidParam{{"extra", "is not allowed"}},
+		},
+		{
+			"application/json",
+			`{"name": 42, "timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusBadRequest,
+			[]InvalidParam{{"name", "must be of type string"}},
+		},
+		{
+			"application/json",
+			`{"timestamp": "2015-02-11T15:01:00+00:00"}`,
+			http.StatusBadRequest,
+			[]InvalidParam{{"name", "is required"}},
+		},
+		{
+			"application/json",
+			`{"name": "  "}`,
+			http.StatusBadRequest,
+			[]InvalidParam{{"name", "is required"}, {"timestamp", "is required"}},
+		},
+	}
+
+	service := WebService{EventInteractor: new(StubEventInteractor), MaxBodyBytes: 96}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest("POST", "http://example.com/events", strings.NewReader(c.body))
+		if c.contentType != "" {
+			request.Header.Set("Content-Type", c.contentType)
+		}
+
+		response := httptest.NewRecorder()
+		service.Create(response, request)
+
+		if response.Code != c.expectedStatus {
+			t.Errorf("%s: expected response code %d, got %d", c.body, c.expectedStatus, response.Code)
+		}
+
+		errorResource := ErrorResource{}
+		json.Unmarshal(response.Body.Bytes(), &errorResource)
+		if !reflect.DeepEqual(errorResource.InvalidParams, c.expectedParams) {
+			t.Errorf("%s: expected invalid params %v, got %v", c.body, c.expectedParams, errorResource.InvalidParams)
+		}
+	}
+}
+
+func TestCreateValidationError(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractorWithValidationError)}
+
+	requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`)
+	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	service.Create(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected response code %d, got %d", http.StatusBadRequest, response.Code)
+	}
+
+	errorResource := ErrorResource{}
+	json.Unmarshal(response.Body.Bytes(), &errorResource)
+	if errorResource.Code != CodeFieldInvalid || errorResource.Field != "name" {
+		t.Errorf("expected field error for name, got %s", response.Body.String())
+	}
+}
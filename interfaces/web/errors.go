@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// ProblemContentType is the media type of error responses, which are
+// problem details documents as described by RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// problemTypeBase prefixes a problem's code to form its type URI.
+const problemTypeBase = "urn:go-events-service:problem:"
+
+// Codes identifying each kind of problem. These are stable, so clients may
+// rely on them rather than on the human-readable title and detail.
+const (
+	CodeMalformedJSON        = "request.malformed_json"
+	CodeEmptyBody            = "request.empty_body"
+	CodeBodyTooLarge         = "request.body_too_large"
+	CodeUnsupportedMediaType = "request.unsupported_media_type"
+	CodeInvalidParams        = "request.invalid_params"
+	CodeMissingParameter     = "request.missing_parameter"
+	CodeMethodNotAllowed     = "request.method_not_allowed"
+	CodeRouteNotFound        = "request.not_found"
+	CodeTimestampNotISO8601  = "timestamp.not_iso8601"
+	CodeTimestampNotUTC      = "timestamp.not_utc"
+	CodeRangeInverted        = "range.inverted"
+	CodeRangeEmpty           = "range.empty"
+	CodeFieldInvalid         = "field.invalid"
+	CodeTenantInvalid        = "tenant.invalid"
+	CodeTenantForbidden      = "tenant.forbidden"
+	CodeScopeInvalid         = "scope.invalid"
+	CodeRateLimitInvalid     = "rate_limit.invalid"
+	CodeRateLimitExceeded    = "rate_limit.exceeded"
+	CodeUnauthenticated      = "auth.unauthenticated"
+	CodeForbidden            = "auth.forbidden"
+	CodeNotFound             = "store.not_found"
+	CodeConflict             = "store.conflict"
+	CodeUnavailable          = "store.unavailable"
+	CodeTimeout              = "store.timeout"
+	CodeUnsupported          = "store.unsupported"
+	CodeInternal             = "internal"
+)
+
+var problemTitles = map[string]string{
+	CodeMalformedJSON:        "Request JSON is invalid",
+	CodeEmptyBody:            "Request body is empty",
+	CodeBodyTooLarge:         "Request body is too large",
+	CodeUnsupportedMediaType: "Unsupported media type",
+	CodeInvalidParams:        "Request is invalid",
+	CodeMissingParameter:     "Missing required parameter",
+	CodeMethodNotAllowed:     "Method not allowed",
+	CodeRouteNotFound:        "Resource not found",
+	CodeTimestampNotISO8601:  "Timestamp does not conform to ISO8601",
+	CodeTimestampNotUTC:      "Timestamp is not UTC",
+	CodeRangeInverted:        "Time range is inverted",
+	CodeRangeEmpty:           "Time range is empty",
+	CodeFieldInvalid:         "Field is invalid",
+	CodeTenantInvalid:        "Tenant is invalid",
+	CodeTenantForbidden:      "Tenant is forbidden",
+	CodeScopeInvalid:         "Scope is invalid",
+	CodeRateLimitInvalid:     "Rate limit is invalid",
+	CodeRateLimitExceeded:    "Rate limit exceeded",
+	CodeUnauthenticated:      "Unauthenticated",
+	CodeForbidden:            "Forbidden",
+	CodeNotFound:             "Not found",
+	CodeConflict:             "Conflicting update",
+	CodeUnavailable:          "Datastore unavailable",
+	CodeTimeout:              "Datastore timed out",
+	CodeUnsupported:          "Not supported by the datastore",
+	CodeInternal:             "Internal server error",
+}
+
+// InvalidParam names a request field and what is wrong with it.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ErrorResource describes why a request failed, as a problem details
+// document. Field names the request field or parameter at fault, if any,
+// and InvalidParams lists every offending field when there are several.
+type ErrorResource struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Code          string         `json:"code"`
+	Field         string         `json:"field,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+}
+
+// NewProblem returns an ErrorResource of the kind identified by `code`.
+func NewProblem(status int, code, detail string) ErrorResource {
+	return ErrorResource{
+		Type:   problemTypeBase + code,
+		Title:  problemTitles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// problemFor returns an ErrorResource describing an error returned by an
+// interactor. Errors caused by the request are 4xx, errors caused by the
+// backing store are 409, 503 or 504, and anything else is 500.
+func problemFor(err error) ErrorResource {
+	switch e := err.(type) {
+	case domain.ValidationError:
+		problem := NewProblem(http.StatusBadRequest, CodeFieldInvalid, e.Error())
+		problem.Field = e.Field
+		return problem
+	case usecases.InvalidTimestampError:
+		code := CodeTimestampNotUTC
+		if e.NotISO8601 {
+			code = CodeTimestampNotISO8601
+		}
+		problem := NewProblem(http.StatusBadRequest, code, e.Error())
+		problem.Field = e.Field
+		return problem
+	case usecases.InvalidTimeRangeError:
+		problem := NewProblem(http.StatusBadRequest, CodeRangeInverted, e.Error())
+		problem.Field = "from"
+		return problem
+	case usecases.EmptyTimeRangeError:
+		problem := NewProblem(http.StatusBadRequest, CodeRangeEmpty, e.Error())
+		problem.Field = "to"
+		return problem
+	case usecases.InvalidTenantError:
+		return NewProblem(http.StatusBadRequest, CodeTenantInvalid, e.Error())
+	case usecases.InvalidScopeError:
+		problem := NewProblem(http.StatusBadRequest, CodeScopeInvalid, e.Error())
+		problem.Field = "scopes"
+		return problem
+	case usecases.InvalidRateLimitError:
+		return NewProblem(http.StatusBadRequest, CodeRateLimitInvalid, e.Error())
+	case usecases.UnauthenticatedError:
+		return NewProblem(http.StatusUnauthorized, CodeUnauthenticated, e.Error())
+	case usecases.ForbiddenError:
+		return NewProblem(http.StatusForbidden, CodeForbidden, e.Error())
+	case usecases.UnsupportedError:
+		return NewProblem(http.StatusNotImplemented, CodeUnsupported, e.Error())
+	case usecases.TenantForbiddenError:
+		return NewProblem(http.StatusForbidden, CodeTenantForbidden, e.Error())
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return NewProblem(http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, domain.ErrConflict):
+		return NewProblem(http.StatusConflict, CodeConflict, "")
+	case errors.Is(err, domain.ErrUnavailable):
+		return NewProblem(http.StatusServiceUnavailable, CodeUnavailable, "")
+	case errors.Is(err, domain.ErrTimeout):
+		return NewProblem(http.StatusGatewayTimeout, CodeTimeout, "")
+	}
+
+	return NewProblem(http.StatusInternalServerError, CodeInternal, "")
+}
+
+// renderError renders a problem describing `err`. Server errors are logged,
+// and their details are not revealed to the client.
+func (service *WebService) renderError(res http.ResponseWriter, req *http.Request, err error) {
+	problem := problemFor(err)
+
+	if problem.Status >= http.StatusInternalServerError {
+		service.logError(req, err)
+	}
+
+	service.RenderProblem(res, req, problem)
+}
+
+// RenderProblem renders `problem` as an application/problem+json response,
+// tagged with the request's ID.
+func (service *WebService) RenderProblem(res http.ResponseWriter, req *http.Request, problem ErrorResource) {
+	writeProblem(res, req, problem)
+}
+
+func writeProblem(res http.ResponseWriter, req *http.Request, problem ErrorResource) {
+	problem.RequestID = RequestIDFromContext(req.Context())
+
+	if problem.Status == http.StatusUnauthorized {
+		res.Header().Set("WWW-Authenticate", `Bearer realm="events"`)
+	}
+
+	responseBody, _ := json.MarshalIndent(problem, "", "    ")
+	res.Header().Set("Content-Type", ProblemContentType)
+	res.WriteHeader(problem.Status)
+	res.Write(responseBody)
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+func TestProblemForError(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+		field  string
+	}{
+		{
+			domain.ValidationError{Field: "name", Reason: "is required"},
+			http.StatusBadRequest, CodeFieldInvalid, "name",
+		},
+		{
+			usecases.InvalidTimestampError{Timestamp: "2015/02/18", Field: "timestamp", NotISO8601: true},
+			http.StatusBadRequest, CodeTimestampNotISO8601, "timestamp",
+		},
+		{
+			usecases.InvalidTimestampError{Timestamp: "2015-02-18T13:26:00-08:00", Field: "to", NotUTC: true},
+			http.StatusBadRequest, CodeTimestampNotUTC, "to",
+		},
+		{
+			usecases.InvalidTimeRangeError{From: "b", To: "a"},
+			http.StatusBadRequest, CodeRangeInverted, "from",
+		},
+		{
+			usecases.EmptyTimeRangeError{From: "a", To: "b"},
+			http.StatusBadRequest, CodeRangeEmpty, "to",
+		},
+		{
+			usecases.InvalidTenantError{Tenant: "a:b"},
+			http.StatusBadRequest, CodeTenantInvalid, "",
+		},
+		{
+			usecases.InvalidScopeError{Scope: "events:delete"},
+			http.StatusBadRequest, CodeScopeInvalid, "scopes",
+		},
+		{
+			usecases.InvalidRateLimitError{Limit: "10"},
+			http.StatusBadRequest, CodeRateLimitInvalid, "",
+		},
+		{
+			usecases.UnauthenticatedError{Reason: "missing API key"},
+			http.StatusUnauthorized, CodeUnauthenticated, "",
+		},
+		{
+			usecases.ForbiddenError{Scope: usecases.ScopeWrite},
+			http.StatusForbidden, CodeForbidden, "",
+		},
+		{
+			usecases.TenantForbiddenError{Tenant: "acme"},
+			http.StatusForbidden, CodeTenantForbidden, "",
+		},
+		{
+			usecases.UnsupportedError{Operation: "exporting events"},
+			http.StatusNotImplemented, CodeUnsupported, "",
+		},
+		{
+			domain.ErrNotFound,
+			http.StatusNotFound, CodeNotFound, "",
+		},
+		{
+			&domain.StoreError{Op: "storing event", Kind: domain.ErrConflict, Err: io.EOF},
+			http.StatusConflict, CodeConflict, "",
+		},
+		{
+			&domain.StoreError{Op: "storing event", Kind: domain.ErrUnavailable, Err: io.EOF},
+			http.StatusServiceUnavailable, CodeUnavailable, "",
+		},
+		{
+			&domain.StoreError{Op: "storing event", Kind: domain.ErrTimeout, Err: io.EOF},
+			http.StatusGatewayTimeout, CodeTimeout, "",
+		},
+		{
+			&domain.StoreError{Op: "storing event", Err: io.EOF},
+			http.StatusInternalServerError, CodeInternal, "",
+		},
+		{
+			errors.New("unexpected"),
+			http.StatusInternalServerError, CodeInternal, "",
+		},
+	}
+
+	for _, c := range cases {
+		problem := problemFor(c.err)
+		if problem.Status != c.status || problem.Code != c.code || problem.Field != c.field {
+			t.Errorf("%T %v: expected status %d, code %q, field %q, got %d, %q, %q",
+				c.err, c.err, c.status, c.code, c.field, problem.Status, problem.Code, problem.Field)
+		}
+		if problem.Type != problemTypeBase+c.code || problem.Title == "" {
+			t.Errorf("%T: expected type and title for code %q, got %q, %q", c.err, c.code, problem.Type, problem.Title)
+		}
+	}
+}
+
+func TestProblemTitles(t *testing.T) {
+	codes := []string{
+		CodeMalformedJSON, CodeEmptyBody, CodeBodyTooLarge, CodeUnsupportedMediaType,
+		CodeInvalidParams, CodeMissingParameter, CodeMethodNotAllowed, CodeRateLimitExceeded,
+	}
+	for _, code := range codes {
+		if problemTitles[code] == "" {
+			t.Errorf("missing title for code %q", code)
+		}
+	}
+}
+
+func TestRenderProblem(t *testing.T) {
+	service := WebService{}
+	handler := RequestID(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		service.renderError(res, req, usecases.InvalidTimestampError{
+			Timestamp: "2015-02-18T13:26:00-08:00", Field: "timestamp", NotUTC: true})
+	}))
+
+	request, _ := http.NewRequest("POST", "http://example.com/events", nil)
+	request.Header.Set(RequestIDHeader, "abc-123")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("expected Content-Type %q, got %q", ProblemContentType, response.Header().Get("Content-Type"))
+	}
+
+	received := map[string]interface{}{}
+	json.Unmarshal(response.Body.Bytes(), &received)
+	expected := map[string]interface{}{
+		"type":       "urn:go-events-service:problem:timestamp.not_utc",
+		"title":      "Timestamp is not UTC",
+		"status":     float64(http.StatusBadRequest),
+		"detail":     "2015-02-18T13:26:00-08:00 is not UTC",
+		"code":       "timestamp.not_utc",
+		"field":      "timestamp",
+		"request_id": "abc-123",
+	}
+	if len(received) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+	for key, value := range expected {
+		if received[key] != value {
+			t.Errorf("expected %s %v, got %v", key, value, received[key])
+		}
+	}
+}
+
+func TestRenderErrorHidesServerErrors(t *testing.T) {
+	service := WebService{}
+	request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+	response := httptest.NewRecorder()
+
+	err := &domain.StoreError{Op: "getting event names", Kind: domain.ErrUnavailable, Err: errors.New("dial tcp 10.0.0.1:6379")}
+	service.renderError(response, request, err)
+
+	problem := ErrorResource{}
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if problem.Code != CodeUnavailable || problem.Detail != "" {
+		t.Errorf("expected no detail for server error, got %s", response.Body.String())
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+const (
+	NDJSONContentType = "application/x-ndjson"
+	CSVContentType    = "text/csv; charset=utf-8"
+)
+
+// exportFormats maps each format accepted by Export to its content type.
+var exportFormats = map[string]string{
+	"ndjson": NDJSONContentType,
+	"csv":    CSVContentType,
+}
+
+// exportFlushEvents is the number of events written between flushes of an
+// export to the client, so that events are sent as they are read rather
+// than buffered until the export is complete.
+const exportFlushEvents = 1000
+
+// Export streams every event in a time range, optionally only those with a
+// given name, as newline-delimited JSON or CSV. Since the response status
+// is sent with the first event, an error part way through an export can only
+// be signalled by aborting the response, which clients see as a truncated
+// chunked body.
+func (service *WebService) Export(res http.ResponseWriter, req *http.Request) {
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	format := req.FormValue("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	if _, ok := exportFormats[format]; !ok {
+		service.renderInvalidParams(res, req, []InvalidParam{{Name: "format", Reason: "must be ndjson or csv"}})
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	stream := &exportStream{res: res, req: req, format: format}
+	err := service.EventInteractor.ExportEvents(tenant, timeRange, req.FormValue("name"), stream.write)
+	if err == nil {
+		err = stream.finish()
+	}
+	if err == nil {
+		return
+	}
+
+	if !stream.started {
+		service.renderError(res, req, err)
+		return
+	}
+	service.logError(req, err)
+	panic(http.ErrAbortHandler)
+}
+
+// exportStream writes exported events to a response, sending the response
+// header with the first event.
+type exportStream struct {
+	res     http.ResponseWriter
+	req     *http.Request
+	format  string
+	started bool
+	out     *bufio.Writer
+	csv     *csv.Writer
+	events  int
+}
+
+func (stream *exportStream) start() {
+	stream.started = true
+	stream.res.Header().Set("Content-Type", exportFormats[stream.format])
+	stream.res.WriteHeader(http.StatusOK)
+
+	stream.out = bufio.NewWriter(stream.res)
+	if stream.format == "csv" {
+		stream.csv = csv.NewWriter(stream.out)
+		stream.csv.Write([]string{"name", "timestamp"})
+	}
+}
+
+func (stream *exportStream) write(event domain.Event) error {
+	// stop reading from the store once the client has gone away
+	if err := stream.req.Context().Err(); err != nil {
+		return err
+	}
+
+	if !stream.started {
+		stream.start()
+	}
+
+	resource := EventResource{
+		Name:      event.Name,
+		Timestamp: time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339),
+	}
+	if stream.csv != nil {
+		stream.csv.Write([]string{resource.Name, resource.Timestamp})
+	} else {
+		line, _ := json.Marshal(resource)
+		stream.out.Write(append(line, '\n'))
+	}
+
+	stream.events++
+	if stream.events%exportFlushEvents == 0 {
+		return stream.flush()
+	}
+	return nil
+}
+
+func (stream *exportStream) flush() error {
+	if stream.csv != nil {
+		stream.csv.Flush()
+		if err := stream.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := stream.out.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := stream.res.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// finish completes the export, starting it first if there were no events.
+func (stream *exportStream) finish() error {
+	if !stream.started {
+		stream.start()
+	}
+	return stream.flush()
+}
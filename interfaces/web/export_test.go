@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const exportURL = "http://example.com/v1/events/export?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00"
+
+func TestExportNDJSON(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", exportURL, nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("expected response code %d, got %d", http.StatusOK, response.Code)
+	}
+	if contentType := response.Header().Get("Content-Type"); contentType != NDJSONContentType {
+		t.Errorf("expected content type %q, got %q", NDJSONContentType, contentType)
+	}
+
+	expected := `{"name":"bar","timestamp":"2015-02-11T15:01:01Z"}
+{"name":"foo","timestamp":"2015-02-11T15:01:00Z"}
+{"name":"foo","timestamp":"2015-02-11T15:01:02Z"}
+`
+	if response.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, response.Body.String())
+	}
+}
+
+func TestExportCSVByName(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", exportURL+"&format=csv&name=foo", nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if contentType := response.Header().Get("Content-Type"); contentType != CSVContentType {
+		t.Errorf("expected content type %q, got %q", CSVContentType, contentType)
+	}
+
+	expected := "name,timestamp\nfoo,2015-02-11T15:01:00Z\nfoo,2015-02-11T15:01:02Z\n"
+	if response.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, response.Body.String())
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", exportURL+"&format=csv&name=none", nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+
+	if response.Code != http.StatusOK || response.Body.String() != "name,timestamp\n" {
+		t.Errorf("expected empty export, got %d %q", response.Code, response.Body.String())
+	}
+}
+
+func TestExportInvalidFormat(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", exportURL+"&format=xml", nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected response code %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestExportMissingParameter(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", "http://example.com/v1/events/export?from=2015-02-11T15:01:00+00:00", nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected response code %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestExportUnsupported(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractorWithExportError)}
+	request, _ := http.NewRequest("GET", exportURL, nil)
+
+	response := httptest.NewRecorder()
+	service.Export(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusNotImplemented {
+		t.Errorf("expected response code %d, got %d", http.StatusNotImplemented, response.Code)
+	}
+}
+
+func TestExportErrorAfterStartAbortsResponse(t *testing.T) {
+	logs := new(bytes.Buffer)
+	service := WebService{EventInteractor: new(StubEventInteractorWithExportStreamError), Logger: NewLogger(logs)}
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	// the abort is seen as soon as the client reads the part of the
+	// response not yet sent, which may be the header
+	response, err := http.Get(server.URL + "/v1/events/export?from=2015-02-11T15:01:00Z&to=2015-02-11T15:01:59Z")
+	if err == nil {
+		_, err = ioutil.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	if err == nil {
+		t.Error("expected truncated response to be reported as an error")
+	}
+
+	// wait for the handler to finish before reading its log
+	server.Close()
+	if !bytes.Contains(logs.Bytes(), []byte(`"level":"error"`)) {
+		t.Errorf("expected error to be logged, got %s", logs.String())
+	}
+}
+
+func TestExportIsStreamedThroughAccessLog(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/v1/events/export?from=2015-02-11T15:01:00Z&to=2015-02-11T15:01:59Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if len(response.TransferEncoding) != 1 || response.TransferEncoding[0] != "chunked" {
+		t.Errorf("expected chunked transfer encoding, got %v", response.TransferEncoding)
+	}
+}
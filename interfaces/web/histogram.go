@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// HistogramResource describes the number of events named Name in each
+// bucket of a time range. Buckets are Interval long, and aligned to the
+// clocks and calendar of the time zone TimeZone.
+type HistogramResource struct {
+	Name     string           `json:"name"`
+	Interval string           `json:"interval"`
+	TimeZone string           `json:"tz"`
+	Buckets  []BucketResource `json:"buckets"`
+}
+
+// BucketResource describes the number of events in a bucket beginning at
+// Start, an ISO8601 timestamp in the time zone of the histogram.
+type BucketResource struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+// Histogram counts the events with a given name in each bucket of a time
+// range.
+func (service *WebService) Histogram(res http.ResponseWriter, req *http.Request) {
+	name := req.FormValue("name")
+	if name == "" {
+		service.renderMissingParameter(res, req, "name")
+		return
+	}
+
+	options := usecases.HistogramOptions{Interval: req.FormValue("interval"), TimeZone: req.FormValue("tz")}
+	if options.Interval == "" {
+		service.renderMissingParameter(res, req, "interval")
+		return
+	}
+
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	buckets, err := service.EventInteractor.Histogram(tenant, name, timeRange, options)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	resource := HistogramResource{Name: name, Interval: options.Interval, TimeZone: options.TimeZone, Buckets: []BucketResource{}}
+	if resource.TimeZone == "" {
+		resource.TimeZone = "UTC"
+	}
+	for _, bucket := range buckets {
+		resource.Buckets = append(resource.Buckets, BucketResource{Start: bucket.Start.Format(time.RFC3339), Count: bucket.Count})
+	}
+	service.RenderJSON(res, resource, http.StatusOK)
+}
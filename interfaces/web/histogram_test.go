@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const histogramURL = "http://example.com/v1/events/histogram?name=login&from=2015-03-29T00:00:00Z&to=2015-03-30T23:00:00Z"
+
+func TestHistogram(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", histogramURL+"&interval=1d&tz=Europe/Dublin", nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var histogram HistogramResource
+	json.Unmarshal(response.Body.Bytes(), &histogram)
+	expected := HistogramResource{
+		Name:     "login",
+		Interval: "1d",
+		TimeZone: "Europe/Dublin",
+		Buckets: []BucketResource{
+			{Start: "2015-03-29T00:00:00Z", Count: 3},
+			{Start: "2015-03-30T00:00:00+01:00", Count: 1},
+		},
+	}
+	if response.Code != http.StatusOK || !reflect.DeepEqual(histogram, expected) {
+		t.Errorf("expected %+v, got %d %s", expected, response.Code, response.Body)
+	}
+}
+
+func TestHistogramRequiresParameters(t *testing.T) {
+	cases := []struct {
+		url   string
+		field string
+	}{
+		{"http://example.com/v1/events/histogram?interval=1d&from=2015-03-29T00:00:00Z&to=2015-03-30T23:00:00Z", "name"},
+		{histogramURL, "interval"},
+	}
+
+	for _, c := range cases {
+		service := WebService{EventInteractor: new(StubEventInteractor)}
+		request, _ := http.NewRequest("GET", c.url, nil)
+
+		response := httptest.NewRecorder()
+		service.Handler().ServeHTTP(response, request)
+		assertMatchesSpec(t, &service, request, response)
+
+		var problem ErrorResource
+		json.Unmarshal(response.Body.Bytes(), &problem)
+		if response.Code != http.StatusBadRequest || problem.Field != c.field {
+			t.Errorf("expected %s to be required, got %d %s", c.field, response.Code, response.Body)
+		}
+	}
+}
+
+func TestHistogramInvalidTimeZone(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", histogramURL+"&interval=1d&tz=Europe/Atlantis", nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "tz" || problem.Code != CodeFieldInvalid {
+		t.Errorf("expected an invalid tz, got %d %s", response.Code, response.Body)
+	}
+}
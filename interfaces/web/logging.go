@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader is the HTTP header used to accept and return request IDs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the size of a client-supplied request ID, so
+// arbitrarily large header values are not copied into every log entry.
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	apiKeyKey
+	pathParamsKey
+	deprecatedKey
+)
+
+// Fields holds the key/value pairs which make up a single log entry.
+type Fields map[string]interface{}
+
+// Logger writes structured log entries to an output stream, one JSON object
+// per line. A nil *Logger discards everything written to it.
+type Logger struct {
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+// NewLogger returns a Logger which writes to `out`.
+func NewLogger(out io.Writer) *Logger {
+	return &Logger{out: out, now: time.Now}
+}
+
+// Log writes a single entry comprising `fields` and the current time.
+func (logger *Logger) Log(fields Fields) {
+	if logger == nil {
+		return
+	}
+
+	entry := Fields{"time": logger.now().UTC().Format(time.RFC3339Nano)}
+	for key, value := range fields {
+		entry[key] = value
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.out.Write(append(line, '\n'))
+}
+
+// RequestIDFromContext returns the request ID stored in `ctx` by the
+// RequestID middleware, or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// RequestID is middleware which tags each request with an ID. The ID is taken
+// from the X-Request-ID request header when it is a valid request ID, and
+// generated otherwise.
+// It is returned in the X-Request-ID response header and made available to
+// downstream handlers through the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		res.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(req.Context(), requestIDKey, id)
+		next.ServeHTTP(res, req.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client-supplied request ID is short and
+// made only of letters, digits, dots, underscores and dashes, so that it is
+// safe to echo back and to write to logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// AccessLog is middleware which writes one log entry per request, recording
+// its method, path, response status, latency, response size and remote address.
+func AccessLog(logger *Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: res, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, req)
+
+		logger.Log(Fields{
+			"level":       "info",
+			"msg":         "request",
+			"request_id":  RequestIDFromContext(req.Context()),
+			"method":      req.Method,
+			"path":        req.URL.Path,
+			"status":      recorder.status,
+			"latency_ms":  float64(time.Since(start)) / float64(time.Millisecond),
+			"bytes":       recorder.bytes,
+			"remote_addr": req.RemoteAddr,
+		})
+	})
+}
+
+// responseRecorder wraps a http.ResponseWriter to capture the status code and
+// number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.wroteHeader = true
+	n, err := recorder.ResponseWriter.Write(data)
+	recorder.bytes += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client, so that streaming handlers
+// work through the access log.
+func (recorder *responseRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		recorder.wroteHeader = true
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerWritesJSONLines(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := NewLogger(buf)
+	logger.now = func() time.Time { return time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC) }
+
+	logger.Log(Fields{"msg": "first"})
+	logger.Log(Fields{"msg": "second"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d", len(lines))
+	}
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not valid JSON: %s", lines[0])
+	}
+	if entry["msg"] != "first" || entry["time"] != "2015-02-11T15:01:00Z" {
+		t.Errorf("unexpected log entry %s", lines[0])
+	}
+}
+
+func TestNilLoggerDiscardsEntries(t *testing.T) {
+	var logger *Logger
+	logger.Log(Fields{"msg": "ignored"})
+}
+
+func TestRequestIDIsGenerated(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		seen = RequestIDFromContext(req.Context())
+	}))
+
+	request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if seen == "" {
+		t.Error("expected request ID in context")
+	}
+	if response.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("expected response header %q, got %q", seen, response.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestIDIsPropagated(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		seen = RequestIDFromContext(req.Context())
+	}))
+
+	request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+	request.Header.Set(RequestIDHeader, "abc-123")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if seen != "abc-123" {
+		t.Errorf("expected request ID %q, got %q", "abc-123", seen)
+	}
+	if response.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Error("request ID not returned in response header")
+	}
+}
+
+func TestInvalidRequestIDIsReplaced(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+
+	for _, id := range []string{`abc" "level":"error`, "abc\ndef", "abc 123", strings.Repeat("a", maxRequestIDLength+1)} {
+		request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+		request.Header.Set(RequestIDHeader, id)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if got := response.Header().Get(RequestIDHeader); got == id || !validRequestID(got) {
+			t.Errorf("expected %q to be replaced by a generated ID, got %q", id, got)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	handler := RequestID(AccessLog(NewLogger(buf), http.HandlerFunc(service.Create)))
+
+	requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`)
+	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(RequestIDHeader, "abc-123")
+	request.RemoteAddr = "10.0.0.1:4321"
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not valid JSON: %s", buf.String())
+	}
+
+	expected := map[string]interface{}{
+		"request_id":  "abc-123",
+		"method":      "POST",
+		"path":        "/events",
+		"status":      float64(http.StatusCreated),
+		"bytes":       float64(response.Body.Len()),
+		"remote_addr": "10.0.0.1:4321",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("expected latency to be logged")
+	}
+}
+
+func TestErrorsAreLoggedWithRequestID(t *testing.T) {
+	buf := new(bytes.Buffer)
+	service := WebService{EventInteractor: new(StubEventInteractorWithCountError), Logger: NewLogger(buf)}
+	handler := RequestID(http.HandlerFunc(service.Count))
+
+	request, _ := http.NewRequest(
+		"GET",
+		"http://example.com/events/count?from=2015-02-15T00:01:00+00:00&to=2015-02-15T15:01:59+00:00",
+		nil)
+	request.Header.Set(RequestIDHeader, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not valid JSON: %s", buf.String())
+	}
+	if entry["level"] != "error" || entry["request_id"] != "abc-123" {
+		t.Errorf("unexpected log entry %s", buf.String())
+	}
+}
@@ -0,0 +1,19 @@
+package web
+
+import "net/http"
+
+// MetricsReporter reports counters describing the service's operation, such
+// as those of a count cache.
+type MetricsReporter interface {
+	Metrics() map[string]int64
+}
+
+// Metrics renders the counters of the service's MetricsReporter, or none if
+// it has no reporter.
+func (service *WebService) Metrics(res http.ResponseWriter, req *http.Request) {
+	metrics := map[string]int64{}
+	if service.MetricsReporter != nil {
+		metrics = service.MetricsReporter.Metrics()
+	}
+	service.RenderJSON(res, metrics, http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	reporter := StubMetricsReporter{"count_cache.hits": 3, "count_cache.misses": 1}
+	service := WebService{MetricsReporter: reporter}
+
+	request, _ := http.NewRequest("GET", "http://example.com/v1/metrics", nil)
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var metrics map[string]int64
+	json.Unmarshal(response.Body.Bytes(), &metrics)
+	if response.Code != http.StatusOK || !reflect.DeepEqual(metrics, map[string]int64(reporter)) {
+		t.Errorf("expected %v, got %d %s", reporter, response.Code, response.Body)
+	}
+}
+
+func TestMetricsWithoutReporter(t *testing.T) {
+	service := WebService{}
+
+	request, _ := http.NewRequest("GET", "http://example.com/v1/metrics", nil)
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK || response.Body.String() != "{}" {
+		t.Errorf("expected no metrics, got %d %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,244 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// openAPIPath is the path, relative to the API version prefix, at which the
+// OpenAPI description of the service is served.
+const openAPIPath = "/openapi.json"
+
+// commonErrorStatus lists the error responses which any authenticated
+// operation may return, in addition to those specific to the operation.
+var commonErrorStatus = []int{401, 403, 429, 500, 503, 504}
+
+// OpenAPI serves an OpenAPI 3 description of the API.
+func (service *WebService) OpenAPI(res http.ResponseWriter, req *http.Request) {
+	service.RenderJSON(res, service.OpenAPISpec(), http.StatusOK)
+}
+
+// OpenAPISpec generates an OpenAPI 3 description of the API from the same
+// operations used to route requests, with schemas derived from the resource
+// types each operation reads and writes.
+func (service *WebService) OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{
+		"ErrorResource": schemaFor(reflect.TypeOf(ErrorResource{})),
+	}
+
+	paths := map[string]interface{}{
+		apiVersionPrefix + openAPIPath: map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":     "Describe the API",
+				"operationId": "getOpenAPI",
+				"security":    []interface{}{},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OpenAPI description of the API",
+						"content":     jsonContent(map[string]interface{}{"type": "object"}),
+					},
+				},
+			},
+		},
+	}
+
+	for _, op := range service.operations() {
+		success := map[string]interface{}{"description": http.StatusText(op.status)}
+		if op.response != nil {
+			schema := schemaRef(op.response, schemas)
+			if op.alternative != nil {
+				schema = map[string]interface{}{
+					"anyOf": []interface{}{schema, schemaRef(op.alternative, schemas)},
+				}
+			}
+			success["content"] = jsonContent(schema)
+		}
+		if op.produces != nil {
+			content := map[string]interface{}{}
+			for mediaType, value := range op.produces {
+				content[mediaType] = map[string]interface{}{"schema": schemaRef(value, schemas)}
+			}
+			success["content"] = content
+		}
+		responses := map[string]interface{}{strconv.Itoa(op.status): success}
+		for _, status := range append(op.errorStatus, commonErrorStatus...) {
+			responses[strconv.Itoa(status)] = map[string]interface{}{"$ref": "#/components/responses/Problem"}
+		}
+
+		parameters := []interface{}{
+			map[string]interface{}{
+				"name":        TenantHeader,
+				"in":          "header",
+				"description": "Tenant to operate on, if the API key is not bound to one",
+				"schema":      map[string]interface{}{"type": "string"},
+			},
+		}
+		for _, segment := range splitPath(op.path) {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     strings.Trim(segment, "{}"),
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		for _, param := range op.parameters {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"required":    param.required,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+
+		spec := map[string]interface{}{
+			"summary":     op.summary,
+			"operationId": operationID(op),
+			"description": "Requires an API key with the " + op.scope + " scope.",
+			"parameters":  parameters,
+			"responses":   responses,
+		}
+		if op.request != nil {
+			spec["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaRef(op.request, schemas)),
+			}
+		}
+
+		path := apiVersionPrefix + op.path
+		if _, ok := paths[path]; !ok {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(op.method)] = spec
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "go-events-service",
+			"version": strings.TrimPrefix(apiVersionPrefix, "/"),
+		},
+		"paths": paths,
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []interface{}{}},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"responses": map[string]interface{}{
+				"Problem": map[string]interface{}{
+					"description": "The request failed",
+					"content": map[string]interface{}{
+						ProblemContentType: map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResource"},
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// operationID derives an identifier from an operation's method and path,
+// e.g. "postEvents" or "getEventsCount".
+func operationID(op operation) string {
+	id := strings.ToLower(op.method)
+	for _, segment := range splitPath(op.path) {
+		id += upperFirst(strings.Trim(segment, "{}"))
+	}
+	return id
+}
+
+// upperFirst returns `s` with its first rune in upper case.
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// schemaRef returns a schema describing the type of `value`. Named struct
+// types are added to `schemas` and referred to by name.
+func schemaRef(value interface{}, schemas map[string]interface{}) interface{} {
+	t := reflect.TypeOf(value)
+	if t.Kind() != reflect.Struct {
+		return schemaFor(t)
+	}
+	schemas[t.Name()] = schemaFor(t)
+	return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+// schemaFor derives a JSON schema from a Go type, using the same rules as
+// encoding/json. Struct fields are required unless tagged omitempty, and
+// pointers may be null.
+func schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			if field.PkgPath != "" || tag[0] == "-" {
+				continue
+			}
+
+			name := field.Name
+			if tag[0] != "" {
+				name = tag[0]
+			}
+			properties[name] = schemaFor(field.Type)
+
+			omitempty := false
+			for _, option := range tag[1:] {
+				omitempty = omitempty || option == "omitempty"
+			}
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,334 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// loadSpec fetches the OpenAPI description served by `service`, decoded as
+// plain JSON values so it is checked exactly as clients would see it.
+func loadSpec(t *testing.T, service *WebService) map[string]interface{} {
+	request, _ := http.NewRequest("GET", "http://example.com/v1/openapi.json", nil)
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected response code %d, got %d", http.StatusOK, response.Code)
+	}
+
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %s", err)
+	}
+	return spec
+}
+
+// assertMatchesSpec checks that a request served by `service`, and the
+// response recorded for it, are both described by the service's OpenAPI
+// description. Unversioned paths are checked against their /v1 equivalent,
+// and paths with parameters against the template they match.
+func assertMatchesSpec(t *testing.T, service *WebService, request *http.Request, response *httptest.ResponseRecorder) {
+	t.Helper()
+	spec := loadSpec(t, service)
+
+	path := request.URL.Path
+	if !strings.HasPrefix(path, apiVersionPrefix+"/") {
+		path = apiVersionPrefix + path
+	}
+
+	pathItem, ok := lookup(spec, "paths", path).(map[string]interface{})
+	for template, item := range lookup(spec, "paths").(map[string]interface{}) {
+		if _, matches := (route{segments: splitPath(template)}).match(splitPath(path)); matches && !ok {
+			pathItem, ok = item.(map[string]interface{})
+		}
+	}
+	if !ok {
+		t.Fatalf("spec does not describe path %s", path)
+	}
+	op, ok := pathItem[strings.ToLower(request.Method)].(map[string]interface{})
+	if !ok {
+		t.Fatalf("spec does not describe %s %s", request.Method, path)
+	}
+
+	succeeded := response.Code < http.StatusBadRequest
+
+	declared := map[string]bool{}
+	params, _ := op["parameters"].([]interface{})
+	for _, p := range params {
+		param := p.(map[string]interface{})
+		if param["in"] != "query" {
+			continue
+		}
+		name := param["name"].(string)
+		declared[name] = true
+		if succeeded && param["required"] == true && request.URL.Query().Get(name) == "" {
+			t.Errorf("%s %s succeeded without required parameter %q", request.Method, path, name)
+		}
+	}
+	for name := range request.URL.Query() {
+		if !declared[name] {
+			t.Errorf("spec does not describe query parameter %q of %s %s", name, request.Method, path)
+		}
+	}
+
+	if body, ok := op["requestBody"].(map[string]interface{}); ok && succeeded && request.GetBody != nil {
+		reader, _ := request.GetBody()
+		data, _ := ioutil.ReadAll(reader)
+		schema := lookup(body, "content", "application/json", "schema")
+		if err := validateJSON(spec, schema, data); err != nil {
+			t.Errorf("request body of %s %s does not match spec: %s", request.Method, path, err)
+		}
+	}
+
+	described, ok := lookup(op, "responses", strconv.Itoa(response.Code)).(map[string]interface{})
+	if !ok {
+		t.Fatalf("spec does not describe status %d for %s %s", response.Code, request.Method, path)
+	}
+	described = resolve(spec, described)
+
+	if described["content"] == nil {
+		if response.Body.Len() > 0 {
+			t.Errorf("spec describes no body for status %d of %s %s, got %s", response.Code, request.Method, path, response.Body)
+		}
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header().Get("Content-Type"))
+	schema := lookup(described, "content", mediaType, "schema")
+	if schema == nil {
+		t.Fatalf("spec does not describe %s responses with status %d for %s %s", mediaType, response.Code, request.Method, path)
+	}
+	if err := validateBody(spec, mediaType, schema, response.Body.Bytes()); err != nil {
+		t.Errorf("response body of %s %s does not match spec: %s", request.Method, path, err)
+	}
+}
+
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// resolve follows a local "$ref" within the spec, if `object` is one.
+func resolve(spec map[string]interface{}, object map[string]interface{}) map[string]interface{} {
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return object
+	}
+	resolved, _ := lookup(spec, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...).(map[string]interface{})
+	return resolved
+}
+
+// validateBody checks a response body against `schema`. Newline-delimited
+// JSON bodies are checked a line at a time, and bodies of other media types
+// which are not JSON are not checked.
+func validateBody(spec map[string]interface{}, mediaType string, schema interface{}, data []byte) error {
+	switch mediaType {
+	case NDJSONContentType:
+		for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if err := validateJSON(spec, schema, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "application/json", ProblemContentType:
+		return validateJSON(spec, schema, data)
+	}
+	return nil
+}
+
+func validateJSON(spec map[string]interface{}, schema interface{}, data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return validate(spec, schema, value, "$")
+}
+
+// validate checks `value` against the subset of JSON schema used by the
+// generated spec: types, nullable, anyOf, properties, required and
+// additionalProperties.
+func validate(spec map[string]interface{}, s interface{}, value interface{}, at string) error {
+	schema, ok := s.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: no schema", at)
+	}
+	schema = resolve(spec, schema)
+
+	if value == nil && schema["nullable"] == true {
+		return nil
+	}
+
+	if alternatives, ok := schema["anyOf"].([]interface{}); ok {
+		var err error
+		for _, alternative := range alternatives {
+			if err = validate(spec, alternative, value, at); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	switch schema["type"] {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %v", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %v", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %v", at, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", at, value)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %v", at, value)
+		}
+		for i, item := range items {
+			if err := validate(spec, schema["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %v", at, value)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, property := range object {
+			propertySchema, ok := properties[name]
+			if !ok {
+				propertySchema = schema["additionalProperties"]
+			}
+			if propertySchema == false {
+				return fmt.Errorf("%s: unexpected property %q", at, name)
+			}
+			if propertySchema == nil {
+				continue
+			}
+			if err := validate(spec, propertySchema, property, at+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	spec := loadSpec(t, &service)
+
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("expected OpenAPI version 3.0.3, got %v", spec["openapi"])
+	}
+
+	for _, op := range service.operations() {
+		path := apiVersionPrefix + op.path
+		if lookup(spec, "paths", path, strings.ToLower(op.method)) == nil {
+			t.Errorf("spec does not describe %s %s", op.method, path)
+		}
+	}
+
+	paths := lookup(spec, "paths").(map[string]interface{})
+	var described []string
+	for path := range paths {
+		described = append(described, path)
+	}
+	sort.Strings(described)
+	for _, path := range described {
+		request, _ := http.NewRequest("OPTIONS", "http://example.com"+path, nil)
+		response := httptest.NewRecorder()
+		service.Handler().ServeHTTP(response, request)
+		if response.Code == http.StatusNotFound {
+			t.Errorf("spec describes %s, which is not routed", path)
+		}
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/events":              "getEvents",
+		"/events/{name}/count": "getEventsNameCount",
+		"/éclairs":             "getÉclairs",
+	} {
+		if id := operationID(operation{method: "GET", path: path}); id != expected {
+			t.Errorf("expected %s for %s, got %s", expected, path, id)
+		}
+	}
+}
+
+func TestOpenAPIDescribesResources(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	spec := loadSpec(t, &service)
+
+	for _, name := range []string{"EventResource", "ErrorResource", "KeyRequestResource", "KeyResource"} {
+		if lookup(spec, "components", "schemas", name) == nil {
+			t.Errorf("spec does not describe schema %s", name)
+		}
+	}
+
+	required := lookup(spec, "components", "schemas", "EventResource", "required")
+	expected := []interface{}{"name", "timestamp"}
+	if fmt.Sprint(required) != fmt.Sprint(expected) {
+		t.Errorf("expected EventResource to require %v, got %v", expected, required)
+	}
+
+	params := lookup(spec, "paths", "/v1/events/count", "get", "parameters").([]interface{})
+	var names []string
+	for _, p := range params {
+		param := p.(map[string]interface{})
+		if param["in"] == "query" {
+			names = append(names, param["name"].(string))
+		}
+	}
+	if strings.Join(names, ",") != "from,to,bounds,compare" {
+		t.Errorf("expected query parameters from,to,bounds,compare, got %v", names)
+	}
+}
+
+func TestValidateRejectsMismatchedBodies(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	spec := loadSpec(t, &service)
+	schema := map[string]interface{}{"$ref": "#/components/schemas/EventResource"}
+
+	bodies := []string{
+		`{"name": "test"}`,
+		`{"name": 1, "timestamp": "2015-02-11T15:01:00+00:00"}`,
+		`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00", "extra": true}`,
+	}
+	for _, body := range bodies {
+		if err := validateJSON(spec, schema, []byte(body)); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// Classes of request which are rate limited separately.
+const (
+	ReadRequests  = "read"
+	WriteRequests = "write"
+)
+
+// RateLimiter decides whether a client identified by `key` may make another
+// request under `limit`. When it may not, Allow returns how long the client
+// should wait before retrying.
+type RateLimiter interface {
+	Allow(key string, limit domain.RateLimit) (bool, time.Duration, error)
+}
+
+// maxBuckets is the number of buckets a MemoryRateLimiter holds at most.
+// Once it is reached, the least recently used bucket is discarded for each
+// new one, granting its client a full bucket should it return.
+const maxBuckets = 10000
+
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+
+	// full is when the bucket will have refilled completely, under the
+	// limit it was last used with
+	full time.Time
+}
+
+// MemoryRateLimiter is a token bucket RateLimiter which keeps its state in
+// memory, and so only limits the requests handled by a single process.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: map[string]*list.Element{}, lru: list.New(), now: time.Now}
+}
+
+func (limiter *MemoryRateLimiter) Allow(key string, limit domain.RateLimit) (bool, time.Duration, error) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	limiter.prune(now)
+
+	element, ok := limiter.buckets[key]
+	if ok {
+		limiter.lru.MoveToFront(element)
+	} else {
+		for limiter.lru.Len() >= maxBuckets {
+			limiter.remove(limiter.lru.Back())
+		}
+		element = limiter.lru.PushFront(&bucket{key: key, tokens: float64(limit.Burst), updated: now})
+		limiter.buckets[key] = element
+	}
+	b := element.Value.(*bucket)
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((float64(limit.Burst) - b.tokens) / limit.Rate))
+
+	if !allowed {
+		return false, seconds((1 - b.tokens) / limit.Rate), nil
+	}
+	return true, 0, nil
+}
+
+// prune discards the least recently used buckets while they have refilled
+// completely by `now`, since they behave exactly like a newly created
+// bucket.
+func (limiter *MemoryRateLimiter) prune(now time.Time) {
+	for element := limiter.lru.Back(); element != nil && !element.Value.(*bucket).full.After(now); element = limiter.lru.Back() {
+		limiter.remove(element)
+	}
+}
+
+func (limiter *MemoryRateLimiter) remove(element *list.Element) {
+	limiter.lru.Remove(element)
+	delete(limiter.buckets, element.Value.(*bucket).key)
+}
+
+func seconds(n float64) time.Duration {
+	return time.Duration(n * float64(time.Second))
+}
+
+// RateLimit is middleware which only calls `next` if the client's address
+// has not exceeded the service default limit for the given class of
+// request. It runs before the client's API key is checked, so that keys
+// cannot be guessed faster than the default limit allows. Requests are not
+// limited if the service has no RateLimiter or limit.
+func (service *WebService) RateLimit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if service.allow(res, req, "ip:"+clientIP(req), class, service.defaultLimit(class)) {
+			next(res, req)
+		}
+	}
+}
+
+// RateLimitKey is middleware which only calls `next` if the authenticated
+// API key has not exceeded its limit for the given class of request. The
+// limit configured on the key takes precedence over the service default.
+// Requests without a key are left to RateLimit.
+func (service *WebService) RateLimitKey(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		key, ok := APIKeyFromContext(req.Context())
+		if !ok {
+			next(res, req)
+			return
+		}
+
+		limit := service.defaultLimit(class)
+		if class == ReadRequests && !key.ReadLimit.IsZero() {
+			limit = key.ReadLimit
+		} else if class == WriteRequests && !key.WriteLimit.IsZero() {
+			limit = key.WriteLimit
+		}
+
+		if service.allow(res, req, "key:"+key.Hash, class, limit) {
+			next(res, req)
+		}
+	}
+}
+
+func (service *WebService) defaultLimit(class string) domain.RateLimit {
+	if class == WriteRequests {
+		return service.WriteLimit
+	}
+	return service.ReadLimit
+}
+
+// allow charges the bucket of `client` for the given class of request,
+// rendering a problem and returning false if it is empty.
+func (service *WebService) allow(res http.ResponseWriter, req *http.Request, client, class string, limit domain.RateLimit) bool {
+	if service.RateLimiter == nil || limit.IsZero() {
+		return true
+	}
+
+	allowed, retryAfter, err := service.RateLimiter.Allow(client+":"+class, limit)
+	if err != nil {
+		// fail open, so that losing the limiter's state does not take
+		// the service down with it
+		service.logError(req, err)
+		return true
+	}
+
+	if !allowed {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		res.Header().Set("Retry-After", strconv.Itoa(seconds))
+		service.RenderProblem(res, req, NewProblem(
+			http.StatusTooManyRequests,
+			CodeRateLimitExceeded,
+			fmt.Sprintf("Retry after %d seconds", seconds)))
+		return false
+	}
+	return true
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
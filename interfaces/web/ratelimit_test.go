@@ -0,0 +1,261 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.now = clock.now.Add(d)
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter()
+	limiter.now = clock.Now
+	limit := domain.RateLimit{Rate: 2, Burst: 3}
+
+	// the bucket starts full, allowing a burst
+	for i := 0; i < 3; i++ {
+		if allowed, _, _ := limiter.Allow("client", limit); !allowed {
+			t.Errorf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	allowed, retryAfter, _ := limiter.Allow("client", limit)
+	if allowed {
+		t.Error("expected request to be rejected once the bucket is empty")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("expected retry after %v, got %v", 500*time.Millisecond, retryAfter)
+	}
+
+	// other clients have their own bucket
+	if allowed, _, _ := limiter.Allow("other", limit); !allowed {
+		t.Error("expected request from another client to be allowed")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if allowed, _, _ := limiter.Allow("client", limit); !allowed {
+		t.Error("expected request to be allowed after the bucket refills")
+	}
+	if allowed, _, _ := limiter.Allow("client", limit); allowed {
+		t.Error("expected bucket to hold one token only")
+	}
+}
+
+func TestMemoryRateLimiterPrunesFullBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter()
+	limiter.now = clock.Now
+	limit := domain.RateLimit{Rate: 1, Burst: 1}
+
+	for i := 0; i < maxBuckets; i++ {
+		limiter.Allow(string(rune(i)), limit)
+	}
+
+	clock.Advance(time.Second)
+	limiter.Allow("new", limit)
+
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected refilled buckets to be pruned, %d remain", len(limiter.buckets))
+	}
+}
+
+func TestMemoryRateLimiterPrunesBucketsByTheirOwnLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter()
+	limiter.now = clock.Now
+	slow := domain.RateLimit{Rate: 0.1, Burst: 1}
+
+	limiter.Allow("slow", slow)
+	clock.Advance(time.Second)
+	limiter.Allow("fast", domain.RateLimit{Rate: 10, Burst: 10})
+
+	// the slow bucket would be full under the fast limit, but not its own
+	if allowed, _, _ := limiter.Allow("slow", slow); allowed {
+		t.Error("expected the slow bucket to be kept until it refills")
+	}
+}
+
+func TestMemoryRateLimiterEvictsLeastRecentlyUsedBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2015, 2, 11, 15, 1, 0, 0, time.UTC)}
+	limiter := NewMemoryRateLimiter()
+	limiter.now = clock.Now
+	limit := domain.RateLimit{Rate: 1, Burst: 1}
+
+	for i := 0; i < maxBuckets; i++ {
+		limiter.Allow(string(rune(i)), limit)
+	}
+	limiter.Allow(string(rune(0)), limit)
+	limiter.Allow("new", limit)
+
+	if len(limiter.buckets) != maxBuckets {
+		t.Errorf("expected %d buckets, got %d", maxBuckets, len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets[string(rune(1))]; ok {
+		t.Error("expected the least recently used bucket to be evicted")
+	}
+	if _, ok := limiter.buckets[string(rune(0))]; !ok {
+		t.Error("expected a recently used bucket to be kept")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		RateLimiter:     NewMemoryRateLimiter(),
+		ReadLimit:       domain.RateLimit{Rate: 0.5, Burst: 2},
+	}
+	handler := service.RateLimit(ReadRequests, service.Count)
+
+	expected := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for _, status := range expected {
+		request, _ := http.NewRequest(
+			"GET",
+			"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+			nil)
+		request.RemoteAddr = "10.0.0.1:4321"
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		if response.Code != status {
+			t.Errorf("expected response code %d, got %d", status, response.Code)
+		}
+		if status == http.StatusTooManyRequests && response.Header().Get("Retry-After") != "2" {
+			t.Errorf("expected Retry-After %q, got %q", "2", response.Header().Get("Retry-After"))
+		}
+	}
+
+	// a different client address is limited separately
+	request, _ := http.NewRequest(
+		"GET",
+		"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+		nil)
+	request.RemoteAddr = "10.0.0.2:4321"
+	response := httptest.NewRecorder()
+	handler(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("expected response code %d, got %d", http.StatusOK, response.Code)
+	}
+}
+
+func TestRateLimitSeparatesReadsAndWrites(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		RateLimiter:     NewMemoryRateLimiter(),
+		ReadLimit:       domain.RateLimit{Rate: 1, Burst: 1},
+		WriteLimit:      domain.RateLimit{Rate: 1, Burst: 1},
+	}
+	read := service.RateLimit(ReadRequests, func(res http.ResponseWriter, req *http.Request) {})
+	write := service.RateLimit(WriteRequests, func(res http.ResponseWriter, req *http.Request) {})
+
+	for i, handler := range []http.HandlerFunc{read, write, read, write} {
+		request, _ := http.NewRequest("GET", "http://example.com/events", nil)
+		request.RemoteAddr = "10.0.0.1:4321"
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		expected := http.StatusOK
+		if i >= 2 {
+			expected = http.StatusTooManyRequests
+		}
+		if response.Code != expected {
+			t.Errorf("request %d: expected response code %d, got %d", i+1, expected, response.Code)
+		}
+	}
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		AuthInteractor:  new(StubAuthInteractor),
+		RateLimiter:     NewMemoryRateLimiter(),
+		ReadLimit:       domain.RateLimit{Rate: 10, Burst: 10},
+	}
+	handler := service.RequireScope(usecases.ScopeRead, service.RateLimitKey(ReadRequests, service.Count))
+
+	cases := []struct {
+		key            string
+		expectedStatus int
+	}{
+		{"limited-secret", http.StatusOK},
+		{"limited-secret", http.StatusTooManyRequests},
+		{"secret", http.StatusOK},
+		{"secret", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest(
+			"GET",
+			"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+			nil)
+		request.Header.Set("Authorization", "Bearer "+c.key)
+		request.RemoteAddr = "10.0.0.1:4321"
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		if response.Code != c.expectedStatus {
+			t.Errorf("%s: expected response code %d, got %d", c.key, c.expectedStatus, response.Code)
+		}
+	}
+}
+
+func TestRateLimitAppliesBeforeAuthentication(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		AuthInteractor:  new(StubAuthInteractor),
+		RateLimiter:     NewMemoryRateLimiter(),
+		ReadLimit:       domain.RateLimit{Rate: 1, Burst: 2},
+	}
+	handler := service.RateLimit(ReadRequests, service.RequireScope(usecases.ScopeRead, service.RateLimitKey(ReadRequests, service.Count)))
+
+	// guessed keys are charged to the client's address
+	expected := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i, status := range expected {
+		request, _ := http.NewRequest("GET", "http://example.com/events/count", nil)
+		request.Header.Set("Authorization", "Bearer guess-"+strconv.Itoa(i))
+		request.RemoteAddr = "10.0.0.1:4321"
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		if response.Code != status {
+			t.Errorf("request %d: expected response code %d, got %d", i+1, status, response.Code)
+		}
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	service := WebService{
+		EventInteractor: new(StubEventInteractor),
+		RateLimiter:     new(StubRateLimiterWithError),
+		ReadLimit:       domain.RateLimit{Rate: 1, Burst: 1},
+	}
+	handler := service.RateLimit(ReadRequests, service.Count)
+
+	request, _ := http.NewRequest(
+		"GET",
+		"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+		nil)
+	response := httptest.NewRecorder()
+	handler(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("expected response code %d, got %d", http.StatusOK, response.Code)
+	}
+}
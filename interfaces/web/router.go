@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// Router dispatches requests to handlers by method and path. Patterns are
+// paths whose segments may be parameters, written as `{name}`, which match
+// any single non-empty segment. Requests for unknown paths are answered with
+// a 404 problem, and requests using an unsupported method with a 405 problem
+// listing the allowed methods. OPTIONS requests are answered automatically.
+type Router struct {
+	routes []route
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers `handler` for requests with the given method and a path
+// matching `pattern`.
+func (router *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	router.routes = append(router.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (router *Router) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	segments := splitPath(req.URL.Path)
+
+	allowed := []string{}
+	for _, r := range router.routes {
+		params, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+
+		if r.method == req.Method {
+			ctx := context.WithValue(req.Context(), pathParamsKey, params)
+			r.handler(res, req.WithContext(ctx))
+			return
+		}
+		allowed = append(allowed, r.method)
+	}
+
+	if len(allowed) == 0 {
+		writeProblem(res, req, NewProblem(
+			http.StatusNotFound,
+			CodeRouteNotFound,
+			fmt.Sprintf("No resource exists at %s", req.URL.Path)))
+		return
+	}
+
+	allowed = append(allowed, "OPTIONS")
+	sort.Strings(allowed)
+	res.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	if req.Method == "OPTIONS" {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeProblem(res, req, NewProblem(
+		http.StatusMethodNotAllowed,
+		CodeMethodNotAllowed,
+		fmt.Sprintf("%s is not supported by %s", req.Method, req.URL.Path)))
+}
+
+// match reports whether the route's pattern matches a request path split into
+// `segments`, returning the values of any path parameters.
+func (r route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// PathParam returns the value of the named path parameter matched by the
+// Router, or an empty string if there is none.
+func PathParam(req *http.Request, name string) string {
+	params, _ := req.Context().Value(pathParamsKey).(map[string]string)
+	return params[name]
+}
+
+// deprecated wraps the handler of an unversioned route, marking its responses
+// as deprecated in favour of the same path under /v1, and the request so that
+// handlers can keep the behaviour the route had before /v1.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Deprecation", "true")
+		res.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, apiVersionPrefix, req.URL.Path))
+		next(res, req.WithContext(context.WithValue(req.Context(), deprecatedKey, true)))
+	}
+}
+
+// isDeprecated reports whether a request was made to an unversioned route.
+func isDeprecated(req *http.Request) bool {
+	deprecated, _ := req.Context().Value(deprecatedKey).(bool)
+	return deprecated
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterDispatchesByMethodAndPath(t *testing.T) {
+	var called string
+	router := NewRouter()
+	router.Handle("GET", "/things", func(res http.ResponseWriter, req *http.Request) { called = "list" })
+	router.Handle("POST", "/things", func(res http.ResponseWriter, req *http.Request) { called = "create" })
+	router.Handle("GET", "/things/count", func(res http.ResponseWriter, req *http.Request) { called = "count" })
+
+	cases := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/things", "list"},
+		{"GET", "/things/", "list"},
+		{"POST", "/things", "create"},
+		{"GET", "/things/count", "count"},
+	}
+
+	for _, c := range cases {
+		called = ""
+		request, _ := http.NewRequest(c.method, "http://example.com"+c.path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		if called != c.expected {
+			t.Errorf("%s %s: expected handler %q, got %q", c.method, c.path, c.expected, called)
+		}
+	}
+}
+
+func TestRouterPathParameters(t *testing.T) {
+	var id, field string
+	router := NewRouter()
+	router.Handle("GET", "/things/{id}/{field}", func(res http.ResponseWriter, req *http.Request) {
+		id = PathParam(req, "id")
+		field = PathParam(req, "field")
+	})
+
+	request, _ := http.NewRequest("GET", "http://example.com/things/42/name", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if id != "42" || field != "name" {
+		t.Errorf("expected path parameters 42 and name, got %q and %q", id, field)
+	}
+
+	request, _ = http.NewRequest("GET", "http://example.com/things//name", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected empty parameter not to match, got %d", response.Code)
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+	router := NewRouter()
+	router.Handle("GET", "/things", func(res http.ResponseWriter, req *http.Request) {})
+
+	request, _ := http.NewRequest("GET", "http://example.com/other", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected response code %d, got %d", http.StatusNotFound, response.Code)
+	}
+
+	problem := ErrorResource{}
+	if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil || problem.Code != CodeRouteNotFound {
+		t.Errorf("expected JSON problem, got %s", response.Body.String())
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+	router.Handle("GET", "/things", func(res http.ResponseWriter, req *http.Request) {})
+	router.Handle("POST", "/things", func(res http.ResponseWriter, req *http.Request) {})
+
+	request, _ := http.NewRequest("DELETE", "http://example.com/things", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected response code %d, got %d", http.StatusMethodNotAllowed, response.Code)
+	}
+	if allow := response.Header().Get("Allow"); allow != "GET, OPTIONS, POST" {
+		t.Errorf("expected Allow header %q, got %q", "GET, OPTIONS, POST", allow)
+	}
+
+	problem := ErrorResource{}
+	if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil || problem.Code != CodeMethodNotAllowed {
+		t.Errorf("expected JSON problem, got %s", response.Body.String())
+	}
+}
+
+func TestRouterOptions(t *testing.T) {
+	router := NewRouter()
+	router.Handle("GET", "/things", func(res http.ResponseWriter, req *http.Request) {})
+
+	request, _ := http.NewRequest("OPTIONS", "http://example.com/things", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNoContent {
+		t.Errorf("expected response code %d, got %d", http.StatusNoContent, response.Code)
+	}
+	if allow := response.Header().Get("Allow"); allow != "GET, OPTIONS" {
+		t.Errorf("expected Allow header %q, got %q", "GET, OPTIONS", allow)
+	}
+}
+
+func TestHandlerServesVersionedAndDeprecatedPaths(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	handler := service.Handler()
+
+	cases := []struct {
+		path       string
+		deprecated bool
+	}{
+		{"/v1/events", false},
+		{"/events", true},
+	}
+
+	for _, c := range cases {
+		requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`)
+		request, _ := http.NewRequest("POST", "http://example.com"+c.path, requestBody)
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if response.Code != http.StatusCreated {
+			t.Errorf("%s: expected response code %d, got %d", c.path, http.StatusCreated, response.Code)
+		}
+		if (response.Header().Get("Deprecation") != "") != c.deprecated {
+			t.Errorf("%s: unexpected Deprecation header %q", c.path, response.Header().Get("Deprecation"))
+		}
+		if c.deprecated && response.Header().Get("Link") != `</v1/events>; rel="successor-version"` {
+			t.Errorf("%s: unexpected Link header %q", c.path, response.Header().Get("Link"))
+		}
+		if response.Header().Get(RequestIDHeader) == "" {
+			t.Errorf("%s: expected request ID", c.path)
+		}
+	}
+}
+
+func TestHandlerOnlyAliasesPathsServedBeforeVersioning(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	handler := service.Handler()
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/events/count?from=2015-02-11T15:01:00Z&to=2015-02-11T15:02:00Z", http.StatusOK},
+		{"/events/top?from=2015-02-11T15:01:00Z&to=2015-02-11T15:02:00Z", http.StatusNotFound},
+		{"/alerts", http.StatusNotFound},
+		{"/metrics", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest("GET", "http://example.com"+c.path, nil)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if response.Code != c.status {
+			t.Errorf("%s: expected response code %d, got %d", c.path, c.status, response.Code)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// apiVersionPrefix is the path under which the current version of the API
+// is served.
+const apiVersionPrefix = "/v1"
+
+// parameter describes a query parameter accepted by an operation.
+type parameter struct {
+	name        string
+	description string
+	required    bool
+}
+
+// operation describes an endpoint of the API, both for routing requests to
+// it and for generating its OpenAPI description. Successful responses are a
+// JSON encoding of `response`, or of `alternative` if set and the request's
+// parameters call for it, unless `produces` maps other media types to the
+// value each encodes, or are empty if none is set. Operations which were
+// served before the API was versioned are `unversioned`: they remain
+// available at their original path, as a deprecated alias.
+type operation struct {
+	method      string
+	path        string
+	summary     string
+	scope       string
+	class       string
+	handler     http.HandlerFunc
+	parameters  []parameter
+	request     interface{}
+	status      int
+	response    interface{}
+	alternative interface{}
+	produces    map[string]interface{}
+	errorStatus []int
+	unversioned bool
+}
+
+// operations returns a description of every authenticated endpoint served
+// by the service, relative to the API version prefix.
+func (service *WebService) operations() []operation {
+	return []operation{
+		{
+			method:      "POST",
+			path:        "/events",
+			summary:     "Record an event",
+			scope:       usecases.ScopeWrite,
+			class:       WriteRequests,
+			handler:     service.Create,
+			request:     EventResource{},
+			status:      http.StatusCreated,
+			response:    map[string]string{},
+			errorStatus: []int{400, 413, 415},
+			unversioned: true,
+		},
+		{
+			method:      "POST",
+			path:        "/events/batch",
+			summary:     "Record a batch of up to 1000 events, or none if any is invalid",
+			scope:       usecases.ScopeWrite,
+			class:       WriteRequests,
+			handler:     service.CreateBatch,
+			request:     BatchResource{},
+			status:      http.StatusCreated,
+			response:    BatchResultResource{},
+			errorStatus: []int{400, 413, 415},
+		},
+		{
+			method:  "GET",
+			path:    "/events/count",
+			summary: "Count events of each name in a time range",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Count,
+			parameters: withTimeRange(
+				parameter{"compare", "Compare each count with that of the previous_period or previous_week", false},
+			),
+			status:      http.StatusOK,
+			response:    map[string]int{},
+			alternative: map[string]ComparisonResource{},
+			errorStatus: []int{400},
+			unversioned: true,
+		},
+		{
+			method:  "GET",
+			path:    "/events/top",
+			summary: "List the most frequent event names in a time range, or the biggest movers",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Top,
+			parameters: withTimeRange(
+				parameter{"limit", "Number of names to list, from 1 to 1000 (default 10)", false},
+				parameter{"movers", "If true, rank names by the change in their counts from the previous range of the same length", false},
+			),
+			status:      http.StatusOK,
+			response:    TopResource{},
+			errorStatus: []int{400},
+		},
+		{
+			method:  "GET",
+			path:    "/events/unique",
+			summary: "Estimate the number of distinct actors of events in a time range",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Unique,
+			parameters: withTimeRange(
+				parameter{"name", "Name of the events whose actors are counted", true},
+			),
+			status:      http.StatusOK,
+			response:    UniqueResource{},
+			errorStatus: []int{400, 501},
+		},
+		{
+			method:  "GET",
+			path:    "/events/histogram",
+			summary: "Count events in each bucket of a time range, aligned to a time zone",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Histogram,
+			parameters: withTimeRange(
+				parameter{"name", "Name of the events which are counted", true},
+				parameter{"interval", "Length of each bucket: minutes, hours, or calendar days, weeks or months, such as 15m, 1h, 1d, 1w or 1M", true},
+				parameter{"tz", "IANA time zone the buckets and relative times are aligned to, such as Europe/Dublin (default UTC)", false},
+			),
+			status:      http.StatusOK,
+			response:    HistogramResource{},
+			errorStatus: []int{400},
+		},
+		{
+			method:  "GET",
+			path:    "/events/aggregate",
+			summary: "Aggregate the values of events in a time range",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Aggregate,
+			parameters: withTimeRange(
+				parameter{"name", "Name of the events whose values are aggregated", true},
+				parameter{"fn", "Aggregate function: sum, avg, min, max, p50, p95 or p99", true},
+			),
+			status:      http.StatusOK,
+			response:    AggregateResource{},
+			errorStatus: []int{400, 501},
+		},
+		{
+			method:  "GET",
+			path:    "/events/export",
+			summary: "Export events in a time range",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Export,
+			parameters: withTimeRange(
+				parameter{"name", "Only export events with this name", false},
+				parameter{"format", "Format of the export, ndjson (the default) or csv", false},
+			),
+			status: http.StatusOK,
+			produces: map[string]interface{}{
+				NDJSONContentType: EventResource{},
+				"text/csv":        "",
+			},
+			errorStatus: []int{400, 501},
+		},
+		{
+			method:  "GET",
+			path:    "/events/stream",
+			summary: "Stream events as they are stored, as Server-Sent Events",
+			scope:   usecases.ScopeRead,
+			class:   ReadRequests,
+			handler: service.Stream,
+			parameters: []parameter{
+				{"name", "Only stream events with this name", false},
+				{"pattern", "Only stream events with names matching this glob pattern", false},
+				{"last_event_id", "Resume after the event with this ID, as the Last-Event-ID header does", false},
+			},
+			status:      http.StatusOK,
+			produces:    map[string]interface{}{EventStreamContentType: ""},
+			errorStatus: []int{400, 501},
+		},
+		{
+			method:      "POST",
+			path:        "/keys",
+			summary:     "Issue an API key",
+			scope:       usecases.ScopeAdmin,
+			class:       WriteRequests,
+			handler:     service.CreateKey,
+			request:     KeyRequestResource{},
+			status:      http.StatusCreated,
+			response:    KeyResource{},
+			errorStatus: []int{400, 413, 415, 501},
+			unversioned: true,
+		},
+		{
+			method:   "GET",
+			path:     "/metrics",
+			summary:  "Report counters describing the service's operation",
+			scope:    usecases.ScopeAdmin,
+			class:    ReadRequests,
+			handler:  service.Metrics,
+			status:   http.StatusOK,
+			response: map[string]int64{},
+		},
+		{
+			method:      "POST",
+			path:        "/alerts",
+			summary:     "Create an alert rule",
+			scope:       usecases.ScopeAlerts,
+			class:       WriteRequests,
+			handler:     service.CreateAlertRule,
+			request:     AlertRuleRequestResource{},
+			status:      http.StatusCreated,
+			response:    AlertRuleResource{},
+			errorStatus: []int{400, 413, 415, 501},
+		},
+		{
+			method:      "GET",
+			path:        "/alerts",
+			summary:     "List alert rules",
+			scope:       usecases.ScopeAlerts,
+			class:       ReadRequests,
+			handler:     service.ListAlertRules,
+			status:      http.StatusOK,
+			response:    AlertRuleListResource{},
+			errorStatus: []int{501},
+		},
+		{
+			method:      "GET",
+			path:        "/alerts/{id}",
+			summary:     "Describe an alert rule",
+			scope:       usecases.ScopeAlerts,
+			class:       ReadRequests,
+			handler:     service.GetAlertRule,
+			status:      http.StatusOK,
+			response:    AlertRuleResource{},
+			errorStatus: []int{404, 501},
+		},
+		{
+			method:      "PUT",
+			path:        "/alerts/{id}",
+			summary:     "Replace an alert rule",
+			scope:       usecases.ScopeAlerts,
+			class:       WriteRequests,
+			handler:     service.UpdateAlertRule,
+			request:     AlertRuleRequestResource{},
+			status:      http.StatusOK,
+			response:    AlertRuleResource{},
+			errorStatus: []int{400, 404, 413, 415, 501},
+		},
+		{
+			method:      "DELETE",
+			path:        "/alerts/{id}",
+			summary:     "Delete an alert rule",
+			scope:       usecases.ScopeAlerts,
+			class:       WriteRequests,
+			handler:     service.DeleteAlertRule,
+			status:      http.StatusNoContent,
+			errorStatus: []int{404, 501},
+		},
+	}
+}
+
+// Handler returns an http.Handler serving every endpoint of the service
+// under /v1, with each request tagged with an ID and logged. The paths
+// served before /v1 remain available as deprecated aliases.
+func (service *WebService) Handler() http.Handler {
+	router := NewRouter()
+	for _, op := range service.operations() {
+		handler := service.RateLimit(op.class, service.RequireScope(op.scope, service.RateLimitKey(op.class, op.handler)))
+		router.Handle(op.method, apiVersionPrefix+op.path, handler)
+		if op.unversioned {
+			router.Handle(op.method, op.path, deprecated(handler))
+		}
+	}
+
+	router.Handle("GET", apiVersionPrefix+openAPIPath, service.OpenAPI)
+
+	return RequestID(AccessLog(service.Logger, router))
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+const (
+	EventStreamContentType = "text/event-stream"
+
+	// LastEventIDHeader is sent by clients resuming an event stream.
+	LastEventIDHeader = "Last-Event-ID"
+)
+
+// StreamHeartbeat is how often a comment is sent on an idle event stream,
+// so that clients and proxies do not mistake it for a dead connection.
+var StreamHeartbeat = 15 * time.Second
+
+// Stream pushes each newly stored event to the client as a Server-Sent
+// Event, optionally only those with a given name or matching a glob
+// pattern. Each message carries the event's ID, so that a reconnecting
+// client sending it in the Last-Event-ID header, or the last_event_id query
+// parameter, receives the events it missed.
+func (service *WebService) Stream(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		service.renderError(res, req, fmt.Errorf("response writer %T cannot stream", res))
+		return
+	}
+
+	lastEventID := req.Header.Get(LastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = req.FormValue("last_event_id")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		var err error
+		if afterID, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || afterID < 0 {
+			service.renderInvalidParams(res, req, []InvalidParam{{Name: LastEventIDHeader, Reason: "must be an event ID"}})
+			return
+		}
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	filter := usecases.EventFilter{Name: req.FormValue("name"), Pattern: req.FormValue("pattern")}
+	sub, err := service.EventInteractor.StreamEvents(tenant, filter, afterID)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+	defer sub.Close()
+
+	res.Header().Set("Content-Type", EventStreamContentType)
+	res.Header().Set("Cache-Control", "no-cache")
+	// prevent proxies such as nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		ctx, cancel := context.WithTimeout(req.Context(), StreamHeartbeat)
+		event, err := sub.Next(ctx)
+		cancel()
+
+		switch {
+		case req.Context().Err() != nil:
+			return
+		case err == context.DeadlineExceeded:
+			_, err = fmt.Fprint(res, ": heartbeat\n\n")
+		case err != nil:
+			// the client will reconnect and resume from the last event it received
+			service.logError(req, err)
+			return
+		default:
+			data, _ := json.Marshal(EventResource{
+				Name:      event.Name,
+				Timestamp: time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339),
+			})
+			_, err = fmt.Fprintf(res, "id: %d\ndata: %s\n\n", event.ID, data)
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStream opens an event stream and returns the first `n` lines which
+// are not blank.
+func readStream(t *testing.T, service *WebService, url string, header http.Header, n int) []string {
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+url, nil)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected response code %d, got %d", http.StatusOK, response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != EventStreamContentType {
+		t.Errorf("expected content type %q, got %q", EventStreamContentType, contentType)
+	}
+
+	var lines []string
+	reader := bufio.NewReader(response.Body)
+	for len(lines) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected error reading stream: %s", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestStream(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+
+	lines := readStream(t, &service, "/v1/events/stream", nil, 4)
+
+	expected := []string{
+		"id: 4",
+		`data: {"name":"foo","timestamp":"2015-02-11T15:01:00Z"}`,
+		"id: 5",
+		`data: {"name":"bar","timestamp":"2015-02-11T15:01:01Z"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected stream %q, got %q", expected, lines)
+	}
+}
+
+func TestStreamFiltersByName(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+
+	lines := readStream(t, &service, "/v1/events/stream?pattern=b*", nil, 2)
+
+	if lines[0] != "id: 5" {
+		t.Errorf("expected only event 5, got %q", lines)
+	}
+}
+
+func TestStreamResumesFromLastEventID(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+
+	lines := readStream(t, &service, "/v1/events/stream", http.Header{LastEventIDHeader: {"4"}}, 1)
+	if lines[0] != "id: 5" {
+		t.Errorf("expected stream to resume at event 5, got %q", lines)
+	}
+
+	lines = readStream(t, &service, "/v1/events/stream?last_event_id=4", nil, 1)
+	if lines[0] != "id: 5" {
+		t.Errorf("expected stream to resume at event 5, got %q", lines)
+	}
+}
+
+func TestStreamSendsHeartbeats(t *testing.T) {
+	defer func(interval time.Duration) { StreamHeartbeat = interval }(StreamHeartbeat)
+	StreamHeartbeat = 10 * time.Millisecond
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+
+	lines := readStream(t, &service, "/v1/events/stream?name=none", nil, 2)
+
+	if lines[0] != ": heartbeat" || lines[1] != ": heartbeat" {
+		t.Errorf("expected heartbeats, got %q", lines)
+	}
+}
+
+func TestStreamInvalidLastEventID(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", "http://example.com/v1/events/stream?last_event_id=abc", nil)
+
+	response := httptest.NewRecorder()
+	service.Stream(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected response code %d, got %d", http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestStreamUnsupported(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractorWithStreamError)}
+	request, _ := http.NewRequest("GET", "http://example.com/v1/events/stream", nil)
+
+	response := httptest.NewRecorder()
+	service.Stream(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	if response.Code != http.StatusNotImplemented {
+		t.Errorf("expected response code %d, got %d", http.StatusNotImplemented, response.Code)
+	}
+}
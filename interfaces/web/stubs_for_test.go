@@ -1,8 +1,12 @@
 package web
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/declantraynor/go-events-service/domain"
 	"github.com/declantraynor/go-events-service/usecases"
 )
 
@@ -10,32 +14,182 @@ import (
 // functions required by the interface
 type StubEventInteractor struct{}
 
-func (interactor *StubEventInteractor) AddEvent(name, timestamp string) error {
+func (interactor *StubEventInteractor) AddEvent(tenant, name, timestamp string, options usecases.EventOptions) error {
 	return nil
 }
 
-func (interactor *StubEventInteractor) CountEventsInTimeRange(from, to string) (map[string]int, error) {
+func (interactor *StubEventInteractor) AddEvents(tenant string, events []usecases.NewEvent) error {
+	return nil
+}
+
+func (interactor *StubEventInteractor) CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error) {
 	return map[string]int{
 		"foo": 25,
 		"bar": 43,
 	}, nil
 }
 
+func (interactor *StubEventInteractor) CompareEventsInTimeRange(tenant string, timeRange usecases.TimeRange, compare string) (map[string]usecases.Comparison, error) {
+	if compare != usecases.ComparePreviousPeriod {
+		return nil, domain.ValidationError{Field: "compare", Reason: "must be previous_period or previous_week"}
+	}
+	fall, rise := -100.0, 75.0
+	return map[string]usecases.Comparison{
+		"foo": {Count: 0, ComparisonCount: 5, Delta: -5, PercentChange: &fall},
+		"bar": {Count: 7, ComparisonCount: 4, Delta: 3, PercentChange: &rise},
+		"baz": {Count: 2, ComparisonCount: 0, Delta: 2},
+	}, nil
+}
+
+func (interactor *StubEventInteractor) CountUniqueActors(tenant, name string, timeRange usecases.TimeRange) (int, error) {
+	return 12, nil
+}
+
+func (interactor *StubEventInteractor) AggregateValues(tenant, name string, timeRange usecases.TimeRange, fn string) (usecases.Aggregate, error) {
+	if name == "unknown" {
+		return usecases.Aggregate{}, nil
+	}
+	value := 42.5
+	return usecases.Aggregate{Count: 7, Value: &value}, nil
+}
+
+func (interactor *StubEventInteractor) Histogram(tenant, name string, timeRange usecases.TimeRange, options usecases.HistogramOptions) ([]usecases.Bucket, error) {
+	if options.TimeZone != "" && options.TimeZone != "Europe/Dublin" {
+		return nil, domain.ValidationError{Field: "tz", Reason: "must be an IANA time zone, such as Europe/Dublin"}
+	}
+	dublin, _ := time.LoadLocation("Europe/Dublin")
+	return []usecases.Bucket{
+		{Start: time.Date(2015, 3, 29, 0, 0, 0, 0, dublin), Count: 3},
+		{Start: time.Date(2015, 3, 30, 0, 0, 0, 0, dublin), Count: 1},
+	}, nil
+}
+
+func (interactor *StubEventInteractor) TopEvents(tenant string, timeRange usecases.TimeRange, options usecases.TopOptions) ([]usecases.TopEvent, error) {
+	if options.Limit > usecases.MaxTopLimit {
+		return nil, domain.ValidationError{Field: "limit", Reason: "must be between 1 and 1000"}
+	}
+	top := []usecases.TopEvent{
+		{Name: "bar", Count: 43, PreviousCount: 40, Change: 3},
+		{Name: "foo", Count: 25, PreviousCount: 30, Change: -5},
+	}
+	if options.Movers {
+		top[0], top[1] = top[1], top[0]
+	}
+	if len(top) > options.Limit {
+		top = top[:options.Limit]
+	}
+	return top, nil
+}
+
+func (interactor *StubEventInteractor) ExportEvents(tenant string, timeRange usecases.TimeRange, name string, fn func(domain.Event) error) error {
+	events := []domain.Event{
+		{Name: "bar", Timestamp: 1423666861},
+		{Name: "foo", Timestamp: 1423666860},
+		{Name: "foo", Timestamp: 1423666862},
+	}
+	for _, event := range events {
+		if name != "" && event.Name != name {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (interactor *StubEventInteractor) StreamEvents(tenant string, filter usecases.EventFilter, afterID int64) (domain.Subscription, error) {
+	events := []domain.StoredEvent{
+		{ID: 4, Event: domain.Event{Name: "foo", Timestamp: 1423666860}},
+		{ID: 5, Event: domain.Event{Name: "bar", Timestamp: 1423666861}},
+	}
+	var matching []domain.StoredEvent
+	for _, event := range events {
+		if event.ID > afterID && filter.Matches(event.Name) {
+			matching = append(matching, event)
+		}
+	}
+	return &StubSubscription{events: matching}, nil
+}
+
+// Subscription which delivers a fixed sequence of events, then waits
+type StubSubscription struct {
+	events []domain.StoredEvent
+}
+
+func (stub *StubSubscription) Next(ctx context.Context) (domain.StoredEvent, error) {
+	if len(stub.events) == 0 {
+		<-ctx.Done()
+		return domain.StoredEvent{}, ctx.Err()
+	}
+	event := stub.events[0]
+	stub.events = stub.events[1:]
+	return event, nil
+}
+
+func (stub *StubSubscription) Close() error {
+	return nil
+}
+
+// EventInteractor which records the tenant and time range of each request
+type StubEventInteractorRecordingTenant struct {
+	StubEventInteractor
+	tenant    string
+	timeRange usecases.TimeRange
+}
+
+func (interactor *StubEventInteractorRecordingTenant) CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error) {
+	interactor.tenant = tenant
+	interactor.timeRange = timeRange
+	return map[string]int{}, nil
+}
+
+// EventInteractor which records the options of each added event
+type StubEventInteractorRecordingOptions struct {
+	StubEventInteractor
+	options usecases.EventOptions
+}
+
+func (interactor *StubEventInteractorRecordingOptions) AddEvent(tenant, name, timestamp string, options usecases.EventOptions) error {
+	interactor.options = options
+	return nil
+}
+
+// EventInteractor which records the events of the batch it is asked to add
+type StubEventInteractorRecordingBatch struct {
+	StubEventInteractor
+	events []usecases.NewEvent
+}
+
+func (interactor *StubEventInteractorRecordingBatch) AddEvents(tenant string, events []usecases.NewEvent) error {
+	interactor.events = events
+	return nil
+}
+
 // EventInteractor which simulates an error from AddEvent
 type StubEventInteractorWithAddError struct {
 	StubEventInteractor
 }
 
-func (interactor *StubEventInteractorWithAddError) AddEvent(name, timestamp string) error {
+func (interactor *StubEventInteractorWithAddError) AddEvent(tenant, name, timestamp string, options usecases.EventOptions) error {
 	return errors.New("error from EventInteractor->AddEvent")
 }
 
+// EventInteractor which simulates a ValidationError from AddEvent
+type StubEventInteractorWithValidationError struct {
+	StubEventInteractor
+}
+
+func (interactor *StubEventInteractorWithValidationError) AddEvent(tenant, name, timestamp string, options usecases.EventOptions) error {
+	return domain.ValidationError{Field: "name", Reason: "is required"}
+}
+
 // EventInteractor which simulates an unspecified error from CountEventsInTimeRange
 type StubEventInteractorWithCountError struct {
 	StubEventInteractor
 }
 
-func (interactor *StubEventInteractorWithCountError) CountEventsInTimeRange(from, to string) (map[string]int, error) {
+func (interactor *StubEventInteractorWithCountError) CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error) {
 	return map[string]int{}, errors.New("error from EventInteractor->CountEventsInTimeRange")
 }
 
@@ -44,8 +198,8 @@ type StubEventInteractorWithTimestampError struct {
 	StubEventInteractor
 }
 
-func (interactor *StubEventInteractorWithTimestampError) CountEventsInTimeRange(from, to string) (map[string]int, error) {
-	return map[string]int{}, usecases.InvalidTimestampError{Timestamp: from}
+func (interactor *StubEventInteractorWithTimestampError) CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error) {
+	return map[string]int{}, usecases.InvalidTimestampError{Timestamp: timeRange.From}
 }
 
 // EventInteractor which simulates an InvalidTimeRangeError from CountEventsInTimeRange
@@ -53,6 +207,169 @@ type StubEventInteractorWithTimeRangeError struct {
 	StubEventInteractor
 }
 
-func (interactor *StubEventInteractorWithTimeRangeError) CountEventsInTimeRange(from, to string) (map[string]int, error) {
-	return map[string]int{}, usecases.InvalidTimeRangeError{From: from, To: to}
+func (interactor *StubEventInteractorWithTimeRangeError) CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error) {
+	return map[string]int{}, usecases.InvalidTimeRangeError{From: timeRange.From, To: timeRange.To}
+}
+
+// AuthInteractor which accepts the key "secret", granting it only
+// usecases.ScopeRead, the key "acme-secret", which is also bound to the
+// tenant "acme", the key "admin-secret", which also has usecases.ScopeAdmin,
+// and the key "limited-secret", which has its own read limit of one request
+// per second. All other keys are rejected.
+type StubAuthInteractor struct{}
+
+func (interactor *StubAuthInteractor) Authenticate(key string) (domain.APIKey, error) {
+	switch key {
+	case "secret":
+		return domain.APIKey{Hash: "hash", Scopes: []string{usecases.ScopeRead}}, nil
+	case "acme-secret":
+		return domain.APIKey{Hash: "acme-hash", Scopes: []string{usecases.ScopeRead}, Tenant: "acme"}, nil
+	case "admin-secret":
+		return domain.APIKey{Hash: "admin-hash", Scopes: []string{usecases.ScopeRead, usecases.ScopeAdmin}}, nil
+	case "limited-secret":
+		return domain.APIKey{
+			Hash:      "limited-hash",
+			Scopes:    []string{usecases.ScopeRead},
+			ReadLimit: domain.RateLimit{Rate: 1, Burst: 1},
+		}, nil
+	}
+	return domain.APIKey{}, usecases.UnauthenticatedError{Reason: "invalid API key"}
+}
+
+func (interactor *StubAuthInteractor) Authorize(key domain.APIKey, scope string) error {
+	if scope != usecases.ScopeRead {
+		return usecases.ForbiddenError{Scope: scope}
+	}
+	return nil
+}
+
+func (interactor *StubAuthInteractor) CreateKey(options usecases.KeyOptions) (string, error) {
+	if len(options.Scopes) == 0 {
+		return "", usecases.InvalidScopeError{}
+	}
+	return "new-key", nil
+}
+
+// AuthInteractor which simulates an error from the backing key store
+type StubAuthInteractorWithError struct {
+	StubAuthInteractor
+}
+
+func (interactor *StubAuthInteractorWithError) Authenticate(key string) (domain.APIKey, error) {
+	return domain.APIKey{}, errors.New("error from AuthInteractor->Authenticate")
+}
+
+// RateLimiter which simulates an error from its backing store
+type StubRateLimiterWithError struct{}
+
+func (limiter *StubRateLimiterWithError) Allow(key string, limit domain.RateLimit) (bool, time.Duration, error) {
+	return false, 0, errors.New("error from RateLimiter->Allow")
+}
+
+// EventInteractor which simulates an error from ExportEvents before any
+// events are exported
+type StubEventInteractorWithExportError struct {
+	StubEventInteractor
+}
+
+func (interactor *StubEventInteractorWithExportError) ExportEvents(tenant string, timeRange usecases.TimeRange, name string, fn func(domain.Event) error) error {
+	return usecases.UnsupportedError{Operation: "exporting events"}
+}
+
+// EventInteractor which simulates an error from ExportEvents after some
+// events have been exported
+type StubEventInteractorWithExportStreamError struct {
+	StubEventInteractor
+}
+
+func (interactor *StubEventInteractorWithExportStreamError) ExportEvents(tenant string, timeRange usecases.TimeRange, name string, fn func(domain.Event) error) error {
+	if err := fn(domain.Event{Name: "foo", Timestamp: 1423666860}); err != nil {
+		return err
+	}
+	return errors.New("error from EventInteractor->ExportEvents")
+}
+
+// EventInteractor which simulates an error from StreamEvents
+type StubEventInteractorWithStreamError struct {
+	StubEventInteractor
+}
+
+func (interactor *StubEventInteractorWithStreamError) StreamEvents(tenant string, filter usecases.EventFilter, afterID int64) (domain.Subscription, error) {
+	return nil, usecases.UnsupportedError{Operation: "streaming events"}
+}
+
+// AlertInteractor which keeps rules in memory, validating only the window
+type StubAlertInteractor struct {
+	rules  map[string]domain.AlertRule
+	nextID int
+}
+
+func (interactor *StubAlertInteractor) CreateRule(tenant string, options usecases.AlertRuleOptions) (domain.AlertRule, error) {
+	window, err := time.ParseDuration(options.Window)
+	if err != nil {
+		return domain.AlertRule{}, domain.ValidationError{Field: "window", Reason: "must be a whole number of seconds, such as 5m"}
+	}
+
+	interactor.nextID++
+	rule := domain.AlertRule{
+		ID:         fmt.Sprintf("rule%d", interactor.nextID),
+		Tenant:     tenant,
+		EventName:  options.EventName,
+		Threshold:  options.Threshold,
+		Window:     int64(window / time.Second),
+		WebhookURL: options.WebhookURL,
+		Secret:     "s3cret",
+	}
+	if interactor.rules == nil {
+		interactor.rules = map[string]domain.AlertRule{}
+	}
+	interactor.rules[tenant+"/"+rule.ID] = rule
+	return rule, nil
+}
+
+func (interactor *StubAlertInteractor) GetRule(tenant, id string) (domain.AlertRule, error) {
+	rule, ok := interactor.rules[tenant+"/"+id]
+	if !ok {
+		return domain.AlertRule{}, domain.ErrNotFound
+	}
+	return rule, nil
+}
+
+func (interactor *StubAlertInteractor) ListRules(tenant string) ([]domain.AlertRule, error) {
+	rules := []domain.AlertRule{}
+	for _, rule := range interactor.rules {
+		if rule.Tenant == tenant {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (interactor *StubAlertInteractor) UpdateRule(tenant, id string, options usecases.AlertRuleOptions) (domain.AlertRule, error) {
+	existing, err := interactor.GetRule(tenant, id)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+	rule, err := interactor.CreateRule(tenant, options)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+	delete(interactor.rules, tenant+"/"+rule.ID)
+	rule.ID, rule.Firing = existing.ID, existing.Firing
+	interactor.rules[tenant+"/"+id] = rule
+	return rule, nil
+}
+
+func (interactor *StubAlertInteractor) DeleteRule(tenant, id string) error {
+	if _, err := interactor.GetRule(tenant, id); err != nil {
+		return err
+	}
+	delete(interactor.rules, tenant+"/"+id)
+	return nil
+}
+
+type StubMetricsReporter map[string]int64
+
+func (stub StubMetricsReporter) Metrics() map[string]int64 {
+	return stub
 }
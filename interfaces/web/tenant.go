@@ -0,0 +1,33 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// TenantHeader is the HTTP header used to select the tenant a request
+// operates on, when its API key is an admin key not bound to one.
+const TenantHeader = "X-Tenant"
+
+// tenant resolves the tenant a request operates on from its API key and
+// X-Tenant header. Requests which are not authenticated, because the service
+// has no AuthInteractor, may name any tenant. If the request may not
+// proceed, an error response is rendered and false is returned.
+func (service *WebService) tenant(res http.ResponseWriter, req *http.Request) (string, bool) {
+	requested := req.Header.Get(TenantHeader)
+
+	var tenant string
+	var err error
+	if key, ok := APIKeyFromContext(req.Context()); ok {
+		tenant, err = usecases.ResolveTenant(key, requested)
+	} else {
+		tenant, err = usecases.SelectTenant(requested)
+	}
+	if err != nil {
+		service.renderError(res, req, err)
+		return "", false
+	}
+
+	return tenant, true
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+func TestCountResolvesTenant(t *testing.T) {
+	cases := []struct {
+		authorization  string
+		tenantHeader   string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{"Bearer secret", "", http.StatusOK, usecases.DefaultTenant},
+		{"Bearer secret", "globex", http.StatusForbidden, ""},
+		{"Bearer admin-secret", "globex", http.StatusOK, "globex"},
+		{"Bearer acme-secret", "", http.StatusOK, "acme"},
+		{"Bearer acme-secret", "acme", http.StatusOK, "acme"},
+		{"Bearer acme-secret", "globex", http.StatusForbidden, ""},
+		{"Bearer admin-secret", "acme:corp", http.StatusBadRequest, ""},
+	}
+
+	for _, c := range cases {
+		interactor := new(StubEventInteractorRecordingTenant)
+		service := WebService{EventInteractor: interactor, AuthInteractor: new(StubAuthInteractor)}
+		handler := service.RequireScope(usecases.ScopeRead, service.Count)
+
+		request, _ := http.NewRequest(
+			"GET",
+			"http://example.com/events/count?from=2015-02-11T15:01:00+00:00&to=2015-02-11T15:01:59+00:00",
+			nil)
+		request.Header.Set("Authorization", c.authorization)
+		if c.tenantHeader != "" {
+			request.Header.Set(TenantHeader, c.tenantHeader)
+		}
+
+		response := httptest.NewRecorder()
+		handler(response, request)
+
+		if response.Code != c.expectedStatus {
+			t.Errorf("expected response code %d, got %d", c.expectedStatus, response.Code)
+		}
+		if interactor.tenant != c.expectedTenant {
+			t.Errorf("expected tenant %q, got %q", c.expectedTenant, interactor.tenant)
+		}
+	}
+}
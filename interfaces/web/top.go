@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// defaultTopLimit is the number of names returned by Top unless the request
+// gives a limit.
+const defaultTopLimit = 10
+
+// TopEventResource describes the number of events of one name in a time
+// range. When ranking movers it also describes the number in the previous
+// range of the same length, and the change between them.
+type TopEventResource struct {
+	Name          string `json:"name"`
+	Count         int    `json:"count"`
+	PreviousCount *int   `json:"previous_count,omitempty"`
+	Change        *int   `json:"change,omitempty"`
+}
+
+type TopResource struct {
+	Events []TopEventResource `json:"events"`
+}
+
+// Top lists the names of the events which occurred most often in a time
+// range, or, if `movers` is true, those whose counts changed most from the
+// previous range of the same length.
+func (service *WebService) Top(res http.ResponseWriter, req *http.Request) {
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	options := usecases.TopOptions{Limit: defaultTopLimit}
+	var params []InvalidParam
+	if value := req.FormValue("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			params = append(params, InvalidParam{Name: "limit", Reason: "must be an integer"})
+		}
+		options.Limit = limit
+	}
+	if value := req.FormValue("movers"); value != "" {
+		movers, err := strconv.ParseBool(value)
+		if err != nil {
+			params = append(params, InvalidParam{Name: "movers", Reason: "must be true or false"})
+		}
+		options.Movers = movers
+	}
+	if params != nil {
+		service.renderInvalidParams(res, req, params)
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	top, err := service.EventInteractor.TopEvents(tenant, timeRange, options)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	resource := TopResource{Events: []TopEventResource{}}
+	for _, event := range top {
+		event := event
+		described := TopEventResource{Name: event.Name, Count: event.Count}
+		if options.Movers {
+			described.PreviousCount, described.Change = &event.PreviousCount, &event.Change
+		}
+		resource.Events = append(resource.Events, described)
+	}
+	service.RenderJSON(res, resource, http.StatusOK)
+}
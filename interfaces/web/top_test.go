@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const topURL = "http://example.com/v1/events/top?from=2015-02-11T15:00:00Z&to=2015-02-11T15:59:59Z"
+
+func serveTop(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", topURL+query, nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+	return response
+}
+
+func TestTop(t *testing.T) {
+	response := serveTop(t, "")
+
+	expected := `{
+    "events": [
+        {
+            "name": "bar",
+            "count": 43
+        },
+        {
+            "name": "foo",
+            "count": 25
+        }
+    ]
+}`
+	if response.Code != http.StatusOK || response.Body.String() != expected {
+		t.Errorf("expected %s, got %d %s", expected, response.Code, response.Body)
+	}
+}
+
+func TestTopMovers(t *testing.T) {
+	response := serveTop(t, "&movers=true&limit=1")
+
+	var top TopResource
+	json.Unmarshal(response.Body.Bytes(), &top)
+	if len(top.Events) != 1 || top.Events[0].Name != "foo" || top.Events[0].Change == nil || *top.Events[0].Change != -5 {
+		t.Errorf("expected foo to be the biggest mover, got %s", response.Body)
+	}
+}
+
+func TestTopInvalidParams(t *testing.T) {
+	for _, query := range []string{"&limit=ten", "&movers=maybe", "&limit=5000"} {
+		response := serveTop(t, query)
+
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %d %s", query, response.Code, response.Body)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import "net/http"
+
+// UniqueResource describes an estimate of the number of distinct actors of
+// the events named Name in a time range.
+type UniqueResource struct {
+	Name         string `json:"name"`
+	UniqueActors int    `json:"unique_actors"`
+}
+
+// Unique estimates how many distinct actors triggered the events with a
+// given name in a time range.
+func (service *WebService) Unique(res http.ResponseWriter, req *http.Request) {
+	name := req.FormValue("name")
+	if name == "" {
+		service.renderMissingParameter(res, req, "name")
+		return
+	}
+
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	count, err := service.EventInteractor.CountUniqueActors(tenant, name, timeRange)
+	if err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	service.RenderJSON(res, UniqueResource{Name: name, UniqueActors: count}, http.StatusOK)
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const uniqueURL = "http://example.com/v1/events/unique?from=2015-02-11T15:01:00Z&to=2015-02-11T15:01:59Z"
+
+func TestUnique(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", uniqueURL+"&name=login", nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var unique UniqueResource
+	json.Unmarshal(response.Body.Bytes(), &unique)
+	expected := UniqueResource{Name: "login", UniqueActors: 12}
+	if response.Code != http.StatusOK || unique != expected {
+		t.Errorf("expected %+v, got %d %s", expected, response.Code, response.Body)
+	}
+}
+
+func TestUniqueRequiresName(t *testing.T) {
+	service := WebService{EventInteractor: new(StubEventInteractor)}
+	request, _ := http.NewRequest("GET", uniqueURL, nil)
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "name" {
+		t.Errorf("expected name to be required, got %d %s", response.Code, response.Body)
+	}
+}
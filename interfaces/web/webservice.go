@@ -3,75 +3,170 @@ package web
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/declantraynor/go-events-service/domain"
 	"github.com/declantraynor/go-events-service/usecases"
 )
 
 type EventInteractor interface {
-	AddEvent(name, timestamp string) error
-	CountEventsInTimeRange(from, to string) (map[string]int, error)
+	AddEvent(tenant, name, timestamp string, options usecases.EventOptions) error
+	AddEvents(tenant string, events []usecases.NewEvent) error
+	CountEventsInTimeRange(tenant string, timeRange usecases.TimeRange) (map[string]int, error)
+	CompareEventsInTimeRange(tenant string, timeRange usecases.TimeRange, compare string) (map[string]usecases.Comparison, error)
+	CountUniqueActors(tenant, name string, timeRange usecases.TimeRange) (int, error)
+	AggregateValues(tenant, name string, timeRange usecases.TimeRange, fn string) (usecases.Aggregate, error)
+	Histogram(tenant, name string, timeRange usecases.TimeRange, options usecases.HistogramOptions) ([]usecases.Bucket, error)
+	TopEvents(tenant string, timeRange usecases.TimeRange, options usecases.TopOptions) ([]usecases.TopEvent, error)
+	ExportEvents(tenant string, timeRange usecases.TimeRange, name string, fn func(domain.Event) error) error
+	StreamEvents(tenant string, filter usecases.EventFilter, afterID int64) (domain.Subscription, error)
 }
 
 type EventResource struct {
-	Name      string `json:"name"`
-	Timestamp string `json:"timestamp"`
+	Name      string   `json:"name"`
+	Timestamp string   `json:"timestamp"`
+	Actor     string   `json:"actor,omitempty"`
+	Value     *float64 `json:"value,omitempty"`
 }
 
-type ErrorResource struct {
-	Error string `json:"error"`
+// BatchResource is a request to record several events at once.
+type BatchResource struct {
+	Events []EventResource `json:"events"`
+}
+
+// BatchResultResource reports the number of events a batch recorded.
+type BatchResultResource struct {
+	Recorded int `json:"recorded"`
 }
 
 type WebService struct {
 	EventInteractor EventInteractor
+	AuthInteractor  AuthInteractor
+	AlertInteractor AlertInteractor
+	MetricsReporter MetricsReporter
+	RateLimiter     RateLimiter
+	ReadLimit       domain.RateLimit
+	WriteLimit      domain.RateLimit
+	MaxBodyBytes    int64
+	Logger          *Logger
 }
 
 func (service *WebService) Create(res http.ResponseWriter, req *http.Request) {
 
-	if req.Method != "POST" {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: "Method Not Allowed"},
-			http.StatusMethodNotAllowed)
+	tenant, ok := service.tenant(res, req)
+	if !ok {
 		return
 	}
 
-	defer req.Body.Close()
-	body, _ := ioutil.ReadAll(req.Body)
-
 	event := EventResource{}
-	err := json.Unmarshal(body, &event)
-	if err != nil {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: "Request JSON is invalid"},
-			http.StatusBadRequest)
+	if !service.decodeJSON(res, req, &event) {
+		return
+	}
+
+	if params := requireFields(map[string]string{"name": event.Name, "timestamp": event.Timestamp}); params != nil {
+		service.renderInvalidParams(res, req, params)
 		return
 	}
 
-	if err := service.EventInteractor.AddEvent(event.Name, event.Timestamp); err != nil {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: err.Error()},
-			http.StatusBadRequest)
+	if err := service.EventInteractor.AddEvent(tenant, event.Name, event.Timestamp, usecases.EventOptions{
+		Actor: event.Actor,
+		Value: event.Value,
+	}); err != nil {
+		service.renderError(res, req, err)
 		return
 	}
 
 	service.RenderJSON(res, map[string]string{}, http.StatusCreated)
 }
 
+// CreateBatch records every event in the request body, or none of them if
+// any is invalid.
+func (service *WebService) CreateBatch(res http.ResponseWriter, req *http.Request) {
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	batch := BatchResource{}
+	if !service.decodeJSON(res, req, &batch) {
+		return
+	}
+
+	var params []InvalidParam
+	events := make([]usecases.NewEvent, len(batch.Events))
+	for i, event := range batch.Events {
+		for _, param := range requireFields(map[string]string{"name": event.Name, "timestamp": event.Timestamp}) {
+			param.Name = fmt.Sprintf("events[%d].%s", i, param.Name)
+			params = append(params, param)
+		}
+		events[i] = usecases.NewEvent{
+			Name:         event.Name,
+			Timestamp:    event.Timestamp,
+			EventOptions: usecases.EventOptions{Actor: event.Actor, Value: event.Value},
+		}
+	}
+	if params != nil {
+		service.renderInvalidParams(res, req, params)
+		return
+	}
+
+	if err := service.EventInteractor.AddEvents(tenant, events); err != nil {
+		service.renderError(res, req, err)
+		return
+	}
+
+	service.RenderJSON(res, BatchResultResource{Recorded: len(events)}, http.StatusCreated)
+}
+
 func (service *WebService) Count(res http.ResponseWriter, req *http.Request) {
 
-	if req.Method != "GET" {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: "Method Not Allowed"},
-			http.StatusMethodNotAllowed)
+	timeRange, ok := service.timeRange(res, req)
+	if !ok {
+		return
+	}
+
+	tenant, ok := service.tenant(res, req)
+	if !ok {
+		return
+	}
+
+	if compare := req.FormValue("compare"); compare != "" {
+		service.compare(res, req, tenant, timeRange, compare)
+		return
+	}
+
+	counts, err := service.EventInteractor.CountEventsInTimeRange(tenant, timeRange)
+	if err != nil {
+		service.renderError(res, req, err)
 		return
 	}
 
+	service.RenderJSON(res, counts, http.StatusOK)
+}
+
+// timeRangeParameters describe the query parameters read by timeRange.
+var timeRangeParameters = []parameter{
+	{"from", "Start of the time range, an ISO8601 UTC timestamp or a relative time such as now-1h or today", true},
+	{"to", "End of the time range, an ISO8601 UTC timestamp or a relative time such as now", true},
+	{"bounds", "half_open (the default) to exclude events at `to`, or closed to include them", false},
+}
+
+// withTimeRange returns the parameters of an operation on a time range:
+// timeRangeParameters followed by `params`.
+func withTimeRange(params ...parameter) []parameter {
+	return append(append([]parameter{}, timeRangeParameters...), params...)
+}
+
+// timeRange returns the time range given by the required `from` and `to`
+// query parameters and the optional `bounds`, rendering an error if either
+// timestamp is missing or the bounds are invalid. Ranges are half-open
+// unless `bounds` is closed, or the request was made to a deprecated
+// unversioned route, where ranges were closed before /v1.
+func (service *WebService) timeRange(res http.ResponseWriter, req *http.Request) (usecases.TimeRange, bool) {
+
 	// FormValue will parse out any `+` symbols in query params,
 	// so we need to put them back in to get the true timestamp
 	// values passed in the URL
@@ -79,45 +174,49 @@ func (service *WebService) Count(res http.ResponseWriter, req *http.Request) {
 	to := strings.Replace(req.FormValue("to"), " ", "+", -1)
 
 	if from == "" {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: `Missing required parameter "from"`},
-			http.StatusBadRequest)
-		return
+		service.renderMissingParameter(res, req, "from")
+		return usecases.TimeRange{}, false
 	}
 
 	if to == "" {
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: `Missing required parameter "to"`},
-			http.StatusBadRequest)
-		return
+		service.renderMissingParameter(res, req, "to")
+		return usecases.TimeRange{}, false
 	}
 
-	counts, err := service.EventInteractor.CountEventsInTimeRange(from, to)
-	if err != nil {
-		if e, ok := err.(usecases.InvalidTimestampError); ok {
-			service.RenderJSON(
-				res,
-				ErrorResource{Error: e.Error()},
-				http.StatusBadRequest)
-			return
-		}
-		if e, ok := err.(usecases.InvalidTimeRangeError); ok {
-			service.RenderJSON(
-				res,
-				ErrorResource{Error: e.Error()},
-				http.StatusBadRequest)
-			return
-		}
-		service.RenderJSON(
-			res,
-			ErrorResource{Error: "Internal Server Error"},
-			http.StatusInternalServerError)
-		return
+	timeRange := usecases.TimeRange{From: from, To: to, Closed: isDeprecated(req)}
+	switch req.FormValue("bounds") {
+	case "":
+	case "half_open":
+		timeRange.Closed = false
+	case "closed":
+		timeRange.Closed = true
+	default:
+		service.renderInvalidParams(res, req, []InvalidParam{{Name: "bounds", Reason: "must be half_open or closed"}})
+		return usecases.TimeRange{}, false
 	}
 
-	service.RenderJSON(res, counts, http.StatusOK)
+	return timeRange, true
+}
+
+func (service *WebService) renderMissingParameter(res http.ResponseWriter, req *http.Request, name string) {
+	problem := NewProblem(
+		http.StatusBadRequest,
+		CodeMissingParameter,
+		fmt.Sprintf("Missing required parameter %q", name))
+	problem.Field = name
+	service.RenderProblem(res, req, problem)
+}
+
+// logError records an error encountered while handling `req`, tagged with
+// the request's ID so it can be correlated with the access log.
+func (service *WebService) logError(req *http.Request, err error) {
+	service.Logger.Log(Fields{
+		"level":      "error",
+		"msg":        err.Error(),
+		"request_id": RequestIDFromContext(req.Context()),
+		"method":     req.Method,
+		"path":       req.URL.Path,
+	})
 }
 
 func (service *WebService) RenderJSON(res http.ResponseWriter, resource interface{}, status int) {
@@ -14,23 +14,42 @@ func TestCreate(t *testing.T) {
 
 	requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}`)
 	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
 
 	response := httptest.NewRecorder()
 	service.Create(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	if response.Code != http.StatusCreated {
 		t.Errorf("expected response code %d, got %d", http.StatusCreated, response.Code)
 	}
 }
 
+func TestCreateWithOptions(t *testing.T) {
+	interactor := new(StubEventInteractorRecordingOptions)
+	service := WebService{EventInteractor: interactor}
+
+	requestBody := strings.NewReader(`{"name": "checkout", "timestamp": "2015-02-11T15:01:00+00:00", "actor": "user-42", "value": 19.99}`)
+	request, _ := http.NewRequest("POST", "http://example.com/v1/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+
+	options := interactor.options
+	if response.Code != http.StatusCreated || options.Actor != "user-42" || options.Value == nil || *options.Value != 19.99 {
+		t.Errorf("expected the event to be added with its actor and value, got %d and %+v", response.Code, options)
+	}
+}
+
 func TestCreateRejectsInvalidHTTPMethods(t *testing.T) {
-	methods := []string{"GET", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	methods := []string{"GET", "PUT", "PATCH", "DELETE", "HEAD"}
 	service := WebService{EventInteractor: new(StubEventInteractor)}
 
 	for _, m := range methods {
-		request, _ := http.NewRequest(m, "http://example.com/events", nil)
+		request, _ := http.NewRequest(m, "http://example.com/v1/events", nil)
 		response := httptest.NewRecorder()
-		service.Create(response, request)
+		service.Handler().ServeHTTP(response, request)
 
 		expectedResponseCode := http.StatusMethodNotAllowed
 		if response.Code != expectedResponseCode {
@@ -44,9 +63,11 @@ func TestCreateRejectsInvalidJSON(t *testing.T) {
 
 	requestBody := strings.NewReader(`{"invalid": json}`)
 	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
 
 	response := httptest.NewRecorder()
 	service.Create(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusBadRequest
 	if response.Code != http.StatusBadRequest {
@@ -59,16 +80,65 @@ func TestCreateEventInteractorError(t *testing.T) {
 
 	requestBody := strings.NewReader(`{"name": "test", "timestamp": "2015-02-11T15:01:00-05:00"}`)
 	request, _ := http.NewRequest("POST", "http://example.com/events", requestBody)
+	request.Header.Set("Content-Type", "application/json")
 
 	response := httptest.NewRecorder()
 	service.Create(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
-	expectedResponseCode := http.StatusBadRequest
-	if response.Code != http.StatusBadRequest {
+	expectedResponseCode := http.StatusInternalServerError
+	if response.Code != expectedResponseCode {
 		t.Errorf("expected response code %d, got %d", expectedResponseCode, response.Code)
 	}
 }
 
+func TestCreateBatch(t *testing.T) {
+	interactor := new(StubEventInteractorRecordingBatch)
+	service := WebService{EventInteractor: interactor}
+
+	requestBody := strings.NewReader(`{"events": [
+		{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"},
+		{"name": "checkout", "timestamp": "2015-02-11T15:02:00+00:00", "actor": "user-42", "value": 19.99}
+	]}`)
+	request, _ := http.NewRequest("POST", "http://example.com/v1/events/batch", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var result BatchResultResource
+	json.Unmarshal(response.Body.Bytes(), &result)
+	if response.Code != http.StatusCreated || result.Recorded != 2 {
+		t.Errorf("expected 2 events to be recorded, got %d: %s", response.Code, response.Body)
+	}
+	if len(interactor.events) != 2 || interactor.events[1].Actor != "user-42" || *interactor.events[1].Value != 19.99 {
+		t.Errorf("unexpected events %+v", interactor.events)
+	}
+}
+
+func TestCreateBatchRequiresFields(t *testing.T) {
+	interactor := new(StubEventInteractorRecordingBatch)
+	service := WebService{EventInteractor: interactor}
+
+	requestBody := strings.NewReader(`{"events": [{"name": "test", "timestamp": "2015-02-11T15:01:00+00:00"}, {"name": "test"}]}`)
+	request, _ := http.NewRequest("POST", "http://example.com/v1/events/batch", requestBody)
+	request.Header.Set("Content-Type", "application/json")
+
+	response := httptest.NewRecorder()
+	service.Handler().ServeHTTP(response, request)
+	assertMatchesSpec(t, &service, request, response)
+
+	var problem ErrorResource
+	json.Unmarshal(response.Body.Bytes(), &problem)
+	if response.Code != http.StatusBadRequest || problem.Field != "events[1].timestamp" {
+		t.Errorf("expected events[1].timestamp to be required, got %d: %s", response.Code, response.Body)
+	}
+	if interactor.events != nil {
+		t.Errorf("expected no events to be added, got %+v", interactor.events)
+	}
+}
+
 func TestCount(t *testing.T) {
 	service := WebService{EventInteractor: new(StubEventInteractor)}
 	request, _ := http.NewRequest(
@@ -78,6 +148,7 @@ func TestCount(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusOK
 	if response.Code != expectedResponseCode {
@@ -99,13 +170,13 @@ func TestCount(t *testing.T) {
 }
 
 func TestCountRejectsInvalidRequestMethods(t *testing.T) {
-	methods := []string{"POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	methods := []string{"POST", "PUT", "PATCH", "DELETE", "HEAD"}
 	service := WebService{EventInteractor: new(StubEventInteractor)}
 
 	for _, m := range methods {
-		request, _ := http.NewRequest(m, "http://example.com/events/count", nil)
+		request, _ := http.NewRequest(m, "http://example.com/v1/events/count", nil)
 		response := httptest.NewRecorder()
-		service.Count(response, request)
+		service.Handler().ServeHTTP(response, request)
 
 		expectedResponseCode := http.StatusMethodNotAllowed
 		if response.Code != expectedResponseCode {
@@ -123,6 +194,7 @@ func TestCountMissingFromParameter(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusBadRequest
 	if response.Code != expectedResponseCode {
@@ -139,6 +211,7 @@ func TestCountMissingToParameter(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusBadRequest
 	if response.Code != expectedResponseCode {
@@ -155,6 +228,7 @@ func TestCountInvalidTimestampError(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusBadRequest
 	if response.Code != expectedResponseCode {
@@ -171,6 +245,7 @@ func TestCountInvalidTimeRangeError(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusBadRequest
 	if response.Code != expectedResponseCode {
@@ -178,6 +253,42 @@ func TestCountInvalidTimeRangeError(t *testing.T) {
 	}
 }
 
+func TestCountTimeRangeBounds(t *testing.T) {
+	cases := []struct {
+		path           string
+		bounds         string
+		expectedStatus int
+		expectedClosed bool
+	}{
+		{"/v1/events/count", "", http.StatusOK, false},
+		{"/v1/events/count", "closed", http.StatusOK, true},
+		{"/v1/events/count", "half_open", http.StatusOK, false},
+		{"/events/count", "", http.StatusOK, true},
+		{"/events/count", "half_open", http.StatusOK, false},
+		{"/v1/events/count", "open", http.StatusBadRequest, false},
+	}
+
+	for _, c := range cases {
+		interactor := new(StubEventInteractorRecordingTenant)
+		service := WebService{EventInteractor: interactor}
+
+		url := "http://example.com" + c.path + "?from=2015-02-11T15:01:00Z&to=2015-02-11T15:02:00Z"
+		if c.bounds != "" {
+			url += "&bounds=" + c.bounds
+		}
+		request, _ := http.NewRequest("GET", url, nil)
+		response := httptest.NewRecorder()
+		service.Handler().ServeHTTP(response, request)
+
+		if response.Code != c.expectedStatus {
+			t.Errorf("%s with bounds %q: expected response code %d, got %d", c.path, c.bounds, c.expectedStatus, response.Code)
+		}
+		if interactor.timeRange.Closed != c.expectedClosed {
+			t.Errorf("%s with bounds %q: expected closed %v, got %v", c.path, c.bounds, c.expectedClosed, interactor.timeRange.Closed)
+		}
+	}
+}
+
 func TestCountGenericInteractorError(t *testing.T) {
 	service := WebService{EventInteractor: new(StubEventInteractorWithCountError)}
 	request, _ := http.NewRequest(
@@ -187,6 +298,7 @@ func TestCountGenericInteractorError(t *testing.T) {
 
 	response := httptest.NewRecorder()
 	service.Count(response, request)
+	assertMatchesSpec(t, &service, request, response)
 
 	expectedResponseCode := http.StatusInternalServerError
 	if response.Code != expectedResponseCode {
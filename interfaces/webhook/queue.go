@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+const (
+	// DefaultWorkers is the number of notifications a Queue delivers at once.
+	DefaultWorkers = 4
+
+	// DefaultQueueSize is the number of notifications each worker of a Queue
+	// holds while it is busy.
+	DefaultQueueSize = 256
+)
+
+// ErrQueueFull is returned by Queue.Notify when a notification cannot be
+// accepted without waiting.
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// notification is a notification waiting in a Queue.
+type notification struct {
+	rule  domain.AlertRule
+	alert usecases.Alert
+}
+
+// Queue delivers notifications in the background, so that evaluating rules
+// never waits on a webhook. Notifications of one rule are delivered in the
+// order they were queued, by the same worker.
+type Queue struct {
+	notifier usecases.AlertNotifier
+	onError  func(rule domain.AlertRule, alert usecases.Alert, err error)
+	workers  []chan notification
+	wg       sync.WaitGroup
+}
+
+// NewQueue starts `workers` goroutines delivering notifications through
+// `notifier`, each holding up to `size` waiting for it. `onError` is called
+// with every notification which could not be delivered.
+func NewQueue(notifier usecases.AlertNotifier, workers, size int, onError func(rule domain.AlertRule, alert usecases.Alert, err error)) *Queue {
+	queue := &Queue{notifier: notifier, onError: onError, workers: make([]chan notification, workers)}
+	for i := range queue.workers {
+		queue.workers[i] = make(chan notification, size)
+		queue.wg.Add(1)
+		go queue.deliver(queue.workers[i])
+	}
+	return queue
+}
+
+// Notify queues `alert` for delivery to the webhook of `rule`, returning
+// ErrQueueFull rather than waiting if its worker is too far behind.
+func (queue *Queue) Notify(rule domain.AlertRule, alert usecases.Alert) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(rule.Tenant + "/" + rule.ID))
+
+	select {
+	case queue.workers[hash.Sum32()%uint32(len(queue.workers))] <- notification{rule, alert}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting notifications and waits for those queued to be
+// delivered.
+func (queue *Queue) Close() {
+	for _, worker := range queue.workers {
+		close(worker)
+	}
+	queue.wg.Wait()
+}
+
+func (queue *Queue) deliver(notifications <-chan notification) {
+	defer queue.wg.Done()
+	for n := range notifications {
+		if err := queue.notifier.Notify(n.rule, n.alert); err != nil && queue.onError != nil {
+			queue.onError(n.rule, n.alert, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// notifier which blocks until released, recording the alerts it delivers
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+	err     error
+
+	mu     sync.Mutex
+	alerts []usecases.Alert
+}
+
+func (n *blockingNotifier) Notify(rule domain.AlertRule, alert usecases.Alert) error {
+	if n.started != nil {
+		n.started <- struct{}{}
+	}
+	<-n.release
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return n.err
+}
+
+func TestQueueDoesNotWaitForDelivery(t *testing.T) {
+	notifier := &blockingNotifier{started: make(chan struct{}, 4), release: make(chan struct{})}
+	queue := NewQueue(notifier, 1, 2, nil)
+
+	queue.Notify(testRule(""), usecases.Alert{Count: 1})
+	<-notifier.started
+
+	// one notification is being delivered, so two more can wait
+	for count := 2; count <= 3; count++ {
+		if err := queue.Notify(testRule(""), usecases.Alert{Count: count}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := queue.Notify(testRule(""), usecases.Alert{Count: 4}); err != ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(notifier.release)
+	queue.Close()
+
+	if len(notifier.alerts) != 3 {
+		t.Fatalf("expected 3 deliveries, got %+v", notifier.alerts)
+	}
+	for i, alert := range notifier.alerts {
+		if alert.Count != i+1 {
+			t.Errorf("expected delivery in order, got %+v", notifier.alerts)
+		}
+	}
+}
+
+func TestQueueReportsFailedDeliveries(t *testing.T) {
+	notifier := &blockingNotifier{release: make(chan struct{}), err: errors.New("webhook unavailable")}
+	close(notifier.release)
+
+	var failed []usecases.Alert
+	queue := NewQueue(notifier, DefaultWorkers, DefaultQueueSize, func(rule domain.AlertRule, alert usecases.Alert, err error) {
+		if err != notifier.err {
+			t.Errorf("expected notifier error, got %v", err)
+		}
+		failed = append(failed, alert)
+	})
+
+	queue.Notify(testRule(""), usecases.Alert{Firing: true})
+	queue.Close()
+
+	if len(failed) != 1 || !failed[0].Firing {
+		t.Errorf("expected the failed alert to be reported, got %+v", failed)
+	}
+}
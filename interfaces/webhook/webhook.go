@@ -0,0 +1,221 @@
+// Package webhook delivers alert notifications by posting signed JSON
+// payloads to HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// Headers sent with each notification. The signature is the hex encoded
+// HMAC-SHA256, keyed with the rule's secret, of the timestamp header, a dot
+// and the request body, prefixed with "sha256=". Receivers should reject
+// notifications whose timestamp is too old, so they cannot be replayed.
+const (
+	SignatureHeader = "X-Events-Signature"
+	TimestampHeader = "X-Events-Timestamp"
+)
+
+const (
+	// DefaultTimeout bounds each attempt at delivering a notification.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries is the number of times a failed delivery is retried.
+	DefaultMaxRetries = 3
+
+	// DefaultBackoff is the delay before the first retry. It doubles with
+	// each subsequent retry, up to MaxBackoff.
+	DefaultBackoff = 500 * time.Millisecond
+
+	// DefaultMaxBackoff bounds the delay between retries.
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// Payload is the body of a notification.
+type Payload struct {
+	RuleID      string `json:"rule_id"`
+	Tenant      string `json:"tenant"`
+	State       string `json:"state"`
+	EventName   string `json:"event_name"`
+	Threshold   int    `json:"threshold"`
+	Window      int64  `json:"window_seconds"`
+	Count       int    `json:"count"`
+	EvaluatedAt string `json:"evaluated_at"`
+}
+
+// States of a rule reported by a notification.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// ErrPrivateAddress is returned when a webhook resolves to an address which
+// is not public, as reported by usecases.IsPublicIP.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// Notifier posts notifications to the webhook of the rule they concern,
+// retrying with jittered exponential backoff after network errors, 429
+// responses and server errors. Any other response outside 2xx is not retried.
+type Notifier struct {
+	HTTPClient *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// AllowPrivate permits the HTTPClient of NewNotifier to connect to
+	// loopback, private and link-local addresses. Otherwise it refuses to,
+	// whatever name the webhook URL or a redirect gives.
+	AllowPrivate bool
+
+	sleep func(d time.Duration)
+}
+
+// NewNotifier returns a Notifier with default timeout and retry settings,
+// whose client refuses to connect to addresses which are not public.
+func NewNotifier() *Notifier {
+	notifier := &Notifier{
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+
+	dialer := &net.Dialer{Timeout: DefaultTimeout, Control: notifier.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	notifier.HTTPClient = &http.Client{Timeout: DefaultTimeout, Transport: transport}
+	return notifier
+}
+
+// checkAddress is called with each address the client connects to, once it
+// has been resolved.
+func (notifier *Notifier) checkAddress(network, address string, _ syscall.RawConn) error {
+	if notifier.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !usecases.IsPublicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Notify delivers `alert` to the webhook of `rule`, returning the error of
+// the last attempt if none succeeded.
+func (notifier *Notifier) Notify(rule domain.AlertRule, alert usecases.Alert) error {
+	state := StateResolved
+	if alert.Firing {
+		state = StateFiring
+	}
+
+	body, err := json.Marshal(Payload{
+		RuleID:      rule.ID,
+		Tenant:      rule.Tenant,
+		State:       state,
+		EventName:   rule.EventName,
+		Threshold:   rule.Threshold,
+		Window:      rule.Window,
+		Count:       alert.Count,
+		EvaluatedAt: alert.EvaluatedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := notifier.post(rule, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= notifier.MaxRetries {
+			return err
+		}
+		notifier.wait(notifier.backoff(attempt))
+	}
+}
+
+// post makes a single attempt at delivery, reporting whether a failure may
+// be retried.
+func (notifier *Notifier) post(rule domain.AlertRule, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", rule.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(rule.Secret, timestamp, body))
+
+	client := notifier.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		retry := !errors.Is(err, ErrPrivateAddress)
+		return retry, fmt.Errorf("delivering alert for rule %s: %v", rule.ID, err)
+	}
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+	return retry, fmt.Errorf("delivering alert for rule %s: webhook responded %s", rule.ID, res.Status)
+}
+
+// Sign returns the signature of a notification with the given timestamp and
+// body, as sent in the SignatureHeader.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before retry number `attempt`, doubling from
+// Backoff up to MaxBackoff, with jitter.
+func (notifier *Notifier) backoff(attempt int) time.Duration {
+	delay := notifier.Backoff
+	for i := 0; i < attempt && (notifier.MaxBackoff <= 0 || delay < notifier.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if notifier.MaxBackoff > 0 && delay > notifier.MaxBackoff {
+		delay = notifier.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (notifier *Notifier) wait(d time.Duration) {
+	if notifier.sleep != nil {
+		notifier.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/usecases"
+)
+
+// receiver is a webhook endpoint which answers with each of `statuses` in
+// turn, then 204, recording every notification whose signature is valid.
+type receiver struct {
+	server   *httptest.Server
+	secret   string
+	statuses []int
+
+	mu       sync.Mutex
+	attempts int
+	payloads []Payload
+	invalid  int
+}
+
+func newReceiver(secret string, statuses ...int) *receiver {
+	r := &receiver{secret: secret, statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+func (r *receiver) serve(res http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if req.Header.Get(SignatureHeader) != Sign(r.secret, timestamp, body) {
+		r.invalid++
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.attempts++
+	if r.attempts <= len(r.statuses) {
+		res.WriteHeader(r.statuses[r.attempts-1])
+		return
+	}
+
+	var payload Payload
+	json.Unmarshal(body, &payload)
+	r.payloads = append(r.payloads, payload)
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// newNotifier returns a Notifier which does not wait between retries, and
+// may deliver to the loopback addresses of test receivers.
+func newNotifier() *Notifier {
+	notifier := NewNotifier()
+	notifier.AllowPrivate = true
+	notifier.sleep = func(time.Duration) {}
+	return notifier
+}
+
+func testRule(url string) domain.AlertRule {
+	return domain.AlertRule{
+		ID:         "a1b2c3",
+		Tenant:     "acme",
+		EventName:  "payment_failed",
+		Threshold:  100,
+		Window:     300,
+		WebhookURL: url,
+		Secret:     "s3cret",
+	}
+}
+
+func TestNotifyPostsSignedPayload(t *testing.T) {
+	r := newReceiver("s3cret")
+	defer r.server.Close()
+
+	evaluatedAt := time.Date(2015, 2, 11, 15, 5, 0, 0, time.UTC)
+	err := newNotifier().Notify(testRule(r.server.URL), usecases.Alert{Firing: true, Count: 101, EvaluatedAt: evaluatedAt})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Payload{
+		RuleID:      "a1b2c3",
+		Tenant:      "acme",
+		State:       StateFiring,
+		EventName:   "payment_failed",
+		Threshold:   100,
+		Window:      300,
+		Count:       101,
+		EvaluatedAt: "2015-02-11T15:05:00Z",
+	}
+	if r.invalid != 0 || len(r.payloads) != 1 || r.payloads[0] != expected {
+		t.Errorf("expected payload %+v, got %+v (%d invalid)", expected, r.payloads, r.invalid)
+	}
+}
+
+func TestNotifySignatureRequiresSecret(t *testing.T) {
+	r := newReceiver("another secret")
+	defer r.server.Close()
+
+	if err := newNotifier().Notify(testRule(r.server.URL), usecases.Alert{}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if r.invalid != 1 {
+		t.Errorf("expected one rejected delivery, got %d", r.invalid)
+	}
+}
+
+func TestNotifyRetriesTemporaryFailures(t *testing.T) {
+	r := newReceiver("s3cret", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer r.server.Close()
+
+	if err := newNotifier().Notify(testRule(r.server.URL), usecases.Alert{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.attempts != 3 || len(r.payloads) != 1 || r.payloads[0].State != StateResolved {
+		t.Errorf("expected delivery on the third attempt, got %d attempts and %+v", r.attempts, r.payloads)
+	}
+}
+
+func TestNotifyGivesUpAfterMaxRetries(t *testing.T) {
+	r := newReceiver("s3cret", 500, 500, 500, 500, 500)
+	defer r.server.Close()
+
+	if err := newNotifier().Notify(testRule(r.server.URL), usecases.Alert{}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if r.attempts != DefaultMaxRetries+1 {
+		t.Errorf("expected %d attempts, got %d", DefaultMaxRetries+1, r.attempts)
+	}
+}
+
+func TestNotifyDoesNotRetryClientErrors(t *testing.T) {
+	r := newReceiver("s3cret", http.StatusNotFound)
+	defer r.server.Close()
+
+	if err := newNotifier().Notify(testRule(r.server.URL), usecases.Alert{}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if r.attempts != 1 {
+		t.Errorf("expected a single attempt, got %d", r.attempts)
+	}
+}
+
+func TestNotifyRetriesConnectionErrors(t *testing.T) {
+	r := newReceiver("s3cret")
+	url := r.server.URL
+	r.server.Close()
+
+	slept := 0
+	notifier := newNotifier()
+	notifier.sleep = func(time.Duration) { slept++ }
+
+	if err := notifier.Notify(testRule(url), usecases.Alert{}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if slept != DefaultMaxRetries {
+		t.Errorf("expected %d retries, got %d", DefaultMaxRetries, slept)
+	}
+}
+
+func TestNotifyRefusesPrivateAddresses(t *testing.T) {
+	r := newReceiver("s3cret")
+	defer r.server.Close()
+
+	slept := 0
+	notifier := newNotifier()
+	notifier.AllowPrivate = false
+	notifier.sleep = func(time.Duration) { slept++ }
+
+	if err := notifier.Notify(testRule(r.server.URL), usecases.Alert{}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if r.attempts != 0 || r.invalid != 0 || slept != 0 {
+		t.Errorf("expected no connection or retry, got %d attempts and %d retries", r.attempts+r.invalid, slept)
+	}
+}
@@ -0,0 +1,171 @@
+// Package storetest provides an in-memory domain.EventStore for the tests of
+// packages which depend on one.
+package storetest
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// EventStore keeps events in memory, separately for each tenant. Its zero
+// value is an empty store, safe for concurrent use.
+type EventStore struct {
+	// Normalize, if set, gives the form in which names are stored, and is
+	// inherited by the stores of tenants created afterwards
+	Normalize func(name string) string
+
+	// Err, if set, is returned by Put and CountInTimeRange
+	Err error
+
+	// DuringCount, if set, is called by CountInTimeRange after counting
+	DuringCount func()
+
+	mu      sync.Mutex
+	tenants map[string]*EventStore
+	events  []domain.Event
+	counted int
+}
+
+// NewEventStore returns an empty EventStore.
+func NewEventStore() *EventStore {
+	return new(EventStore)
+}
+
+func (store *EventStore) CountInTimeRange(name string, start, end int64) (int, error) {
+	store.mu.Lock()
+	store.counted++
+	store.mu.Unlock()
+	if store.Err != nil {
+		return 0, store.Err
+	}
+
+	count := len(store.matching(store.NormalizeName(name), start, end))
+	if store.DuringCount != nil {
+		store.DuringCount()
+	}
+	return count, nil
+}
+
+// Counted returns the number of times CountInTimeRange has been called.
+func (store *EventStore) Counted() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.counted
+}
+
+func (store *EventStore) CountInTimeRanges(name string, ranges [][2]int64) ([]int, error) {
+	counts := make([]int, len(ranges))
+	for i, r := range ranges {
+		count, err := store.CountInTimeRange(name, r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = count
+	}
+	return counts, nil
+}
+
+func (store *EventStore) ScanInTimeRange(name string, start, end int64, fn func(domain.Event) error) error {
+	for _, event := range store.matching(name, start, end) {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *EventStore) CountUniqueInTimeRange(name string, start, end int64) (int, error) {
+	actors := map[string]bool{}
+	for _, event := range store.matching(name, start, end) {
+		if event.Actor != "" {
+			actors[event.Actor] = true
+		}
+	}
+	return len(actors), nil
+}
+
+func (store *EventStore) SummarizeInTimeRange(name string, start, end int64) (*domain.Sketch, error) {
+	sketch := domain.NewSketch()
+	for _, event := range store.matching(name, start, end) {
+		if event.Value != nil {
+			sketch.Add(*event.Value)
+		}
+	}
+	return sketch, nil
+}
+
+func (store *EventStore) Names() ([]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, event := range store.events {
+		if !seen[event.Name] {
+			names = append(names, event.Name)
+			seen[event.Name] = true
+		}
+	}
+	return names, nil
+}
+
+func (store *EventStore) Put(event domain.Event) error {
+	if store.Err != nil {
+		return store.Err
+	}
+	event.Name = store.NormalizeName(event.Name)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.events = append(store.events, event)
+	return nil
+}
+
+func (store *EventStore) NormalizeName(name string) string {
+	if store.Normalize == nil {
+		return name
+	}
+	return store.Normalize(name)
+}
+
+func (store *EventStore) ForTenant(tenant string) domain.EventStore {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.tenants == nil {
+		store.tenants = map[string]*EventStore{}
+	}
+	if _, ok := store.tenants[tenant]; !ok {
+		store.tenants[tenant] = &EventStore{Normalize: store.Normalize}
+	}
+	return store.tenants[tenant]
+}
+
+// matching returns the events with a given name and timestamp between
+// `start` and `end`, in timestamp order.
+func (store *EventStore) matching(name string, start, end int64) []domain.Event {
+	store.mu.Lock()
+	var matching []domain.Event
+	for _, event := range store.events {
+		if event.Name == name && event.Timestamp >= start && event.Timestamp <= end {
+			matching = append(matching, event)
+		}
+	}
+	store.mu.Unlock()
+
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].Timestamp < matching[j].Timestamp })
+	return matching
+}
+
+// BasicEventStore supports none of the optional capabilities of the store
+// it wraps, only those of domain.EventStore.
+type BasicEventStore struct {
+	domain.EventStore
+}
+
+func (store BasicEventStore) ForTenant(tenant string) domain.EventStore {
+	return BasicEventStore{store.EventStore.ForTenant(tenant)}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/interfaces/cache"
 	"github.com/declantraynor/go-events-service/interfaces/datastore"
 	"github.com/declantraynor/go-events-service/interfaces/web"
+	"github.com/declantraynor/go-events-service/interfaces/webhook"
 	"github.com/declantraynor/go-events-service/usecases"
 )
 
@@ -18,17 +24,146 @@ func run(serve func(webservice *web.WebService)) error {
 		return err
 	}
 
+	keyStore, err := datastore.NewRedisAPIKeyStore(redisAddr, redisPort)
+	if err != nil {
+		return err
+	}
+
+	alertStore, err := datastore.NewRedisAlertRuleStore(redisAddr, redisPort)
+	if err != nil {
+		return err
+	}
+
+	// the cache is only invalidated by events stored through this replica,
+	// so it is off unless asked for
+	var countCacheTTL time.Duration
+	if value := os.Getenv("COUNT_CACHE_TTL"); value != "" {
+		if countCacheTTL, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid COUNT_CACHE_TTL %q", value)
+		}
+	}
+
+	countCacheSize := 10000
+	if value := os.Getenv("COUNT_CACHE_SIZE"); value != "" {
+		if countCacheSize, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid COUNT_CACHE_SIZE %q", value)
+		}
+	}
+
 	eventInteractor := usecases.EventInteractor{Store: &eventStore}
-	webservice := web.WebService{EventInteractor: &eventInteractor}
+	var metrics web.MetricsReporter
+	if countCacheTTL > 0 {
+		countCache := cache.New(countCacheTTL, countCacheSize)
+		eventInteractor.Store = cache.NewEventStore(&eventStore, countCache)
+		metrics = countCache
+	}
+	authInteractor := usecases.AuthInteractor{Keys: &keyStore}
+	allowPrivateWebhooks := false
+	if value := os.Getenv("ALLOW_PRIVATE_WEBHOOKS"); value != "" {
+		if allowPrivateWebhooks, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid ALLOW_PRIVATE_WEBHOOKS %q", value)
+		}
+	}
+
+	alertInteractor := usecases.AlertInteractor{
+		Rules:                &alertStore,
+		Events:               &eventStore,
+		AllowPrivateWebhooks: allowPrivateWebhooks,
+	}
+
+	// provision a known administrative key, which can be used to issue others
+	if adminKey := os.Getenv("ADMIN_API_KEY"); adminKey != "" {
+		options := usecases.KeyOptions{Scopes: []string{usecases.ScopeAdmin}}
+		if err := authInteractor.RegisterKey(adminKey, options); err != nil {
+			return err
+		}
+	}
+
+	readLimit, err := usecases.ParseRateLimit(os.Getenv("READ_RATE_LIMIT"))
+	if err != nil {
+		return err
+	}
+
+	writeLimit, err := usecases.ParseRateLimit(os.Getenv("WRITE_RATE_LIMIT"))
+	if err != nil {
+		return err
+	}
+
+	var maxBodyBytes int64
+	if value := os.Getenv("MAX_BODY_BYTES"); value != "" {
+		if maxBodyBytes, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid MAX_BODY_BYTES %q", value)
+		}
+	}
+
+	alertInterval := time.Minute
+	if value := os.Getenv("ALERT_INTERVAL"); value != "" {
+		if alertInterval, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid ALERT_INTERVAL %q", value)
+		}
+	}
+
+	rateLimiter, err := newRateLimiter(os.Getenv("RATE_LIMIT_BACKEND"), redisAddr, redisPort)
+	if err != nil {
+		return err
+	}
+
+	webservice := web.WebService{
+		EventInteractor: &eventInteractor,
+		AuthInteractor:  &authInteractor,
+		AlertInteractor: &alertInteractor,
+		MetricsReporter: metrics,
+		RateLimiter:     rateLimiter,
+		ReadLimit:       readLimit,
+		WriteLimit:      writeLimit,
+		MaxBodyBytes:    maxBodyBytes,
+		Logger:          web.NewLogger(os.Stdout),
+	}
+
+	notifier := webhook.NewNotifier()
+	notifier.AllowPrivate = allowPrivateWebhooks
+	alertInteractor.Notifier = webhook.NewQueue(notifier, webhook.DefaultWorkers, webhook.DefaultQueueSize,
+		func(rule domain.AlertRule, alert usecases.Alert, err error) {
+			// notify of the change again at the next evaluation
+			webservice.Logger.Log(web.Fields{"level": "error", "msg": err.Error()})
+			if err := alertInteractor.ReleaseAlert(rule, alert); err != nil {
+				webservice.Logger.Log(web.Fields{"level": "error", "msg": err.Error()})
+			}
+		})
+
+	if alertInterval > 0 {
+		go evaluateAlerts(&alertInteractor, alertInterval, webservice.Logger)
+	}
 
 	serve(&webservice)
 	return nil
 }
 
+// newRateLimiter returns a RateLimiter which keeps its state in memory, or in
+// redis if `backend` is "redis" so that replicas share each client's limit.
+func newRateLimiter(backend, redisAddr, redisPort string) (web.RateLimiter, error) {
+	switch backend {
+	case "", "memory":
+		return web.NewMemoryRateLimiter(), nil
+	case "redis":
+		limiter, err := datastore.NewRedisRateLimiter(redisAddr, redisPort)
+		return &limiter, err
+	}
+	return nil, fmt.Errorf("unknown rate limit backend %q", backend)
+}
+
+// evaluateAlerts evaluates every alert rule each `interval`, logging any
+// error encountered.
+func evaluateAlerts(interactor *usecases.AlertInteractor, interval time.Duration, logger *web.Logger) {
+	for range time.Tick(interval) {
+		if err := interactor.Evaluate(); err != nil {
+			logger.Log(web.Fields{"level": "error", "msg": err.Error()})
+		}
+	}
+}
+
 func serve(webservice *web.WebService) {
-	http.HandleFunc("/events", webservice.Create)
-	http.HandleFunc("/events/count", webservice.Count)
-	http.ListenAndServe(":5000", nil)
+	http.ListenAndServe(":5000", webservice.Handler())
 }
 
 func main() {
@@ -74,13 +74,13 @@ func TestCreateEventEndToEnd(t *testing.T) {
 			"test",
 			"2015/02/18",
 			http.StatusBadRequest,
-			`{"error": "2015/02/18 does not conform to ISO8601"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_iso8601", "title": "Timestamp does not conform to ISO8601", "status": 400, "detail": "2015/02/18 does not conform to ISO8601", "code": "timestamp.not_iso8601", "field": "timestamp"}`,
 		},
 		{
 			"test",
 			"2015-02-18T13:26:00-08:00",
 			http.StatusBadRequest,
-			`{"error": "2015-02-18T13:26:00-08:00 is not UTC"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_utc", "title": "Timestamp is not UTC", "status": 400, "detail": "2015-02-18T13:26:00-08:00 is not UTC", "code": "timestamp.not_utc", "field": "timestamp"}`,
 		},
 	}
 
@@ -143,31 +143,37 @@ func TestCountEventsEndToEnd(t *testing.T) {
 			"2015-02-01",
 			"2015-01-03T23:59:00+00:00",
 			http.StatusBadRequest,
-			`{"error": "2015-02-01 does not conform to ISO8601"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_iso8601", "title": "Timestamp does not conform to ISO8601", "status": 400, "detail": "2015-02-01 does not conform to ISO8601", "code": "timestamp.not_iso8601", "field": "from"}`,
 		},
 		{
 			"2015-02-01T13:16:13+00:00",
 			"02/01/2015",
 			http.StatusBadRequest,
-			`{"error": "02/01/2015 does not conform to ISO8601"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_iso8601", "title": "Timestamp does not conform to ISO8601", "status": 400, "detail": "02/01/2015 does not conform to ISO8601", "code": "timestamp.not_iso8601", "field": "to"}`,
 		},
 		{
 			"2015-01-03T13:16:13-05:00",
 			"2015-01-03T23:59:00+00:00",
 			http.StatusBadRequest,
-			`{"error": "2015-01-03T13:16:13-05:00 is not UTC"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_utc", "title": "Timestamp is not UTC", "status": 400, "detail": "2015-01-03T13:16:13-05:00 is not UTC", "code": "timestamp.not_utc", "field": "from"}`,
 		},
 		{
 			"2015-01-03T13:16:13+00:00",
 			"2015-01-03T23:59:00+05:00",
 			http.StatusBadRequest,
-			`{"error": "2015-01-03T23:59:00+05:00 is not UTC"}`,
+			`{"type": "urn:go-events-service:problem:timestamp.not_utc", "title": "Timestamp is not UTC", "status": 400, "detail": "2015-01-03T23:59:00+05:00 is not UTC", "code": "timestamp.not_utc", "field": "to"}`,
 		},
 		{
 			"2015-01-04T13:16:13+00:00",
 			"2015-01-03T23:59:00+00:00",
 			http.StatusBadRequest,
-			`{"error": "2015-01-04T13:16:13+00:00 is later than 2015-01-03T23:59:00+00:00"}`,
+			`{"type": "urn:go-events-service:problem:range.inverted", "title": "Time range is inverted", "status": 400, "detail": "2015-01-04T13:16:13+00:00 is later than 2015-01-03T23:59:00+00:00", "code": "range.inverted", "field": "from"}`,
+		},
+		{
+			"now-1y",
+			"now",
+			http.StatusBadRequest,
+			`{"type": "urn:go-events-service:problem:field.invalid", "title": "Field is invalid", "status": 400, "detail": "from has an unknown unit 'y'", "code": "field.invalid", "field": "from"}`,
 		},
 	}
 
@@ -188,8 +194,12 @@ func TestCountEventsEndToEnd(t *testing.T) {
 }
 
 func assertJSONResponse(t *testing.T, res *http.Response, status int, content string) {
-	if res.Header.Get("Content-Type") != "application/json; charset=utf-8" {
-		t.Error("expected JSON response")
+	contentType := "application/json; charset=utf-8"
+	if status >= http.StatusBadRequest {
+		contentType = web.ProblemContentType
+	}
+	if res.Header.Get("Content-Type") != contentType {
+		t.Errorf("expected Content-Type %q, got %q", contentType, res.Header.Get("Content-Type"))
 	}
 
 	if res.StatusCode != status {
@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"strings"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// aggregateQuantiles maps each percentile function accepted by
+// AggregateValues to its quantile.
+var aggregateQuantiles = map[string]float64{
+	"p50": 0.5,
+	"p95": 0.95,
+	"p99": 0.99,
+}
+
+// Aggregate is the result of applying an aggregate function to the values of
+// Count events. Value is nil if there were no values to aggregate, other
+// than for sums, which are zero.
+type Aggregate struct {
+	Count int64
+	Value *float64
+}
+
+// AggregateValues applies the function `fn`, one of sum, avg, min, max, p50,
+// p95 or p99, to the values of the events named `name` stored by `tenant`
+// with a timestamp in `timeRange`. Events without a value are
+// ignored. Percentiles are estimated to within domain.SketchAccuracy of
+// their true values; the other functions are exact.
+func (interactor *EventInteractor) AggregateValues(tenant, name string, timeRange TimeRange, fn string) (Aggregate, error) {
+	if strings.TrimSpace(name) == "" {
+		return Aggregate{}, domain.ValidationError{Field: "name", Reason: "is required"}
+	}
+
+	switch fn {
+	case "sum", "avg", "min", "max", "p50", "p95", "p99":
+	default:
+		return Aggregate{}, domain.ValidationError{
+			Field:  "fn",
+			Reason: "must be one of sum, avg, min, max, p50, p95 or p99",
+		}
+	}
+
+	start, end, err := timeRange.parse(interactor.now())
+	if err != nil {
+		return Aggregate{}, err
+	}
+
+	summarizer, ok := interactor.Store.ForTenant(tenant).(domain.ValueSummarizer)
+	if !ok {
+		return Aggregate{}, UnsupportedError{Operation: "aggregating values"}
+	}
+
+	sketch, err := summarizer.SummarizeInTimeRange(name, start, end)
+	if err != nil {
+		return Aggregate{}, err
+	}
+
+	aggregate := Aggregate{Count: sketch.Count}
+	if sketch.Count == 0 && fn != "sum" {
+		return aggregate, nil
+	}
+
+	var value float64
+	switch fn {
+	case "sum":
+		value = sketch.Sum
+	case "avg":
+		value = sketch.Mean()
+	case "min":
+		value = sketch.Min
+	case "max":
+		value = sketch.Max
+	default:
+		value = sketch.Quantile(aggregateQuantiles[fn])
+	}
+	aggregate.Value = &value
+	return aggregate, nil
+}
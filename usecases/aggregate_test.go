@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"math"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+func newAggregateInteractor(t *testing.T) EventInteractor {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	for i := 1; i <= 100; i++ {
+		value := float64(i)
+		addEvent(t, interactor, "acme", "checkout", "2015-02-11T15:01:00Z", EventOptions{Value: &value})
+	}
+	addEvents(t, interactor, "checkout", "2015-02-11T15:01:30Z", 1)
+	outside := 1000.0
+	addEvent(t, interactor, "acme", "checkout", "2015-02-11T15:02:00Z", EventOptions{Value: &outside})
+	addEvent(t, interactor, "globex", "checkout", "2015-02-11T15:01:00Z", EventOptions{Value: &outside})
+	return interactor
+}
+
+func TestAggregateValues(t *testing.T) {
+	interactor := newAggregateInteractor(t)
+	cases := map[string]float64{
+		"sum": 5050,
+		"avg": 50.5,
+		"min": 1,
+		"max": 100,
+		"p50": 50,
+		"p95": 95,
+		"p99": 99,
+	}
+
+	for fn, expected := range cases {
+		aggregate, err := interactor.AggregateValues("acme", "checkout", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"}, fn)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if aggregate.Count != 100 || aggregate.Value == nil {
+			t.Fatalf("expected %s of 100 values, got %+v", fn, aggregate)
+		}
+		if math.Abs(*aggregate.Value-expected) > domain.SketchAccuracy*expected {
+			t.Errorf("expected %s of %v, got %v", fn, expected, *aggregate.Value)
+		}
+	}
+}
+
+func TestAggregateValuesWithoutEvents(t *testing.T) {
+	interactor := newAggregateInteractor(t)
+
+	aggregate, _ := interactor.AggregateValues("acme", "checkout", TimeRange{From: "2015-02-11T16:00:00Z", To: "2015-02-11T17:00:00Z"}, "max")
+	if aggregate.Count != 0 || aggregate.Value != nil {
+		t.Errorf("expected no maximum, got %+v", aggregate)
+	}
+
+	aggregate, _ = interactor.AggregateValues("acme", "checkout", TimeRange{From: "2015-02-11T16:00:00Z", To: "2015-02-11T17:00:00Z"}, "sum")
+	if aggregate.Value == nil || *aggregate.Value != 0 {
+		t.Errorf("expected a sum of zero, got %+v", aggregate)
+	}
+}
+
+func TestAggregateValuesInvalidFunction(t *testing.T) {
+	interactor := newAggregateInteractor(t)
+	_, err := interactor.AggregateValues("acme", "checkout", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"}, "median")
+
+	if e, ok := err.(domain.ValidationError); !ok || e.Field != "fn" {
+		t.Errorf("expected ValidationError for fn, got %v", err)
+	}
+}
+
+func TestAggregateValuesUnsupported(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+	_, err := interactor.AggregateValues("acme", "checkout", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"}, "sum")
+
+	if err != (UnsupportedError{Operation: "aggregating values"}) {
+		t.Errorf("expected UnsupportedError, got %v", err)
+	}
+}
+
+func TestAddEventRejectsNonFiniteValues(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+	value := math.Inf(1)
+	err := interactor.AddEvent("acme", "checkout", "2015-02-11T15:01:00Z", EventOptions{Value: &value})
+
+	if e, ok := err.(domain.ValidationError); !ok || e.Field != "value" {
+		t.Errorf("expected ValidationError for value, got %v", err)
+	}
+}
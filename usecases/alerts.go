@@ -0,0 +1,255 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// AlertNotifier delivers notice of a change in whether a rule is firing. It
+// may deliver it later, in which case it should call
+// AlertInteractor.ReleaseAlert if it cannot.
+type AlertNotifier interface {
+	Notify(rule domain.AlertRule, alert Alert) error
+}
+
+// Alert describes a rule starting or stopping firing, with the number of
+// events counted in its window when it was evaluated.
+type Alert struct {
+	Firing      bool
+	Count       int
+	EvaluatedAt time.Time
+}
+
+// AlertRuleOptions describe a rule to be created or updated. Window is a
+// duration such as "5m".
+type AlertRuleOptions struct {
+	EventName  string
+	Threshold  int
+	Window     string
+	WebhookURL string
+}
+
+type AlertInteractor struct {
+	Rules    domain.AlertRuleStore
+	Events   domain.EventStore
+	Notifier AlertNotifier
+
+	// AllowPrivateWebhooks permits webhook URLs whose host is localhost or a
+	// loopback, private or link-local address, which are otherwise rejected
+	// so that tenants cannot make the service post to internal endpoints.
+	AllowPrivateWebhooks bool
+
+	// Now returns the time rules are evaluated at, defaulting to time.Now.
+	Now func() time.Time
+}
+
+// CreateRule validates and stores a new rule for `tenant`, generating its ID
+// and the secret its notifications are signed with. It returns the rule as
+// well as any error encountered.
+func (interactor *AlertInteractor) CreateRule(tenant string, options AlertRuleOptions) (domain.AlertRule, error) {
+	rule, err := interactor.ruleFromOptions(options)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+
+	if rule.ID, err = randomHex(8); err != nil {
+		return domain.AlertRule{}, err
+	}
+	if rule.Secret, err = randomHex(32); err != nil {
+		return domain.AlertRule{}, err
+	}
+	rule.Tenant = tenant
+
+	if err := interactor.Rules.Put(rule); err != nil {
+		return domain.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+// GetRule returns the rule of `tenant` identified by `id`, as well as any
+// error encountered.
+func (interactor *AlertInteractor) GetRule(tenant, id string) (domain.AlertRule, error) {
+	return interactor.Rules.Get(tenant, id)
+}
+
+// ListRules returns every rule of `tenant`, as well as any error encountered.
+func (interactor *AlertInteractor) ListRules(tenant string) ([]domain.AlertRule, error) {
+	return interactor.Rules.List(tenant)
+}
+
+// UpdateRule replaces the definition of an existing rule, keeping its ID,
+// secret and whether it is firing. It returns the updated rule as well as any
+// error encountered.
+func (interactor *AlertInteractor) UpdateRule(tenant, id string, options AlertRuleOptions) (domain.AlertRule, error) {
+	rule, err := interactor.ruleFromOptions(options)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+
+	rule.ID, rule.Tenant = id, tenant
+	return interactor.Rules.Update(rule)
+}
+
+// DeleteRule deletes the rule of `tenant` identified by `id`.
+func (interactor *AlertInteractor) DeleteRule(tenant, id string) error {
+	return interactor.Rules.Delete(tenant, id)
+}
+
+// Evaluate counts the events in the window of every rule, notifying of each
+// rule which has started or stopped firing since it was last evaluated.
+// Every rule is evaluated even if some fail, and the first error encountered
+// is returned.
+func (interactor *AlertInteractor) Evaluate() error {
+	rules, err := interactor.Rules.All()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now
+	if interactor.Now != nil {
+		now = interactor.Now
+	}
+	evaluatedAt := now().UTC()
+
+	var first error
+	for _, rule := range rules {
+		if err := interactor.evaluate(rule, evaluatedAt); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (interactor *AlertInteractor) evaluate(rule domain.AlertRule, evaluatedAt time.Time) error {
+	end := evaluatedAt.Unix()
+	events := interactor.Events.ForTenant(rule.Tenant)
+	count, err := events.CountInTimeRange(rule.EventName, end-rule.Window+1, end)
+	if err != nil {
+		return err
+	}
+
+	firing := count > rule.Threshold
+	if firing == rule.Firing {
+		return nil
+	}
+
+	// claim the change before notifying of it, so that when several
+	// instances of the service evaluate the rule at once only one notifies
+	changed, err := interactor.Rules.SetFiring(rule.Tenant, rule.ID, firing)
+	if err != nil || !changed {
+		return err
+	}
+
+	if err := interactor.Notifier.Notify(rule, Alert{Firing: firing, Count: count, EvaluatedAt: evaluatedAt}); err != nil {
+		// release the change, so that it is notified at the next evaluation
+		interactor.Rules.SetFiring(rule.Tenant, rule.ID, !firing)
+		return err
+	}
+	return nil
+}
+
+// ReleaseAlert undoes the change in whether `rule` is firing which `alert`
+// notified of, after it could not be delivered, so that it is notified again
+// at the next evaluation.
+func (interactor *AlertInteractor) ReleaseAlert(rule domain.AlertRule, alert Alert) error {
+	_, err := interactor.Rules.SetFiring(rule.Tenant, rule.ID, !alert.Firing)
+	return err
+}
+
+// ruleFromOptions validates `options`, returning the rule they describe.
+func (interactor *AlertInteractor) ruleFromOptions(options AlertRuleOptions) (domain.AlertRule, error) {
+	if options.EventName == "" {
+		return domain.AlertRule{}, domain.ValidationError{Field: "event_name", Reason: "is required"}
+	}
+
+	if options.Threshold < 0 {
+		return domain.AlertRule{}, domain.ValidationError{Field: "threshold", Reason: "must not be negative"}
+	}
+
+	window, err := time.ParseDuration(options.Window)
+	if err != nil || window < time.Second || window%time.Second != 0 {
+		return domain.AlertRule{}, domain.ValidationError{Field: "window", Reason: "must be a whole number of seconds, such as 5m"}
+	}
+
+	u, err := url.Parse(options.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.AlertRule{}, domain.ValidationError{Field: "webhook_url", Reason: "must be an absolute http or https URL"}
+	}
+	if !interactor.AllowPrivateWebhooks && !isPublicHost(u.Hostname()) {
+		return domain.AlertRule{}, domain.ValidationError{Field: "webhook_url", Reason: "must not address a loopback, private or link-local host"}
+	}
+
+	return domain.AlertRule{
+		EventName:  options.EventName,
+		Threshold:  options.Threshold,
+		Window:     int64(window / time.Second),
+		WebhookURL: options.WebhookURL,
+	}, nil
+}
+
+// nonPublicNetworks are the loopback, private, link-local, shared, multicast
+// and reserved networks, which webhooks may not address unless
+// AllowPrivateWebhooks is set.
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}
+
+// IsPublicIP reports whether `ip` is an address webhooks may be delivered
+// to: not a loopback, private, link-local, multicast or reserved address.
+func IsPublicIP(ip net.IP) bool {
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPublicHost reports whether the host of a webhook URL may be public. Names
+// other than localhost are resolved when notifications are delivered, so the
+// notifier must check the addresses they resolve to.
+func isPublicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPublicIP(ip)
+	}
+	return true
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
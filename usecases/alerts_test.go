@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+var validRuleOptions = AlertRuleOptions{
+	EventName:  "payment_failed",
+	Threshold:  2,
+	Window:     "5m",
+	WebhookURL: "https://example.com/hooks/alerts",
+}
+
+func TestCreateRule(t *testing.T) {
+	rules := new(StubAlertRuleStore)
+	interactor := AlertInteractor{Rules: rules}
+
+	rule, err := interactor.CreateRule("acme", validRuleOptions)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if rule.ID == "" || len(rule.Secret) != 64 {
+		t.Errorf("expected a generated ID and secret, got %+v", rule)
+	}
+	if rule.Tenant != "acme" || rule.Window != 300 || rule.Threshold != 2 || rule.Firing {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+	if stored, _ := rules.Get("acme", rule.ID); stored != rule {
+		t.Errorf("expected rule to be stored, got %+v", stored)
+	}
+}
+
+func TestCreateRuleValidatesOptions(t *testing.T) {
+	invalid := map[string]func(*AlertRuleOptions){
+		"event_name":  func(o *AlertRuleOptions) { o.EventName = "" },
+		"threshold":   func(o *AlertRuleOptions) { o.Threshold = -1 },
+		"window":      func(o *AlertRuleOptions) { o.Window = "500ms" },
+		"webhook_url": func(o *AlertRuleOptions) { o.WebhookURL = "ftp://example.com/" },
+	}
+
+	for field, invalidate := range invalid {
+		options := validRuleOptions
+		invalidate(&options)
+
+		interactor := AlertInteractor{Rules: new(StubAlertRuleStore)}
+		_, err := interactor.CreateRule("acme", options)
+		if e, ok := err.(domain.ValidationError); !ok || e.Field != field {
+			t.Errorf("expected ValidationError for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestCreateRuleRejectsPrivateWebhooks(t *testing.T) {
+	urls := []string{
+		"http://localhost/hooks",
+		"http://api.localhost:8080/hooks",
+		"http://127.0.0.1/hooks",
+		"http://10.1.2.3/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]:5000/hooks",
+		"http://[fd00::1]/hooks",
+		"http://0.0.0.0/hooks",
+	}
+
+	for _, url := range urls {
+		options := validRuleOptions
+		options.WebhookURL = url
+
+		interactor := AlertInteractor{Rules: new(StubAlertRuleStore)}
+		_, err := interactor.CreateRule("acme", options)
+		if e, ok := err.(domain.ValidationError); !ok || e.Field != "webhook_url" {
+			t.Errorf("%s: expected ValidationError for webhook_url, got %v", url, err)
+		}
+
+		interactor.AllowPrivateWebhooks = true
+		if _, err := interactor.CreateRule("acme", options); err != nil {
+			t.Errorf("%s: expected private webhooks to be allowed, got %v", url, err)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:2800:220::1": true,
+		"172.15.0.1":       true,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"100.64.0.1":       false,
+		"::ffff:127.0.0.1": false,
+		"fe80::1":          false,
+	}
+
+	for address, public := range cases {
+		if IsPublicIP(net.ParseIP(address)) != public {
+			t.Errorf("%s: expected public to be %v", address, public)
+		}
+	}
+}
+
+func TestUpdateRuleKeepsIdentityAndState(t *testing.T) {
+	rules := new(StubAlertRuleStore)
+	interactor := AlertInteractor{Rules: rules}
+	rule, _ := interactor.CreateRule("acme", validRuleOptions)
+	rules.SetFiring("acme", rule.ID, true)
+
+	options := validRuleOptions
+	options.Threshold = 10
+	updated, err := interactor.UpdateRule("acme", rule.ID, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if updated.ID != rule.ID || updated.Secret != rule.Secret || !updated.Firing || updated.Threshold != 10 {
+		t.Errorf("unexpected rule %+v", updated)
+	}
+}
+
+func TestUpdateRuleNotFound(t *testing.T) {
+	interactor := AlertInteractor{Rules: new(StubAlertRuleStore)}
+	if _, err := interactor.UpdateRule("acme", "missing", validRuleOptions); err != domain.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEvaluateNotifiesWhenRuleStartsAndStopsFiring(t *testing.T) {
+	now := time.Date(2015, 2, 11, 15, 5, 0, 0, time.UTC)
+	events := storetest.NewEventStore()
+	rules := new(StubAlertRuleStore)
+	notifier := new(StubAlertNotifier)
+	interactor := AlertInteractor{Rules: rules, Events: events, Notifier: notifier, Now: func() time.Time { return now }}
+
+	rule, _ := interactor.CreateRule("acme", validRuleOptions)
+
+	// an event outside the window, and one belonging to another tenant
+	events.ForTenant("acme").Put(domain.Event{Name: "payment_failed", Timestamp: now.Unix() - 300})
+	events.ForTenant("other").Put(domain.Event{Name: "payment_failed", Timestamp: now.Unix()})
+	for i := 0; i < 2; i++ {
+		events.ForTenant("acme").Put(domain.Event{Name: "payment_failed", Timestamp: now.Unix() - 299})
+	}
+	interactor.Evaluate()
+	if len(notifier.alerts) != 0 {
+		t.Fatalf("expected no alerts at the threshold, got %+v", notifier.alerts)
+	}
+
+	events.ForTenant("acme").Put(domain.Event{Name: "payment_failed", Timestamp: now.Unix()})
+	interactor.Evaluate()
+	interactor.Evaluate()
+	if len(notifier.alerts) != 1 || !notifier.alerts[0].Firing || notifier.alerts[0].Count != 3 {
+		t.Fatalf("expected a single firing alert, got %+v", notifier.alerts)
+	}
+	if !notifier.alerts[0].EvaluatedAt.Equal(now) {
+		t.Errorf("expected alert evaluated at %s, got %s", now, notifier.alerts[0].EvaluatedAt)
+	}
+	if stored, _ := rules.Get("acme", rule.ID); !stored.Firing {
+		t.Error("expected rule to be recorded as firing")
+	}
+
+	now = now.Add(5 * time.Minute)
+	interactor.Evaluate()
+	if len(notifier.alerts) != 2 || notifier.alerts[1].Firing || notifier.alerts[1].Count != 0 {
+		t.Errorf("expected a resolved alert, got %+v", notifier.alerts)
+	}
+}
+
+func TestEvaluateDoesNotNotifyChangesClaimedElsewhere(t *testing.T) {
+	rules := &StubAlertRuleStore{lostRace: true}
+	notifier := new(StubAlertNotifier)
+	interactor := AlertInteractor{Rules: rules, Events: new(StubEventStore), Notifier: notifier}
+
+	options := validRuleOptions
+	options.EventName = "foo"
+	interactor.CreateRule("acme", options)
+
+	if err := interactor.Evaluate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(notifier.alerts) != 0 {
+		t.Errorf("expected no alerts, got %+v", notifier.alerts)
+	}
+}
+
+func TestEvaluateRetriesFailedNotifications(t *testing.T) {
+	rules := new(StubAlertRuleStore)
+	notifier := &StubAlertNotifier{err: errors.New("webhook unavailable")}
+	interactor := AlertInteractor{Rules: rules, Events: new(StubEventStore), Notifier: notifier}
+
+	options := validRuleOptions
+	options.EventName = "foo"
+	rule, _ := interactor.CreateRule("acme", options)
+
+	if err := interactor.Evaluate(); err != notifier.err {
+		t.Fatalf("expected notifier error, got %v", err)
+	}
+	if stored, _ := rules.Get("acme", rule.ID); stored.Firing {
+		t.Error("expected rule not to be recorded as firing")
+	}
+
+	notifier.err = nil
+	interactor.Evaluate()
+	if len(notifier.alerts) != 1 || !notifier.alerts[0].Firing {
+		t.Errorf("expected the alert to be delivered, got %+v", notifier.alerts)
+	}
+}
+
+func TestReleaseAlert(t *testing.T) {
+	rules := new(StubAlertRuleStore)
+	notifier := new(StubAlertNotifier)
+	interactor := AlertInteractor{Rules: rules, Events: new(StubEventStore), Notifier: notifier}
+
+	options := validRuleOptions
+	options.EventName = "foo"
+	rule, _ := interactor.CreateRule("acme", options)
+
+	// a notification accepted for later delivery which then fails
+	interactor.Evaluate()
+	if err := interactor.ReleaseAlert(rule, notifier.alerts[0]); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stored, _ := rules.Get("acme", rule.ID); stored.Firing {
+		t.Error("expected rule not to be recorded as firing")
+	}
+
+	interactor.Evaluate()
+	if len(notifier.alerts) != 2 || !notifier.alerts[1].Firing {
+		t.Errorf("expected the alert to be notified again, got %+v", notifier.alerts)
+	}
+}
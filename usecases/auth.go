@@ -0,0 +1,119 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// Scopes which may be granted to an API key. A key with ScopeAdmin is
+// permitted to perform any operation.
+const (
+	ScopeWrite  = "events:write"
+	ScopeRead   = "events:read"
+	ScopeAlerts = "alerts:manage"
+	ScopeAdmin  = "admin"
+)
+
+var validScopes = map[string]bool{
+	ScopeWrite:  true,
+	ScopeRead:   true,
+	ScopeAlerts: true,
+	ScopeAdmin:  true,
+}
+
+type AuthInteractor struct {
+	Keys domain.APIKeyStore
+}
+
+// KeyOptions describe the permissions and limits granted to a new API key.
+// An empty Tenant allows the key to be used with any tenant, and zero rate
+// limits defer to those of the service as a whole.
+type KeyOptions struct {
+	Tenant     string
+	Scopes     []string
+	ReadLimit  domain.RateLimit
+	WriteLimit domain.RateLimit
+}
+
+// HashAPIKey returns the hex encoded SHA-256 digest of an API key. Only the
+// digest is ever stored, so keys cannot be recovered from the backing store.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate looks up the API key presented by a client, returning the
+// stored key as well as any error encountered. An UnauthenticatedError is
+// returned if the key is missing or unknown.
+func (interactor *AuthInteractor) Authenticate(key string) (domain.APIKey, error) {
+	if key == "" {
+		return domain.APIKey{}, UnauthenticatedError{Reason: "missing API key"}
+	}
+
+	stored, err := interactor.Keys.Get(HashAPIKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.APIKey{}, UnauthenticatedError{Reason: "invalid API key"}
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	return stored, nil
+}
+
+// Authorize returns a ForbiddenError unless `key` has been granted `scope`.
+func (interactor *AuthInteractor) Authorize(key domain.APIKey, scope string) error {
+	for _, granted := range key.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return nil
+		}
+	}
+	return ForbiddenError{Scope: scope}
+}
+
+// CreateKey generates and stores a new random API key with the given options.
+// It returns the key, which cannot be retrieved again later, as well as any
+// error encountered.
+func (interactor *AuthInteractor) CreateKey(options KeyOptions) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	key := hex.EncodeToString(buf)
+	if err := interactor.RegisterKey(key, options); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// RegisterKey stores a caller-supplied API key with the given options, which
+// is useful for provisioning a known administrative key at startup.
+func (interactor *AuthInteractor) RegisterKey(key string, options KeyOptions) error {
+	if options.Tenant != "" {
+		if err := ValidateTenant(options.Tenant); err != nil {
+			return err
+		}
+	}
+
+	if len(options.Scopes) == 0 {
+		return InvalidScopeError{}
+	}
+	for _, scope := range options.Scopes {
+		if !validScopes[scope] {
+			return InvalidScopeError{Scope: scope}
+		}
+	}
+
+	return interactor.Keys.Put(domain.APIKey{
+		Hash:       HashAPIKey(key),
+		Scopes:     options.Scopes,
+		Tenant:     options.Tenant,
+		ReadLimit:  options.ReadLimit,
+		WriteLimit: options.WriteLimit,
+	})
+}
@@ -0,0 +1,135 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	expected := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if hash := HashAPIKey("test"); hash != expected {
+		t.Errorf("expected hash %q, got %q", expected, hash)
+	}
+}
+
+func TestCreateKeyStoresHash(t *testing.T) {
+	store := new(StubAPIKeyStore)
+	interactor := AuthInteractor{Keys: store}
+
+	key, err := interactor.CreateKey(KeyOptions{Scopes: []string{ScopeRead}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, ok := store.keys[key]; ok {
+		t.Error("API key stored in plain text")
+	}
+	if _, ok := store.keys[HashAPIKey(key)]; !ok {
+		t.Error("API key hash not stored")
+	}
+}
+
+func TestCreateKeyStoresRateLimits(t *testing.T) {
+	store := new(StubAPIKeyStore)
+	interactor := AuthInteractor{Keys: store}
+	limit := domain.RateLimit{Rate: 5, Burst: 10}
+
+	key, _ := interactor.CreateKey(KeyOptions{Scopes: []string{ScopeWrite}, WriteLimit: limit})
+	if stored := store.keys[HashAPIKey(key)]; stored.WriteLimit != limit || !stored.ReadLimit.IsZero() {
+		t.Errorf("unexpected rate limits stored %v", stored)
+	}
+}
+
+func TestCreateKeyInvalidScope(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStore)}
+
+	for _, scopes := range [][]string{{}, {ScopeRead, "events:delete"}} {
+		if _, err := interactor.CreateKey(KeyOptions{Scopes: scopes}); err == nil {
+			t.Errorf("expected InvalidScopeError for scopes %v", scopes)
+		} else if _, ok := err.(InvalidScopeError); !ok {
+			t.Errorf("expected InvalidScopeError, got %T", err)
+		}
+	}
+}
+
+func TestCreateKeyInvalidTenant(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStore)}
+
+	if _, err := interactor.CreateKey(KeyOptions{Tenant: "acme:corp", Scopes: []string{ScopeRead}}); err == nil {
+		t.Error("expected InvalidTenantError")
+	} else if _, ok := err.(InvalidTenantError); !ok {
+		t.Errorf("expected InvalidTenantError, got %T", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStore)}
+	interactor.RegisterKey("secret", KeyOptions{Tenant: "acme", Scopes: []string{ScopeWrite}})
+
+	key, err := interactor.Authenticate("secret")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if key.Hash != HashAPIKey("secret") || len(key.Scopes) != 1 || key.Scopes[0] != ScopeWrite || key.Tenant != "acme" {
+		t.Errorf("unexpected key %v", key)
+	}
+}
+
+func TestAuthenticateRejectsMissingAndUnknownKeys(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStore)}
+	interactor.RegisterKey("secret", KeyOptions{Tenant: "acme", Scopes: []string{ScopeWrite}})
+
+	for _, key := range []string{"", "wrong"} {
+		if _, err := interactor.Authenticate(key); err == nil {
+			t.Errorf("expected error authenticating %q", key)
+		} else if _, ok := err.(UnauthenticatedError); !ok {
+			t.Errorf("expected UnauthenticatedError, got %T", err)
+		}
+	}
+}
+
+func TestAuthenticateRejectsKeysWhichAreNotFound(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStoreWrappingNotFound)}
+
+	if _, err := interactor.Authenticate("secret"); err != (UnauthenticatedError{Reason: "invalid API key"}) {
+		t.Errorf("expected UnauthenticatedError, got %v", err)
+	}
+}
+
+func TestAuthenticateStoreError(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStoreWithGetError)}
+
+	_, err := interactor.Authenticate("secret")
+	if _, ok := err.(UnauthenticatedError); ok || err == nil {
+		t.Errorf("expected error from APIKeyStore.Get, got %v", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	interactor := AuthInteractor{Keys: new(StubAPIKeyStore)}
+
+	cases := []struct {
+		scopes  []string
+		scope   string
+		allowed bool
+	}{
+		{[]string{ScopeWrite}, ScopeWrite, true},
+		{[]string{ScopeWrite}, ScopeRead, false},
+		{[]string{ScopeRead, ScopeWrite}, ScopeRead, true},
+		{[]string{ScopeAdmin}, ScopeRead, true},
+		{[]string{}, ScopeRead, false},
+	}
+
+	for _, c := range cases {
+		err := interactor.Authorize(domain.APIKey{Scopes: c.scopes}, c.scope)
+		if c.allowed && err != nil {
+			t.Errorf("expected scopes %v to allow %q", c.scopes, c.scope)
+		}
+		if !c.allowed {
+			if _, ok := err.(ForbiddenError); !ok {
+				t.Errorf("expected ForbiddenError for scopes %v and %q, got %T", c.scopes, c.scope, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// Periods against which CompareEventsInTimeRange compares a time range: the
+// range of the same length ending just before it, or the same range a week
+// earlier.
+const (
+	ComparePreviousPeriod = "previous_period"
+	ComparePreviousWeek   = "previous_week"
+)
+
+const week = 7 * 24 * 60 * 60
+
+// Comparison is the number of events of one name in a time range and in the
+// period it is compared with. PercentChange is nil if there were no events
+// in the comparison period, since any change from zero is unbounded.
+type Comparison struct {
+	Count           int
+	ComparisonCount int
+	Delta           int
+	PercentChange   *float64
+}
+
+// CompareEventsInTimeRange returns, for each name with events stored by
+// `tenant` with a timestamp in `timeRange` or in the period `compare`, the
+// number in each, and the change between them. Names with events in only
+// one of the periods have a count of zero in the other.
+func (interactor *EventInteractor) CompareEventsInTimeRange(tenant string, timeRange TimeRange, compare string) (map[string]Comparison, error) {
+	start, end, err := timeRange.parse(interactor.now())
+	if err != nil {
+		return nil, err
+	}
+
+	comparisonStart, comparisonEnd, err := comparisonPeriod(compare, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	store := interactor.Store.ForTenant(tenant)
+	counts, err := countAll(store, start, end)
+	if err != nil {
+		return nil, err
+	}
+	comparisonCounts, err := countAll(store, comparisonStart, comparisonEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons := map[string]Comparison{}
+	for name, count := range counts {
+		comparisons[name] = compareCounts(count, comparisonCounts[name])
+	}
+	for name, count := range comparisonCounts {
+		if _, ok := counts[name]; !ok {
+			comparisons[name] = compareCounts(0, count)
+		}
+	}
+	return comparisons, nil
+}
+
+// comparisonPeriod returns the start and end of the period named `compare`
+// with which the time range between `start` and `end` is compared.
+func comparisonPeriod(compare string, start, end int64) (int64, int64, error) {
+	switch compare {
+	case ComparePreviousPeriod:
+		length := end - start + 1
+		return start - length, start - 1, nil
+	case ComparePreviousWeek:
+		return start - week, end - week, nil
+	}
+	return 0, 0, domain.ValidationError{Field: "compare", Reason: "must be previous_period or previous_week"}
+}
+
+func compareCounts(count, comparisonCount int) Comparison {
+	comparison := Comparison{
+		Count:           count,
+		ComparisonCount: comparisonCount,
+		Delta:           count - comparisonCount,
+	}
+	if comparisonCount != 0 {
+		percent := float64(comparison.Delta) / float64(comparisonCount) * 100
+		comparison.PercentChange = &percent
+	}
+	return comparison
+}
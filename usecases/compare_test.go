@@ -0,0 +1,67 @@
+package usecases
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+func newCompareInteractor(t *testing.T) EventInteractor {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+
+	// a week earlier, the previous hour, and the hour being compared
+	addEvents(t, interactor, "login", "2015-02-04T15:10:00Z", 8)
+	addEvents(t, interactor, "login", "2015-02-11T14:10:00Z", 4)
+	addEvents(t, interactor, "logout", "2015-02-11T14:20:00Z", 2)
+	addEvents(t, interactor, "login", "2015-02-11T15:10:00Z", 6)
+	addEvents(t, interactor, "signup", "2015-02-11T15:20:00Z", 3)
+	return interactor
+}
+
+func percent(p float64) *float64 {
+	return &p
+}
+
+func TestCompareWithPreviousPeriod(t *testing.T) {
+	interactor := newCompareInteractor(t)
+	comparisons, err := interactor.CompareEventsInTimeRange("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, ComparePreviousPeriod)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]Comparison{
+		"login":  {Count: 6, ComparisonCount: 4, Delta: 2, PercentChange: percent(50)},
+		"logout": {Count: 0, ComparisonCount: 2, Delta: -2, PercentChange: percent(-100)},
+		"signup": {Count: 3, ComparisonCount: 0, Delta: 3},
+	}
+	if !reflect.DeepEqual(comparisons, expected) {
+		t.Errorf("expected %+v, got %+v", expected, comparisons)
+	}
+}
+
+func TestCompareWithPreviousWeek(t *testing.T) {
+	interactor := newCompareInteractor(t)
+	comparisons, err := interactor.CompareEventsInTimeRange("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, ComparePreviousWeek)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]Comparison{
+		"login":  {Count: 6, ComparisonCount: 8, Delta: -2, PercentChange: percent(-25)},
+		"signup": {Count: 3, ComparisonCount: 0, Delta: 3},
+	}
+	if !reflect.DeepEqual(comparisons, expected) {
+		t.Errorf("expected %+v, got %+v", expected, comparisons)
+	}
+}
+
+func TestCompareInvalidPeriod(t *testing.T) {
+	interactor := newCompareInteractor(t)
+	_, err := interactor.CompareEventsInTimeRange("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, "yesterday")
+
+	if e, ok := err.(domain.ValidationError); !ok || e.Field != "compare" {
+		t.Errorf("expected ValidationError for compare, got %v", err)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 )
 
+// InvalidTimestampError describes a timestamp which is not an ISO8601 UTC
+// time value. Field names the parameter which held it, if known.
 type InvalidTimestampError struct {
 	Timestamp  string
+	Field      string
 	NotISO8601 bool
 	NotUTC     bool
 }
@@ -28,3 +31,75 @@ type InvalidTimeRangeError struct {
 func (err InvalidTimeRangeError) Error() string {
 	return fmt.Sprintf("%s is later than %s", err.From, err.To)
 }
+
+// EmptyTimeRangeError is returned for a time range which, though From is
+// before To, includes no whole second, so no event can fall within it.
+type EmptyTimeRangeError struct {
+	From string
+	To   string
+}
+
+func (err EmptyTimeRangeError) Error() string {
+	return fmt.Sprintf("%s to %s includes no whole second", err.From, err.To)
+}
+
+type UnauthenticatedError struct {
+	Reason string
+}
+
+func (err UnauthenticatedError) Error() string {
+	return err.Reason
+}
+
+type ForbiddenError struct {
+	Scope string
+}
+
+func (err ForbiddenError) Error() string {
+	return fmt.Sprintf("API key lacks scope %q", err.Scope)
+}
+
+type InvalidScopeError struct {
+	Scope string
+}
+
+func (err InvalidScopeError) Error() string {
+	if err.Scope == "" {
+		return "at least one scope is required"
+	}
+	return fmt.Sprintf("%q is not a valid scope", err.Scope)
+}
+
+type InvalidTenantError struct {
+	Tenant string
+}
+
+func (err InvalidTenantError) Error() string {
+	return fmt.Sprintf("%q is not a valid tenant", err.Tenant)
+}
+
+type TenantForbiddenError struct {
+	Tenant string
+}
+
+func (err TenantForbiddenError) Error() string {
+	return fmt.Sprintf("API key may not access tenant %q", err.Tenant)
+}
+
+type InvalidRateLimitError struct {
+	Limit string
+}
+
+func (err InvalidRateLimitError) Error() string {
+	return fmt.Sprintf("%q is not a valid rate limit, expected e.g. \"100/s\"", err.Limit)
+}
+
+// UnsupportedError describes an operation which the configured store is not
+// able to perform.
+type UnsupportedError struct {
+	Operation string
+}
+
+func (err UnsupportedError) Error() string {
+	return fmt.Sprintf("%s is not supported by the event store", err.Operation)
+}
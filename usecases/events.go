@@ -2,54 +2,159 @@
 package usecases
 
 import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
 	"github.com/declantraynor/go-events-service/domain"
 )
 
 type EventInteractor struct {
 	Store domain.EventStore
+
+	// Now returns the time relative time expressions are resolved against,
+	// defaulting to time.Now.
+	Now func() time.Time
 }
 
-func (interactor *EventInteractor) AddEvent(name string, timestamp string) error {
+// now returns the current UTC time according to interactor.Now.
+func (interactor *EventInteractor) now() time.Time {
+	if interactor.Now != nil {
+		return interactor.Now().UTC()
+	}
+	return time.Now().UTC()
+}
 
-	parsedTimestamp, err := ParseTimestamp(timestamp)
+// EventOptions describe the optional properties of a new event: the Actor
+// which triggered it, if known, and the Value it measures, if any.
+type EventOptions struct {
+	Actor string
+	Value *float64
+}
+
+// AddEvent stores an event with the given name and ISO8601 timestamp on
+// behalf of `tenant`, returning any error encountered.
+func (interactor *EventInteractor) AddEvent(tenant, name, timestamp string, options EventOptions) error {
+	event, err := newEvent(name, timestamp, options)
 	if err != nil {
 		return err
 	}
 
-	event := domain.Event{Name: name, Timestamp: parsedTimestamp.Unix()}
-	if err := interactor.Store.Put(event); err != nil {
+	if err := interactor.Store.ForTenant(tenant).Put(event); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (interactor *EventInteractor) CountEventsInTimeRange(from, to string) (map[string]int, error) {
-	parsedFrom, fromerr := ParseTimestamp(from)
-	if fromerr != nil {
-		return map[string]int{}, fromerr
+// MaxBatchEvents bounds the number of events AddEvents stores at once.
+const MaxBatchEvents = 1000
+
+// NewEvent describes an event to be stored by AddEvents.
+type NewEvent struct {
+	Name      string
+	Timestamp string
+	EventOptions
+}
+
+// AddEvents stores a batch of events on behalf of `tenant`, returning any
+// error encountered. Every event is validated before any is stored, so an
+// invalid event rejects the whole batch, with an error whose field is
+// indexed, as in "events[2].timestamp". If the store fails, the
+// events before the one it failed on have been stored.
+func (interactor *EventInteractor) AddEvents(tenant string, events []NewEvent) error {
+	if len(events) == 0 {
+		return domain.ValidationError{Field: "events", Reason: "is required"}
+	}
+	if len(events) > MaxBatchEvents {
+		return domain.ValidationError{Field: "events", Reason: fmt.Sprintf("must have at most %d events", MaxBatchEvents)}
 	}
 
-	parsedTo, toerr := ParseTimestamp(to)
-	if toerr != nil {
-		return map[string]int{}, toerr
+	batch := make([]domain.Event, len(events))
+	for i, e := range events {
+		event, err := newEvent(e.Name, e.Timestamp, e.EventOptions)
+		switch e := err.(type) {
+		case nil:
+		case domain.ValidationError:
+			e.Field = fmt.Sprintf("events[%d].%s", i, e.Field)
+			return e
+		case InvalidTimestampError:
+			e.Field = fmt.Sprintf("events[%d].%s", i, e.Field)
+			return e
+		default:
+			return err
+		}
+		batch[i] = event
 	}
 
-	if !parsedFrom.Before(parsedTo) {
-		return map[string]int{}, InvalidTimeRangeError{From: from, To: to}
+	store := interactor.Store.ForTenant(tenant)
+	for _, event := range batch {
+		if err := store.Put(event); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	eventNames, err := interactor.Store.Names()
+// newEvent validates the properties of a new event, returning the event they
+// describe.
+func newEvent(name, timestamp string, options EventOptions) (domain.Event, error) {
+	if strings.TrimSpace(name) == "" {
+		return domain.Event{}, domain.ValidationError{Field: "name", Reason: "is required"}
+	}
+
+	parsedTimestamp, err := parseTimestampField("timestamp", timestamp)
+	if err != nil {
+		return domain.Event{}, err
+	}
+
+	if options.Value != nil && (math.IsNaN(*options.Value) || math.IsInf(*options.Value, 0)) {
+		return domain.Event{}, domain.ValidationError{Field: "value", Reason: "must be a finite number"}
+	}
+
+	return domain.Event{
+		Name:      name,
+		Timestamp: parsedTimestamp.Unix(),
+		Actor:     options.Actor,
+		Value:     options.Value,
+	}, nil
+}
+
+// CountEventsInTimeRange returns the number of events of each name stored by
+// `tenant` with a timestamp in `timeRange`, as well as any error encountered.
+// Stores which implement domain.EventCounter count every name at once;
+// others are asked for each name in turn.
+func (interactor *EventInteractor) CountEventsInTimeRange(tenant string, timeRange TimeRange) (map[string]int, error) {
+	start, end, err := timeRange.parse(interactor.now())
 	if err != nil {
 		return map[string]int{}, err
 	}
 
+	counts, err := countAll(interactor.Store.ForTenant(tenant), start, end)
+	if err != nil {
+		return map[string]int{}, err
+	}
+	return counts, nil
+}
+
+// countAll returns the number of events of each name in `store` with a
+// timestamp between `start` and `end`, omitting names with none.
+func countAll(store domain.EventStore, start, end int64) (map[string]int, error) {
+	if counter, ok := store.(domain.EventCounter); ok {
+		return counter.CountAllInTimeRange(start, end)
+	}
+
+	eventNames, err := store.Names()
+	if err != nil {
+		return nil, err
+	}
+
 	counts := map[string]int{}
 	for _, name := range eventNames {
-		count, err := interactor.Store.CountInTimeRange(name, parsedFrom.Unix(), parsedTo.Unix())
-
+		count, err := store.CountInTimeRange(name, start, end)
 		if err != nil {
-			return map[string]int{}, err
+			return nil, err
 		}
 
 		// returned counts will only include events which occur in the time range
@@ -3,21 +3,25 @@ package usecases
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
 )
 
 func TestAddEvent(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
 
-	if err := interactor.AddEvent("test-event", "2015-02-11T15:01:00+00:00"); err != nil {
+	if err := interactor.AddEvent("test-tenant", "test-event", "2015-02-11T15:01:00+00:00", EventOptions{}); err != nil {
 		t.Error("EventInteractor.AddEvent returned an unexpected error")
 	}
 }
 
 func TestAddEventNonISOTimestamp(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	err := interactor.AddEvent("test-event", "2015/02/01 15:01")
+	err := interactor.AddEvent("test-tenant", "test-event", "2015/02/01 15:01", EventOptions{})
 
-	if err, ok := err.(InvalidTimestampError); !ok || err.NotISO8601 == false {
+	if err, ok := err.(InvalidTimestampError); !ok || err.NotISO8601 == false || err.Field != "timestamp" {
 		t.Errorf("expected InvalidTimestampError, got %T", err)
 	}
 
@@ -29,7 +33,7 @@ func TestAddEventNonISOTimestamp(t *testing.T) {
 
 func TestAddEventNonUTCTimestamp(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	err := interactor.AddEvent("test-event", "2015-02-11T15:01:00-05:00")
+	err := interactor.AddEvent("test-tenant", "test-event", "2015-02-11T15:01:00-05:00", EventOptions{})
 
 	if err, ok := err.(InvalidTimestampError); !ok || err.NotUTC == false {
 		t.Errorf("expected InvalidTimestampError, got %T", err)
@@ -41,17 +45,89 @@ func TestAddEventNonUTCTimestamp(t *testing.T) {
 	}
 }
 
+func TestAddEventMissingName(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+
+	for _, name := range []string{"", "   "} {
+		err := interactor.AddEvent("test-tenant", name, "2015-02-11T15:01:00+00:00", EventOptions{})
+		if err, ok := err.(domain.ValidationError); !ok || err.Field != "name" {
+			t.Errorf("expected ValidationError for name, got %v", err)
+		}
+	}
+}
+
 func TestAddEventStorageError(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStoreWithPutError)}
 
-	if err := interactor.AddEvent("test-event", "2015-02-11T15:01:00+00:00"); err == nil {
+	if err := interactor.AddEvent("test-tenant", "test-event", "2015-02-11T15:01:00+00:00", EventOptions{}); err == nil {
 		t.Error("expected error from Store.Put")
 	}
 }
 
+func TestAddEvents(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	events := []NewEvent{
+		{Name: "foo", Timestamp: "2015-02-11T15:01:00Z"},
+		{Name: "foo", Timestamp: "2015-02-11T15:01:30Z"},
+		{Name: "bar", Timestamp: "2015-02-11T15:02:00Z"},
+	}
+
+	if err := interactor.AddEvents("acme", events); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	counts, _ := interactor.CountEventsInTimeRange("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"})
+	if counts["foo"] != 2 || counts["bar"] != 1 {
+		t.Errorf("expected every event to be stored, got %v", counts)
+	}
+}
+
+func TestAddEventsValidatesEveryEventFirst(t *testing.T) {
+	store := storetest.NewEventStore()
+	interactor := EventInteractor{Store: store}
+
+	cases := map[string][]NewEvent{
+		"events[1].name":      {{Name: "foo", Timestamp: "2015-02-11T15:01:00Z"}, {Name: " ", Timestamp: "2015-02-11T15:01:00Z"}},
+		"events[2].timestamp": {{Name: "foo", Timestamp: "2015-02-11T15:01:00Z"}, {Name: "foo", Timestamp: "2015-02-11T15:01:00Z"}, {Name: "foo", Timestamp: "yesterday"}},
+		"events":              {},
+	}
+
+	for field, events := range cases {
+		err := interactor.AddEvents("acme", events)
+		switch e := err.(type) {
+		case domain.ValidationError:
+			if e.Field != field {
+				t.Errorf("expected an error on %s, got %v", field, err)
+			}
+		case InvalidTimestampError:
+			if e.Field != field {
+				t.Errorf("expected an error on %s, got %v", field, err)
+			}
+		default:
+			t.Errorf("expected an error on %s, got %v", field, err)
+		}
+	}
+
+	if names, _ := store.ForTenant("acme").Names(); len(names) != 0 {
+		t.Errorf("expected no events to be stored, got %v", names)
+	}
+}
+
+func TestAddEventsLimitsBatchSize(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	events := make([]NewEvent, MaxBatchEvents+1)
+	for i := range events {
+		events[i] = NewEvent{Name: "foo", Timestamp: "2015-02-11T15:01:00Z"}
+	}
+
+	if err, ok := interactor.AddEvents("acme", events).(domain.ValidationError); !ok || err.Field != "events" {
+		t.Errorf("expected ValidationError for events, got %v", err)
+	}
+}
+
 func TestCountEventsInTimeRange(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	counts, err := interactor.CountEventsInTimeRange("2015-01-01T13:23:00+00:00", "2015-01-01T13:23:59+00:00")
+	counts, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015-01-01T13:24:00+00:00"})
 
 	if err != nil {
 		t.Error("EventInteractor.CountEventsInTimeRange returned unexpected error")
@@ -67,27 +143,114 @@ func TestCountEventsInTimeRange(t *testing.T) {
 	}
 }
 
+func TestCountEventsInTimeRangeCountsAllNamesAtOnce(t *testing.T) {
+	store := new(StubCountingEventStore)
+	interactor := EventInteractor{Store: store}
+	counts, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015-01-01T13:24:00+00:00"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]int{"foo": 18, "bar": 6}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+	if store.start != 1420118580 || store.end != 1420118639 {
+		t.Errorf("expected range [1420118580, 1420118639], got [%d, %d]", store.start, store.end)
+	}
+}
+
+func TestTimeRangeBounds(t *testing.T) {
+	cases := []struct {
+		timeRange  TimeRange
+		start, end int64
+	}{
+		{TimeRange{From: "2015-01-01T13:23:00Z", To: "2015-01-01T13:24:00Z"}, 1420118580, 1420118639},
+		{TimeRange{From: "2015-01-01T13:23:00Z", To: "2015-01-01T13:24:00Z", Closed: true}, 1420118580, 1420118640},
+		{TimeRange{From: "2015-01-01T13:23:00.5Z", To: "2015-01-01T13:24:00.5Z"}, 1420118581, 1420118640},
+		{TimeRange{From: "2015-01-01T13:23:00.5Z", To: "2015-01-01T13:24:00.5Z", Closed: true}, 1420118581, 1420118640},
+	}
+
+	for _, c := range cases {
+		start, end, err := c.timeRange.parse(time.Time{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if start != c.start || end != c.end {
+			t.Errorf("expected %+v to span [%d, %d], got [%d, %d]", c.timeRange, c.start, c.end, start, end)
+		}
+	}
+}
+
+func TestTimeRangeWithinASecondIsEmpty(t *testing.T) {
+	cases := []TimeRange{
+		{From: "2015-01-01T13:23:00.2Z", To: "2015-01-01T13:23:00.7Z"},
+		{From: "2015-01-01T13:23:00.2Z", To: "2015-01-01T13:23:01Z"},
+		{From: "2015-01-01T13:23:00.2Z", To: "2015-01-01T13:23:00.7Z", Closed: true},
+	}
+
+	for _, timeRange := range cases {
+		_, _, err := timeRange.parse(time.Time{})
+		if _, ok := err.(EmptyTimeRangeError); !ok {
+			t.Errorf("expected EmptyTimeRangeError for %+v, got %v", timeRange, err)
+		}
+	}
+}
+
+func TestCountEventsInRelativeTimeRange(t *testing.T) {
+	now := time.Date(2015, 2, 11, 15, 30, 0, 0, time.UTC)
+	interactor := EventInteractor{Store: storetest.NewEventStore(), Now: func() time.Time { return now }}
+	interactor.AddEvent("acme", "test", "2015-02-11T14:45:00Z", EventOptions{})
+	interactor.AddEvent("acme", "test", "2015-02-11T01:00:00Z", EventOptions{})
+
+	counts, err := interactor.CountEventsInTimeRange("acme", TimeRange{From: "now-1h", To: "now"})
+	if err != nil || counts["test"] != 1 {
+		t.Errorf("expected 1 event in the last hour, got %v (%v)", counts, err)
+	}
+
+	counts, err = interactor.CountEventsInTimeRange("acme", TimeRange{From: "today", To: "now"})
+	if err != nil || counts["test"] != 2 {
+		t.Errorf("expected 2 events today, got %v (%v)", counts, err)
+	}
+}
+
+func TestConsecutiveRangesCountEachEventOnce(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	interactor.AddEvent("acme", "test", "2015-02-11T15:02:00Z", EventOptions{})
+
+	total := 0
+	for _, timeRange := range []TimeRange{
+		{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"},
+		{From: "2015-02-11T15:02:00Z", To: "2015-02-11T15:03:00Z"},
+	} {
+		counts, _ := interactor.CountEventsInTimeRange("acme", timeRange)
+		total += counts["test"]
+	}
+	if total != 1 {
+		t.Errorf("expected the event to be counted once, got %d", total)
+	}
+}
+
 func TestCountEventsInTimeRangeInvalidFrom(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	_, err := interactor.CountEventsInTimeRange("2015/01/01 13:23:00", "2015-01-01T13:23:59+00:00")
+	_, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015/01/01 13:23:00", To: "2015-01-01T13:24:00+00:00"})
 
-	if _, ok := err.(InvalidTimestampError); !ok {
+	if err, ok := err.(InvalidTimestampError); !ok || err.Field != "from" {
 		t.Errorf("expected InvalidTimestampError, got %T", err)
 	}
 }
 
 func TestCountEventsInTimeRangeInvalidTo(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	_, err := interactor.CountEventsInTimeRange("2015-01-01T13:23:00+00:00", "2015/01/01 13:23:59")
+	_, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015/01/01 13:23:59"})
 
-	if _, ok := err.(InvalidTimestampError); !ok {
+	if err, ok := err.(InvalidTimestampError); !ok || err.Field != "to" {
 		t.Errorf("expected InvalidTimestampError, got %T", err)
 	}
 }
 
 func TestCountEventsInTimeRangeInvalidRange(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStore)}
-	_, err := interactor.CountEventsInTimeRange("2015-01-01T13:29:00+00:00", "2015-01-01T13:20:00+00:00")
+	_, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:29:00+00:00", To: "2015-01-01T13:20:00+00:00"})
 
 	if _, ok := err.(InvalidTimeRangeError); !ok {
 		t.Errorf("expected InvalidTimeRangeError, got %T", err)
@@ -101,7 +264,7 @@ func TestCountEventsInTimeRangeInvalidRange(t *testing.T) {
 
 func TestCountEventsInTimeRangeEventStoreNamesError(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStoreWithNamesError)}
-	_, err := interactor.CountEventsInTimeRange("2015-01-01T13:23:00+00:00", "2015-01-01T13:23:59+00:00")
+	_, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015-01-01T13:24:00+00:00"})
 
 	if err == nil {
 		t.Error("expected error from Store.Names")
@@ -110,9 +273,37 @@ func TestCountEventsInTimeRangeEventStoreNamesError(t *testing.T) {
 
 func TestCountEventsInTimeRangeEventStoreCountError(t *testing.T) {
 	interactor := EventInteractor{Store: new(StubEventStoreWithCountError)}
-	_, err := interactor.CountEventsInTimeRange("2015-01-01T13:23:00+00:00", "2015-01-01T13:23:59+00:00")
+	_, err := interactor.CountEventsInTimeRange("test-tenant", TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015-01-01T13:24:00+00:00"})
 
 	if err == nil {
 		t.Error("expected error from Store.CountInTimeRange")
 	}
 }
+
+func TestEventsAreIsolatedByTenant(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+
+	interactor.AddEvent("acme", "login", "2015-01-01T13:23:10+00:00", EventOptions{})
+	interactor.AddEvent("acme", "login", "2015-01-01T13:23:20+00:00", EventOptions{})
+	interactor.AddEvent("acme", "logout", "2015-01-01T13:23:30+00:00", EventOptions{})
+	interactor.AddEvent("globex", "login", "2015-01-01T13:23:40+00:00", EventOptions{})
+
+	cases := []struct {
+		tenant   string
+		expected map[string]int
+	}{
+		{"acme", map[string]int{"login": 2, "logout": 1}},
+		{"globex", map[string]int{"login": 1}},
+		{"initech", map[string]int{}},
+	}
+
+	for _, c := range cases {
+		counts, err := interactor.CountEventsInTimeRange(c.tenant, TimeRange{From: "2015-01-01T13:23:00+00:00", To: "2015-01-01T13:24:00+00:00"})
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(counts, c.expected) {
+			t.Errorf("tenant %q: expected %v, got %v", c.tenant, c.expected, counts)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package usecases
+
+import (
+	"sort"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// ExportEvents calls `fn` with each event stored by `tenant` with a
+// timestamp in `timeRange`, stopping at the first error returned by
+// `fn`. If `name` is non-empty only events with that name are exported;
+// otherwise events are exported one name at a time, in order of name, and
+// in timestamp order within each name.
+func (interactor *EventInteractor) ExportEvents(tenant string, timeRange TimeRange, name string, fn func(domain.Event) error) error {
+	start, end, err := timeRange.parse(interactor.now())
+	if err != nil {
+		return err
+	}
+
+	store := interactor.Store.ForTenant(tenant)
+	scanner, ok := store.(domain.EventScanner)
+	if !ok {
+		return UnsupportedError{Operation: "exporting events"}
+	}
+
+	names := []string{name}
+	if name == "" {
+		if names, err = store.Names(); err != nil {
+			return err
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		if err := scanner.ScanInTimeRange(name, start, end, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
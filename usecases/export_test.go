@@ -0,0 +1,94 @@
+package usecases
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+func newExportStore() *storetest.EventStore {
+	store := storetest.NewEventStore()
+	events := []domain.Event{
+		{Name: "foo", Timestamp: 1423666860},
+		{Name: "bar", Timestamp: 1423666861},
+		{Name: "foo", Timestamp: 1423666862},
+		{Name: "foo", Timestamp: 1423666920},
+	}
+	for _, event := range events {
+		store.ForTenant("test-tenant").Put(event)
+	}
+	store.ForTenant("other-tenant").Put(domain.Event{Name: "foo", Timestamp: 1423666860})
+	return store
+}
+
+func export(interactor EventInteractor, name string) ([]domain.Event, error) {
+	var events []domain.Event
+	err := interactor.ExportEvents("test-tenant", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"}, name, func(event domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+func TestExportEvents(t *testing.T) {
+	events, err := export(EventInteractor{Store: newExportStore()}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []domain.Event{
+		{Name: "bar", Timestamp: 1423666861},
+		{Name: "foo", Timestamp: 1423666860},
+		{Name: "foo", Timestamp: 1423666862},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestExportEventsByName(t *testing.T) {
+	events, err := export(EventInteractor{Store: newExportStore()}, "bar")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []domain.Event{{Name: "bar", Timestamp: 1423666861}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestExportEventsInvalidRange(t *testing.T) {
+	interactor := EventInteractor{Store: newExportStore()}
+	err := interactor.ExportEvents("test-tenant", TimeRange{From: "2015-02-11T15:01:59Z", To: "2015-02-11T15:01:00Z"}, "", func(domain.Event) error { return nil })
+
+	if _, ok := err.(InvalidTimeRangeError); !ok {
+		t.Errorf("expected InvalidTimeRangeError, got %#v", err)
+	}
+}
+
+func TestExportEventsStopsAtError(t *testing.T) {
+	interactor := EventInteractor{Store: newExportStore()}
+	stop := errors.New("stop")
+	calls := 0
+	err := interactor.ExportEvents("test-tenant", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"}, "", func(domain.Event) error {
+		calls++
+		return stop
+	})
+
+	if err != stop || calls != 1 {
+		t.Errorf("expected export to stop at first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestExportEventsUnsupportedStore(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+	_, err := export(interactor, "")
+
+	if _, ok := err.(UnsupportedError); !ok {
+		t.Errorf("expected UnsupportedError, got %#v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package usecases
+
+import (
+	"strconv"
+	"time"
+
+	// zone data is embedded so that time zones can be loaded wherever the
+	// service runs, including minimal containers without /usr/share/zoneinfo
+	_ "time/tzdata"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// MaxHistogramBuckets bounds the number of buckets a histogram may have.
+const MaxHistogramBuckets = 1000
+
+// HistogramOptions describe the buckets of a histogram: Interval is their
+// length, such as "15m", "1h", "1d", "1w" or "1M", and TimeZone the IANA
+// time zone, such as "Europe/Dublin", whose clocks and calendar they are
+// aligned to, defaulting to UTC.
+type HistogramOptions struct {
+	Interval string
+	TimeZone string
+}
+
+// Bucket is the number of events in the interval beginning at Start.
+type Bucket struct {
+	Start time.Time
+	Count int
+}
+
+// interval is a parsed HistogramOptions.Interval: `n` minutes or hours, or
+// calendar days, weeks or months.
+type interval struct {
+	n    int
+	unit byte
+}
+
+// parseInterval parses an interval of a whole number of minutes (m), hours
+// (h), days (d), weeks (w) or months (M).
+func parseInterval(value string) (interval, error) {
+	invalid := domain.ValidationError{Field: "interval", Reason: "must be a number of minutes, hours, days, weeks or months, such as 15m, 1h, 1d, 1w or 1M"}
+	if len(value) < 2 {
+		return interval{}, invalid
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 1 || value[0] == '+' {
+		return interval{}, invalid
+	}
+	switch unit := value[len(value)-1]; unit {
+	case 'm', 'h', 'd', 'w', 'M':
+		return interval{n: n, unit: unit}, nil
+	}
+	return interval{}, invalid
+}
+
+// first returns the start of the bucket which `t` falls in.
+func (i interval) first(t time.Time) time.Time {
+	switch i.unit {
+	case 'd', 'w':
+		return roundDown(t, i.unit)
+	case 'M':
+		return startOfDay(t.Year(), t.Month(), 1, t.Location())
+	}
+	return truncateLocal(t, i.duration())
+}
+
+// next returns the start of the bucket after the one beginning at `start`.
+// Calendar buckets begin at local midnight on their first day, or at the
+// first instant of that day if the clocks skip midnight, so the next is
+// found from the date of `start` rather than its time.
+func (i interval) next(start time.Time) time.Time {
+	year, month, day := start.Date()
+	switch i.unit {
+	case 'd':
+		day += i.n
+	case 'w':
+		day += 7 * i.n
+	case 'M':
+		month += time.Month(i.n)
+	default:
+		return start.Add(i.duration())
+	}
+	return startOfDay(year, month, day, start.Location())
+}
+
+func (i interval) duration() time.Duration {
+	if i.unit == 'h' {
+		return time.Duration(i.n) * time.Hour
+	}
+	return time.Duration(i.n) * time.Minute
+}
+
+// Histogram returns the number of events named `name` stored by `tenant` in
+// each bucket of `timeRange`. Buckets are aligned to the clocks and calendar
+// of options.TimeZone, so daily buckets begin at local midnight and are 23
+// or 25 hours long when the clocks change, and relative times in the range,
+// such as `today`, are resolved in it too. The first and last buckets may
+// begin before or end after the range, but only events within the range are
+// counted in them.
+func (interactor *EventInteractor) Histogram(tenant, name string, timeRange TimeRange, options HistogramOptions) ([]Bucket, error) {
+	interval, err := parseInterval(options.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// the service's own zone is not a meaningful reporting zone
+	location, err := time.LoadLocation(options.TimeZone)
+	if err != nil || options.TimeZone == "Local" {
+		return nil, domain.ValidationError{Field: "tz", Reason: "must be an IANA time zone, such as Europe/Dublin"}
+	}
+
+	start, end, err := timeRange.parse(interactor.now().In(location))
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []Bucket
+	for bucketStart := interval.first(time.Unix(start, 0).In(location)); bucketStart.Unix() <= end; {
+		if len(buckets) == MaxHistogramBuckets {
+			return nil, domain.ValidationError{Field: "interval", Reason: "must give at most 1000 buckets in the time range"}
+		}
+		next := interval.next(bucketStart)
+		buckets = append(buckets, Bucket{Start: bucketStart})
+		bucketStart = next
+	}
+
+	ranges := make([][2]int64, len(buckets))
+	for i := range buckets {
+		ranges[i] = [2]int64{buckets[i].Start.Unix(), end}
+		if i+1 < len(buckets) {
+			ranges[i][1] = buckets[i+1].Start.Unix() - 1
+		}
+		if ranges[i][0] < start {
+			ranges[i][0] = start
+		}
+	}
+
+	counts, err := countRanges(interactor.Store.ForTenant(tenant), name, ranges)
+	if err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		buckets[i].Count = counts[i]
+	}
+	return buckets, nil
+}
+
+// countRanges counts the events named `name` in each of `ranges`, at once if
+// the store is a domain.BatchCounter, or else one range at a time.
+func countRanges(store domain.EventStore, name string, ranges [][2]int64) ([]int, error) {
+	if counter, ok := store.(domain.BatchCounter); ok {
+		return counter.CountInTimeRanges(name, ranges)
+	}
+
+	counts := make([]int, len(ranges))
+	for i, r := range ranges {
+		count, err := store.CountInTimeRange(name, r[0], r[1])
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = count
+	}
+	return counts, nil
+}
@@ -0,0 +1,237 @@
+package usecases
+
+import (
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+// histogram returns the buckets of a histogram of the events named "test"
+// at `timestamps`, failing the test on any error.
+func histogram(t *testing.T, timestamps []string, timeRange TimeRange, options HistogramOptions) []Bucket {
+	t.Helper()
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	for _, timestamp := range timestamps {
+		interactor.AddEvent("acme", "test", timestamp, EventOptions{})
+	}
+	buckets, err := interactor.Histogram("acme", "test", timeRange, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return buckets
+}
+
+// assertBuckets checks the start, as an RFC3339 local time, and count of
+// each bucket.
+func assertBuckets(t *testing.T, buckets []Bucket, starts []string, counts []int) {
+	t.Helper()
+	if len(buckets) != len(starts) {
+		t.Fatalf("expected %d buckets, got %d: %v", len(starts), len(buckets), buckets)
+	}
+	for i, bucket := range buckets {
+		if start := bucket.Start.Format(time.RFC3339); start != starts[i] {
+			t.Errorf("bucket %d: expected start %s, got %s", i, starts[i], start)
+		}
+		if bucket.Count != counts[i] {
+			t.Errorf("bucket %d: expected count %d, got %d", i, counts[i], bucket.Count)
+		}
+	}
+}
+
+func TestHistogramUTC(t *testing.T) {
+	buckets := histogram(t,
+		[]string{"2015-02-11T15:00:00Z", "2015-02-11T15:59:59Z", "2015-02-11T16:00:00Z", "2015-02-11T18:00:00Z"},
+		TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T18:00:00Z"},
+		HistogramOptions{Interval: "1h"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-02-11T15:00:00Z", "2015-02-11T16:00:00Z", "2015-02-11T17:00:00Z"},
+		[]int{2, 1, 0})
+}
+
+func TestHistogramClipsBucketsToTheRange(t *testing.T) {
+	buckets := histogram(t,
+		[]string{"2015-02-11T15:05:00Z", "2015-02-11T15:20:00Z", "2015-02-11T15:40:00Z", "2015-02-11T15:50:00Z"},
+		TimeRange{From: "2015-02-11T15:10:00Z", To: "2015-02-11T15:45:00Z"},
+		HistogramOptions{Interval: "30m"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-02-11T15:00:00Z", "2015-02-11T15:30:00Z"},
+		[]int{1, 1})
+}
+
+func TestHistogramDaysAreAlignedToLocalMidnight(t *testing.T) {
+	// Dublin is an hour ahead of UTC in summer
+	buckets := histogram(t,
+		[]string{"2015-07-01T22:59:59Z", "2015-07-01T23:00:00Z"},
+		TimeRange{From: "2015-06-30T23:00:00Z", To: "2015-07-02T23:00:00Z"},
+		HistogramOptions{Interval: "1d", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-07-01T00:00:00+01:00", "2015-07-02T00:00:00+01:00"},
+		[]int{1, 1})
+}
+
+func TestHistogramDayWhenClocksGoForward(t *testing.T) {
+	// Dublin's clocks went forward from 01:00 to 02:00 on 29 March 2015
+	buckets := histogram(t,
+		[]string{"2015-03-29T00:59:59Z", "2015-03-29T01:00:00Z", "2015-03-29T22:59:59Z", "2015-03-29T23:00:00Z"},
+		TimeRange{From: "2015-03-28T00:00:00Z", To: "2015-03-30T23:00:00Z"},
+		HistogramOptions{Interval: "1d", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-03-28T00:00:00Z", "2015-03-29T00:00:00Z", "2015-03-30T00:00:00+01:00"},
+		[]int{0, 3, 1})
+	if length := buckets[2].Start.Sub(buckets[1].Start); length != 23*time.Hour {
+		t.Errorf("expected a 23 hour day, got %v", length)
+	}
+}
+
+func TestHistogramDayWhenClocksGoBack(t *testing.T) {
+	// Dublin's clocks went back from 02:00 to 01:00 on 25 October 2015
+	buckets := histogram(t,
+		[]string{"2015-10-24T23:00:00Z", "2015-10-25T00:30:00Z", "2015-10-25T01:30:00Z", "2015-10-25T23:59:59Z"},
+		TimeRange{From: "2015-10-24T23:00:00Z", To: "2015-10-26T00:00:00Z"},
+		HistogramOptions{Interval: "1d", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-10-25T00:00:00+01:00"},
+		[]int{4})
+	if length := buckets[0].Start.AddDate(0, 0, 1).Sub(buckets[0].Start); length != 25*time.Hour {
+		t.Errorf("expected a 25 hour day, got %v", length)
+	}
+}
+
+func TestHistogramHoursWhenClocksGoBack(t *testing.T) {
+	// 01:00 to 02:00 happened twice in Dublin on 25 October 2015
+	buckets := histogram(t,
+		[]string{"2015-10-25T00:30:00Z", "2015-10-25T01:30:00Z", "2015-10-25T01:45:00Z"},
+		TimeRange{From: "2015-10-24T23:00:00Z", To: "2015-10-25T03:00:00Z"},
+		HistogramOptions{Interval: "1h", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{
+			"2015-10-25T00:00:00+01:00",
+			"2015-10-25T01:00:00+01:00",
+			"2015-10-25T01:00:00Z",
+			"2015-10-25T02:00:00Z",
+		},
+		[]int{0, 1, 2, 0})
+}
+
+func TestHistogramHoursWhenClocksGoForward(t *testing.T) {
+	// 01:00 to 02:00 never happened in Dublin on 29 March 2015
+	buckets := histogram(t,
+		[]string{"2015-03-29T00:30:00Z", "2015-03-29T01:30:00Z"},
+		TimeRange{From: "2015-03-29T00:00:00Z", To: "2015-03-29T02:00:00Z"},
+		HistogramOptions{Interval: "1h", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-03-29T00:00:00Z", "2015-03-29T02:00:00+01:00"},
+		[]int{1, 1})
+}
+
+func TestHistogramDayWhenClocksSkipMidnight(t *testing.T) {
+	// São Paulo's clocks went forward from 00:00 to 01:00 on 18 October 2015,
+	// so that day began at 01:00
+	buckets := histogram(t,
+		[]string{"2015-10-18T02:59:59Z", "2015-10-18T03:00:00Z", "2015-10-19T01:59:59Z", "2015-10-19T02:00:00Z"},
+		TimeRange{From: "2015-10-17T03:00:00Z", To: "2015-10-20T02:00:00Z"},
+		HistogramOptions{Interval: "1d", TimeZone: "America/Sao_Paulo"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-10-17T00:00:00-03:00", "2015-10-18T01:00:00-02:00", "2015-10-19T00:00:00-02:00"},
+		[]int{1, 2, 1})
+}
+
+func TestHistogramWeeksBeginOnMonday(t *testing.T) {
+	buckets := histogram(t,
+		[]string{"2015-03-29T22:59:59Z", "2015-03-29T23:00:00Z"},
+		TimeRange{From: "2015-03-25T00:00:00Z", To: "2015-04-05T23:00:00Z"},
+		HistogramOptions{Interval: "1w", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-03-23T00:00:00Z", "2015-03-30T00:00:00+01:00"},
+		[]int{1, 1})
+}
+
+func TestHistogramMonths(t *testing.T) {
+	buckets := histogram(t,
+		[]string{"2015-01-31T23:59:59Z", "2015-03-31T22:59:59Z", "2015-03-31T23:00:00Z", "2015-04-30T23:00:00Z"},
+		TimeRange{From: "2015-01-01T00:00:00Z", To: "2015-05-31T23:00:00Z"},
+		HistogramOptions{Interval: "1M", TimeZone: "Europe/Dublin"})
+
+	assertBuckets(t, buckets,
+		[]string{
+			"2015-01-01T00:00:00Z",
+			"2015-02-01T00:00:00Z",
+			"2015-03-01T00:00:00Z",
+			"2015-04-01T00:00:00+01:00",
+			"2015-05-01T00:00:00+01:00",
+		},
+		[]int{1, 0, 1, 1, 1})
+}
+
+func TestHistogramHalfHourZone(t *testing.T) {
+	// India is five and a half hours ahead of UTC
+	buckets := histogram(t,
+		[]string{"2015-02-11T00:29:59Z", "2015-02-11T00:30:00Z"},
+		TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-11T01:00:00Z"},
+		HistogramOptions{Interval: "1h", TimeZone: "Asia/Kolkata"})
+
+	assertBuckets(t, buckets,
+		[]string{"2015-02-11T05:00:00+05:30", "2015-02-11T06:00:00+05:30"},
+		[]int{1, 1})
+}
+
+func TestHistogramResolvesRelativeTimesInTheTimeZone(t *testing.T) {
+	now := time.Date(2015, 7, 1, 12, 0, 0, 0, time.UTC)
+	interactor := EventInteractor{Store: storetest.NewEventStore(), Now: func() time.Time { return now }}
+	interactor.AddEvent("acme", "test", "2015-06-30T23:30:00Z", EventOptions{})
+
+	buckets, err := interactor.Histogram("acme", "test", TimeRange{From: "today", To: "now"}, HistogramOptions{Interval: "1d", TimeZone: "Europe/Dublin"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertBuckets(t, buckets, []string{"2015-07-01T00:00:00+01:00"}, []int{1})
+}
+
+func TestHistogramCountsEachBucketWithoutBatchCounter(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.BasicEventStore{EventStore: storetest.NewEventStore()}}
+	interactor.AddEvent("acme", "test", "2015-02-11T15:01:00Z", EventOptions{})
+	interactor.AddEvent("acme", "test", "2015-02-11T16:30:00Z", EventOptions{})
+
+	timeRange := TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T18:00:00Z"}
+	buckets, err := interactor.Histogram("acme", "test", timeRange, HistogramOptions{Interval: "1h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertBuckets(t, buckets,
+		[]string{"2015-02-11T15:00:00Z", "2015-02-11T16:00:00Z", "2015-02-11T17:00:00Z"},
+		[]int{1, 1, 0})
+}
+
+func TestHistogramInvalidOptions(t *testing.T) {
+	cases := []struct {
+		timeRange TimeRange
+		options   HistogramOptions
+		field     string
+	}{
+		{TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-12T00:00:00Z"}, HistogramOptions{Interval: "1y"}, "interval"},
+		{TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-12T00:00:00Z"}, HistogramOptions{Interval: "0d"}, "interval"},
+		{TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-12T00:00:00Z"}, HistogramOptions{Interval: "h"}, "interval"},
+		{TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-12T00:00:00Z"}, HistogramOptions{Interval: "1d", TimeZone: "Europe/Atlantis"}, "tz"},
+		{TimeRange{From: "2015-02-11T00:00:00Z", To: "2015-02-12T00:00:00Z"}, HistogramOptions{Interval: "1d", TimeZone: "Local"}, "tz"},
+		{TimeRange{From: "2015-01-01T00:00:00Z", To: "2016-01-01T00:00:00Z"}, HistogramOptions{Interval: "1m"}, "interval"},
+	}
+
+	for _, c := range cases {
+		interactor := EventInteractor{Store: storetest.NewEventStore()}
+		_, err := interactor.Histogram("acme", "test", c.timeRange, c.options)
+		if err, ok := err.(domain.ValidationError); !ok || err.Field != c.field {
+			t.Errorf("%+v: expected a ValidationError on %s, got %v", c.options, c.field, err)
+		}
+	}
+}
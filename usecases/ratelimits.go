@@ -0,0 +1,41 @@
+package usecases
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+var rateLimitPeriods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRateLimit parses a limit of the form "<requests>/<period>", where the
+// period is one of "s", "m" or "h". A limit of "600/m" permits bursts of up
+// to 600 requests, refilled at 10 requests per second. An empty string
+// parses to the zero RateLimit.
+func ParseRateLimit(value string) (domain.RateLimit, error) {
+	if value == "" {
+		return domain.RateLimit{}, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return domain.RateLimit{}, InvalidRateLimitError{Limit: value}
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	period, ok := rateLimitPeriods[parts[1]]
+	if err != nil || !ok || requests <= 0 {
+		return domain.RateLimit{}, InvalidRateLimitError{Limit: value}
+	}
+
+	return domain.RateLimit{
+		Rate:  float64(requests) / period.Seconds(),
+		Burst: requests,
+	}, nil
+}
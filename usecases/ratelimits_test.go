@@ -0,0 +1,36 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected domain.RateLimit
+	}{
+		{"", domain.RateLimit{}},
+		{"10/s", domain.RateLimit{Rate: 10, Burst: 10}},
+		{"600/m", domain.RateLimit{Rate: 10, Burst: 600}},
+		{"3600/h", domain.RateLimit{Rate: 1, Burst: 3600}},
+	}
+
+	for _, c := range cases {
+		limit, err := ParseRateLimit(c.value)
+		if err != nil || limit != c.expected {
+			t.Errorf("%q: expected %v, got %v (%v)", c.value, c.expected, limit, err)
+		}
+	}
+}
+
+func TestParseRateLimitInvalid(t *testing.T) {
+	for _, value := range []string{"10", "10/d", "ten/s", "0/s", "-1/s", "/s"} {
+		if _, err := ParseRateLimit(value); err == nil {
+			t.Errorf("expected error parsing %q", value)
+		} else if _, ok := err.(InvalidRateLimitError); !ok {
+			t.Errorf("expected InvalidRateLimitError, got %T", err)
+		}
+	}
+}
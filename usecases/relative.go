@@ -0,0 +1,135 @@
+package usecases
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// clockUnits are the units of relative time expressions of a fixed length.
+// The others, d and w, are calendar days and weeks in the zone of the time
+// they apply to, so across a change of the clocks they are an hour shorter
+// or longer.
+var clockUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+}
+
+// isRelativeUnit reports whether `unit` is a unit of relative time
+// expressions.
+func isRelativeUnit(unit byte) bool {
+	return unit == 'd' || unit == 'w' || clockUnits[unit] != 0
+}
+
+// isRelativeTime reports whether `expression` is a relative time expression
+// rather than an absolute timestamp.
+func isRelativeTime(expression string) bool {
+	return strings.HasPrefix(expression, "now") || strings.HasPrefix(expression, "today")
+}
+
+// parseRelativeTime resolves a relative time expression against `now`. An
+// expression starts with `now`, or `today` for the start of the current day,
+// followed by any number of offsets such as `-1h` or `+30m`, and optionally a
+// unit to round down to, such as `/d`: `now-7d/d` is the start of the day a
+// week ago. Units are s, m, h, d and w, and weeks start on Monday. Days and
+// weeks are those of the zone of `now`. Any error is a
+// domain.ValidationError naming `field`.
+func parseRelativeTime(field, expression string, now time.Time) (time.Time, error) {
+	invalid := func(reason string) (time.Time, error) {
+		return time.Time{}, domain.ValidationError{Field: field, Reason: reason}
+	}
+
+	t, rest := now.Truncate(time.Second), ""
+	switch {
+	case strings.HasPrefix(expression, "today"):
+		t, rest = roundDown(t, 'd'), expression[len("today"):]
+	case strings.HasPrefix(expression, "now"):
+		rest = expression[len("now"):]
+	default:
+		return invalid("must start with now or today")
+	}
+
+	for len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		end := 1
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(rest[1:end])
+		if err != nil || end == len(rest) {
+			return invalid(fmt.Sprintf("has an invalid offset %q", rest))
+		}
+		if !isRelativeUnit(rest[end]) {
+			return invalid(fmt.Sprintf("has an unknown unit %q", rest[end]))
+		}
+		if rest[0] == '-' {
+			n = -n
+		}
+		t, rest = addUnits(t, n, rest[end]), rest[end+1:]
+	}
+
+	if strings.HasPrefix(rest, "/") && len(rest) == 2 {
+		if !isRelativeUnit(rest[1]) {
+			return invalid(fmt.Sprintf("has an unknown unit %q", rest[1]))
+		}
+		t, rest = roundDown(t, rest[1]), ""
+	}
+
+	if rest != "" {
+		return invalid(fmt.Sprintf("has unexpected %q", rest))
+	}
+	return t, nil
+}
+
+// addUnits adds `n` seconds, minutes, hours, days or weeks, as given by
+// `unit`, to `t`.
+func addUnits(t time.Time, n int, unit byte) time.Time {
+	switch unit {
+	case 'd':
+		return t.AddDate(0, 0, n)
+	case 'w':
+		return t.AddDate(0, 0, 7*n)
+	}
+	return t.Add(time.Duration(n) * clockUnits[unit])
+}
+
+// roundDown returns the start of the second, minute, hour, day or week, as
+// given by `unit`, which `t` falls in, in the zone of `t`.
+func roundDown(t time.Time, unit byte) time.Time {
+	switch unit {
+	case 'd':
+		return startOfDay(t.Year(), t.Month(), t.Day(), t.Location())
+	case 'w':
+		return startOfDay(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, t.Location())
+	}
+
+	return truncateLocal(t, clockUnits[unit])
+}
+
+// truncateLocal returns the start of the interval of length `d` which `t`
+// falls in, counting from midnight in the zone of `t`. It truncates the
+// local time rather than calling time.Date, which is ambiguous in the hour
+// repeated when the clocks go back.
+func truncateLocal(t time.Time, d time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(d).Add(-shift)
+}
+
+// startOfDay returns the first instant of a day in `location`: midnight,
+// unless the clocks went forward at midnight, when the day began as they
+// did. time.Date gives a time the day before for a midnight which never
+// happened, offset as it was then, and the day began at midnight by that
+// offset.
+func startOfDay(year int, month time.Month, day int, location *time.Location) time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, location)
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != midnight.Day() {
+		_, offset := t.Zone()
+		t = midnight.Add(-time.Duration(offset) * time.Second).In(location)
+	}
+	return t
+}
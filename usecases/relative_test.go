@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestParseRelativeTime(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2015, 2, 11, 15, 31, 42, 500000000, time.UTC)
+
+	cases := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"now", time.Date(2015, 2, 11, 15, 31, 42, 0, time.UTC)},
+		{"now-1h", time.Date(2015, 2, 11, 14, 31, 42, 0, time.UTC)},
+		{"now+30m", time.Date(2015, 2, 11, 16, 1, 42, 0, time.UTC)},
+		{"now-1d-12h", time.Date(2015, 2, 10, 3, 31, 42, 0, time.UTC)},
+		{"now/m", time.Date(2015, 2, 11, 15, 31, 0, 0, time.UTC)},
+		{"now-7d/d", time.Date(2015, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"now/w", time.Date(2015, 2, 9, 0, 0, 0, 0, time.UTC)},
+		{"today", time.Date(2015, 2, 11, 0, 0, 0, 0, time.UTC)},
+		{"today-1d", time.Date(2015, 2, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		parsed, err := parseRelativeTime("from", c.expression, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.expression, err)
+		} else if !parsed.Equal(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.expression, c.expected, parsed)
+		}
+	}
+}
+
+func TestParseRelativeTimeRoundsSundayToMonday(t *testing.T) {
+	now := time.Date(2015, 2, 15, 12, 0, 0, 0, time.UTC)
+	parsed, _ := parseRelativeTime("from", "now/w", now)
+	if expected := time.Date(2015, 2, 9, 0, 0, 0, 0, time.UTC); !parsed.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, parsed)
+	}
+}
+
+func TestParseRelativeTimeInvalid(t *testing.T) {
+	for _, expression := range []string{"now-", "now-1", "now-1y", "now/y", "now/d/d", "now1h", "today+h"} {
+		_, err := parseRelativeTime("to", expression, time.Now())
+		if err, ok := err.(domain.ValidationError); !ok || err.Field != "to" {
+			t.Errorf("%s: expected a ValidationError on to, got %v", expression, err)
+		}
+	}
+}
+
+func TestParseRelativeTimeAcrossClockChange(t *testing.T) {
+	dublin, _ := time.LoadLocation("Europe/Dublin")
+	// noon on the day Dublin's clocks went forward
+	now := time.Date(2015, 3, 29, 12, 0, 0, 0, dublin)
+
+	cases := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"now-1d", time.Date(2015, 3, 28, 12, 0, 0, 0, time.UTC)},
+		{"now-24h", time.Date(2015, 3, 28, 11, 0, 0, 0, time.UTC)},
+		{"today", time.Date(2015, 3, 29, 0, 0, 0, 0, time.UTC)},
+		{"today+1d", time.Date(2015, 3, 29, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		parsed, err := parseRelativeTime("from", c.expression, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.expression, err)
+		} else if !parsed.Equal(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.expression, c.expected, parsed.UTC())
+		}
+	}
+}
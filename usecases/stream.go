@@ -0,0 +1,74 @@
+package usecases
+
+import (
+	"context"
+	"path"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// EventFilter selects events by name, either exactly or by a glob pattern
+// such as "signup.*". An empty filter selects every event.
+type EventFilter struct {
+	Name    string
+	Pattern string
+}
+
+// Validate returns a ValidationError if the filter's pattern is malformed.
+func (filter EventFilter) Validate() error {
+	if _, err := path.Match(filter.Pattern, ""); err != nil {
+		return domain.ValidationError{Field: "pattern", Reason: "is not a valid pattern"}
+	}
+	return nil
+}
+
+// Matches reports whether an event named `name` is selected by the filter.
+func (filter EventFilter) Matches(name string) bool {
+	if filter.Name != "" && name != filter.Name {
+		return false
+	}
+	if filter.Pattern != "" {
+		matched, _ := path.Match(filter.Pattern, name)
+		return matched
+	}
+	return true
+}
+
+// StreamEvents subscribes to the events stored by `tenant` which are selected
+// by `filter`. If `afterID` is non-zero, events already stored with a greater
+// ID are delivered first. The subscription must be closed when no longer
+// needed.
+func (interactor *EventInteractor) StreamEvents(tenant string, filter EventFilter, afterID int64) (domain.Subscription, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if afterID < 0 {
+		return nil, domain.ValidationError{Field: "Last-Event-ID", Reason: "must not be negative"}
+	}
+
+	subscriber, ok := interactor.Store.ForTenant(tenant).(domain.EventSubscriber)
+	if !ok {
+		return nil, UnsupportedError{Operation: "streaming events"}
+	}
+
+	sub, err := subscriber.Subscribe(afterID)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredSubscription{Subscription: sub, filter: filter}, nil
+}
+
+// filteredSubscription skips events not selected by its filter.
+type filteredSubscription struct {
+	domain.Subscription
+	filter EventFilter
+}
+
+func (sub *filteredSubscription) Next(ctx context.Context) (domain.StoredEvent, error) {
+	for {
+		event, err := sub.Subscription.Next(ctx)
+		if err != nil || sub.filter.Matches(event.Name) {
+			return event, err
+		}
+	}
+}
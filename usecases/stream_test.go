@@ -0,0 +1,80 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestEventFilter(t *testing.T) {
+	cases := []struct {
+		filter  EventFilter
+		name    string
+		matches bool
+	}{
+		{EventFilter{}, "signup", true},
+		{EventFilter{Name: "signup"}, "signup", true},
+		{EventFilter{Name: "signup"}, "login", false},
+		{EventFilter{Pattern: "user.*"}, "user.signup", true},
+		{EventFilter{Pattern: "user.*"}, "order.placed", false},
+		{EventFilter{Name: "user.signup", Pattern: "order.*"}, "user.signup", false},
+	}
+
+	for _, c := range cases {
+		if c.filter.Matches(c.name) != c.matches {
+			t.Errorf("expected %+v matching %q to be %t", c.filter, c.name, c.matches)
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	store := &StubSubscriberEventStore{events: []domain.StoredEvent{
+		{ID: 4, Event: domain.Event{Name: "user.signup", Timestamp: 1423666860}},
+		{ID: 5, Event: domain.Event{Name: "order.placed", Timestamp: 1423666861}},
+		{ID: 6, Event: domain.Event{Name: "user.login", Timestamp: 1423666862}},
+	}}
+	interactor := EventInteractor{Store: store}
+
+	sub, err := interactor.StreamEvents("test-tenant", EventFilter{Pattern: "user.*"}, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer sub.Close()
+
+	if store.afterID != 3 {
+		t.Errorf("expected subscription after ID 3, got %d", store.afterID)
+	}
+
+	for _, id := range []int64{4, 6} {
+		if event, _ := sub.Next(context.Background()); event.ID != id {
+			t.Errorf("expected event %d, got %+v", id, event)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := sub.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected Next to wait for further events, got %v", err)
+	}
+}
+
+func TestStreamEventsInvalidPattern(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubSubscriberEventStore)}
+
+	_, err := interactor.StreamEvents("test-tenant", EventFilter{Pattern: "[user"}, 0)
+	if e, ok := err.(domain.ValidationError); !ok || e.Field != "pattern" {
+		t.Errorf("expected ValidationError for pattern, got %#v", err)
+	}
+}
+
+func TestStreamEventsUnsupportedStore(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+
+	if _, err := interactor.StreamEvents("test-tenant", EventFilter{}, 0); err == nil {
+		t.Error("expected UnsupportedError")
+	} else if _, ok := err.(UnsupportedError); !ok {
+		t.Errorf("expected UnsupportedError, got %#v", err)
+	}
+}
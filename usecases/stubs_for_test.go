@@ -1,7 +1,10 @@
 package usecases
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"testing"
 
 	"github.com/declantraynor/go-events-service/domain"
 )
@@ -27,11 +30,19 @@ func (stub *StubEventStore) Put(event domain.Event) error {
 	return nil
 }
 
+func (stub *StubEventStore) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
 // EventStore which simulates an error from Put()
 type StubEventStoreWithPutError struct {
 	StubEventStore
 }
 
+func (stub *StubEventStoreWithPutError) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
 func (stub *StubEventStoreWithPutError) Put(event domain.Event) error {
 	return errors.New("error from EventStore->Put")
 }
@@ -41,6 +52,10 @@ type StubEventStoreWithCountError struct {
 	StubEventStore
 }
 
+func (stub *StubEventStoreWithCountError) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
 func (stub *StubEventStoreWithCountError) CountInTimeRange(name string, start, end int64) (int, error) {
 	return 0, errors.New("error from EventStore->CountInTimeRange")
 }
@@ -50,6 +65,203 @@ type StubEventStoreWithNamesError struct {
 	StubEventStore
 }
 
+func (stub *StubEventStoreWithNamesError) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
 func (stub *StubEventStoreWithNamesError) Names() ([]string, error) {
 	return []string{}, errors.New("error from EventStore->Names")
 }
+
+// APIKeyStore which keeps keys in memory
+type StubAPIKeyStore struct {
+	keys map[string]domain.APIKey
+}
+
+func (stub *StubAPIKeyStore) Get(hash string) (domain.APIKey, error) {
+	key, ok := stub.keys[hash]
+	if !ok {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	return key, nil
+}
+
+func (stub *StubAPIKeyStore) Put(key domain.APIKey) error {
+	if stub.keys == nil {
+		stub.keys = map[string]domain.APIKey{}
+	}
+	stub.keys[key.Hash] = key
+	return nil
+}
+
+// APIKeyStore which simulates an error from Get()
+type StubAPIKeyStoreWithGetError struct {
+	StubAPIKeyStore
+}
+
+func (stub *StubAPIKeyStoreWithGetError) Get(hash string) (domain.APIKey, error) {
+	return domain.APIKey{}, errors.New("error from APIKeyStore->Get")
+}
+
+// APIKeyStore which wraps ErrNotFound with the key it could not find
+type StubAPIKeyStoreWrappingNotFound struct {
+	StubAPIKeyStore
+}
+
+func (stub *StubAPIKeyStoreWrappingNotFound) Get(hash string) (domain.APIKey, error) {
+	return domain.APIKey{}, fmt.Errorf("getting API key %s: %w", hash, domain.ErrNotFound)
+}
+
+// EventStore which delivers a fixed sequence of events to subscribers
+type StubSubscriberEventStore struct {
+	StubEventStore
+	events  []domain.StoredEvent
+	afterID int64
+}
+
+func (stub *StubSubscriberEventStore) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
+func (stub *StubSubscriberEventStore) Subscribe(afterID int64) (domain.Subscription, error) {
+	stub.afterID = afterID
+	return &StubSubscription{events: stub.events}, nil
+}
+
+// Subscription which delivers a fixed sequence of events, then waits
+type StubSubscription struct {
+	events []domain.StoredEvent
+}
+
+func (stub *StubSubscription) Next(ctx context.Context) (domain.StoredEvent, error) {
+	if len(stub.events) == 0 {
+		<-ctx.Done()
+		return domain.StoredEvent{}, ctx.Err()
+	}
+	event := stub.events[0]
+	stub.events = stub.events[1:]
+	return event, nil
+}
+
+func (stub *StubSubscription) Close() error {
+	return nil
+}
+
+// AlertRuleStore which keeps rules in memory
+type StubAlertRuleStore struct {
+	rules map[string]domain.AlertRule
+
+	// lostRace makes SetFiring report that another caller made each change
+	lostRace bool
+}
+
+func (stub *StubAlertRuleStore) Get(tenant, id string) (domain.AlertRule, error) {
+	rule, ok := stub.rules[tenant+"/"+id]
+	if !ok {
+		return domain.AlertRule{}, domain.ErrNotFound
+	}
+	return rule, nil
+}
+
+func (stub *StubAlertRuleStore) List(tenant string) ([]domain.AlertRule, error) {
+	rules := []domain.AlertRule{}
+	for _, rule := range stub.rules {
+		if rule.Tenant == tenant {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (stub *StubAlertRuleStore) All() ([]domain.AlertRule, error) {
+	rules := []domain.AlertRule{}
+	for _, rule := range stub.rules {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (stub *StubAlertRuleStore) Put(rule domain.AlertRule) error {
+	if stub.rules == nil {
+		stub.rules = map[string]domain.AlertRule{}
+	}
+	stub.rules[rule.Tenant+"/"+rule.ID] = rule
+	return nil
+}
+
+func (stub *StubAlertRuleStore) Update(rule domain.AlertRule) (domain.AlertRule, error) {
+	existing, ok := stub.rules[rule.Tenant+"/"+rule.ID]
+	if !ok {
+		return domain.AlertRule{}, domain.ErrNotFound
+	}
+	rule.Secret, rule.Firing = existing.Secret, existing.Firing
+	stub.rules[rule.Tenant+"/"+rule.ID] = rule
+	return rule, nil
+}
+
+func (stub *StubAlertRuleStore) Delete(tenant, id string) error {
+	if _, ok := stub.rules[tenant+"/"+id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(stub.rules, tenant+"/"+id)
+	return nil
+}
+
+func (stub *StubAlertRuleStore) SetFiring(tenant, id string, firing bool) (bool, error) {
+	rule, ok := stub.rules[tenant+"/"+id]
+	if !ok {
+		return false, domain.ErrNotFound
+	}
+	if stub.lostRace || rule.Firing == firing {
+		return false, nil
+	}
+	rule.Firing = firing
+	stub.rules[tenant+"/"+id] = rule
+	return true, nil
+}
+
+// AlertNotifier which records the alerts it is asked to deliver
+type StubAlertNotifier struct {
+	alerts []Alert
+	err    error
+}
+
+func (stub *StubAlertNotifier) Notify(rule domain.AlertRule, alert Alert) error {
+	if stub.err != nil {
+		return stub.err
+	}
+	stub.alerts = append(stub.alerts, alert)
+	return nil
+}
+
+// EventStore which counts every name at once, failing if asked for names
+type StubCountingEventStore struct {
+	StubEventStoreWithNamesError
+	start, end int64
+}
+
+func (stub *StubCountingEventStore) ForTenant(tenant string) domain.EventStore {
+	return stub
+}
+
+func (stub *StubCountingEventStore) CountAllInTimeRange(start, end int64) (map[string]int, error) {
+	stub.start, stub.end = start, end
+	return map[string]int{"foo": 18, "bar": 6}, nil
+}
+
+// addEvents records `n` events named `name` at `timestamp` for the tenant
+// "acme", failing the test on any error.
+func addEvents(t *testing.T, interactor EventInteractor, name, timestamp string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		addEvent(t, interactor, "acme", name, timestamp, EventOptions{})
+	}
+}
+
+// addEvent records an event for `tenant`, failing the test on any error.
+func addEvent(t *testing.T, interactor EventInteractor, tenant, name, timestamp string, options EventOptions) {
+	t.Helper()
+	if err := interactor.AddEvent(tenant, name, timestamp, options); err != nil {
+		t.Fatalf("unexpected error adding %s at %s: %s", name, timestamp, err)
+	}
+}
@@ -0,0 +1,62 @@
+package usecases
+
+import (
+	"regexp"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// DefaultTenant owns the data of requests which do not name a tenant.
+const DefaultTenant = "default"
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateTenant returns an InvalidTenantError unless `tenant` consists of
+// 1-64 letters, digits, underscores or dashes.
+func ValidateTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return InvalidTenantError{Tenant: tenant}
+	}
+	return nil
+}
+
+// ResolveTenant determines which tenant a request operates on, given the API
+// key it was made with and the tenant it asked for, if any. A key bound to a
+// tenant may only be used with that tenant, and a key bound to none may only
+// name a tenant other than the default if it has the admin scope. It returns
+// the tenant as well as any error encountered.
+func ResolveTenant(key domain.APIKey, requested string) (string, error) {
+	if key.Tenant != "" {
+		if requested != "" && requested != key.Tenant {
+			return "", TenantForbiddenError{Tenant: requested}
+		}
+		requested = key.Tenant
+	} else if requested != "" && requested != DefaultTenant && !hasScope(key, ScopeAdmin) {
+		return "", TenantForbiddenError{Tenant: requested}
+	}
+	return SelectTenant(requested)
+}
+
+// SelectTenant returns `requested`, or DefaultTenant if it is empty, for
+// requests which are not authenticated and so may name any tenant, as well
+// as any error encountered.
+func SelectTenant(requested string) (string, error) {
+	tenant := requested
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+
+	if err := ValidateTenant(tenant); err != nil {
+		return "", err
+	}
+	return tenant, nil
+}
+
+func hasScope(key domain.APIKey, scope string) bool {
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+func TestResolveTenant(t *testing.T) {
+	cases := []struct {
+		keyTenant string
+		scope     string
+		requested string
+		expected  string
+	}{
+		{"", ScopeRead, "", DefaultTenant},
+		{"", ScopeRead, DefaultTenant, DefaultTenant},
+		{"", ScopeAdmin, "acme", "acme"},
+		{"acme", ScopeRead, "", "acme"},
+		{"acme", ScopeRead, "acme", "acme"},
+	}
+
+	for _, c := range cases {
+		key := domain.APIKey{Tenant: c.keyTenant, Scopes: []string{c.scope}}
+		tenant, err := ResolveTenant(key, c.requested)
+		if err != nil || tenant != c.expected {
+			t.Errorf("expected tenant %q, got %q (%v)", c.expected, tenant, err)
+		}
+	}
+}
+
+func TestResolveTenantRejectsOtherTenants(t *testing.T) {
+	_, err := ResolveTenant(domain.APIKey{Tenant: "acme"}, "globex")
+	if _, ok := err.(TenantForbiddenError); !ok {
+		t.Errorf("expected TenantForbiddenError, got %T", err)
+	}
+}
+
+func TestResolveTenantRequiresAdminToNameATenant(t *testing.T) {
+	_, err := ResolveTenant(domain.APIKey{Scopes: []string{ScopeRead, ScopeWrite}}, "acme")
+	if _, ok := err.(TenantForbiddenError); !ok {
+		t.Errorf("expected TenantForbiddenError, got %T", err)
+	}
+}
+
+func TestResolveTenantRejectsInvalidTenants(t *testing.T) {
+	for _, tenant := range []string{"acme:corp", "acme corp", "*"} {
+		_, err := ResolveTenant(domain.APIKey{Scopes: []string{ScopeAdmin}}, tenant)
+		if _, ok := err.(InvalidTenantError); !ok {
+			t.Errorf("expected InvalidTenantError for %q, got %T", tenant, err)
+		}
+	}
+}
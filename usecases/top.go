@@ -0,0 +1,149 @@
+package usecases
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// MaxTopLimit bounds the number of names TopEvents may be asked for.
+const MaxTopLimit = 1000
+
+// TopOptions describe which names TopEvents returns: at most Limit of them,
+// ranked by their counts, or if Movers is set, by how much their counts
+// changed from the previous time range of the same length.
+type TopOptions struct {
+	Limit  int
+	Movers bool
+}
+
+// TopEvent is the number of events of one name in a time range, and, when
+// ranking movers, in the previous range of the same length and the change
+// between them.
+type TopEvent struct {
+	Name          string
+	Count         int
+	PreviousCount int
+	Change        int
+}
+
+// TopEvents returns the names of the events stored by `tenant` with a
+// timestamp in `timeRange` which occurred most often, in descending order
+// of their counts, or the names whose counts changed most from the previous
+// range of the same length, in descending order of the size of the change.
+// Names whose counts did not change are not movers. Ties are broken by
+// name. The counts of every name in each range are fetched at once, but
+// only the top names are kept while ranking, so that ranking itself need
+// not sort every name.
+func (interactor *EventInteractor) TopEvents(tenant string, timeRange TimeRange, options TopOptions) ([]TopEvent, error) {
+	if options.Limit < 1 || options.Limit > MaxTopLimit {
+		return nil, domain.ValidationError{Field: "limit", Reason: "must be between 1 and 1000"}
+	}
+
+	start, end, err := timeRange.parse(interactor.now())
+	if err != nil {
+		return nil, err
+	}
+
+	store := interactor.Store.ForTenant(tenant)
+	counts, err := countAll(store, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	top := &topHeap{limit: options.Limit}
+	if !options.Movers {
+		for name, count := range counts {
+			top.offer(TopEvent{Name: name, Count: count}, count)
+		}
+		return top.sorted(), nil
+	}
+
+	previousStart, previousEnd, _ := comparisonPeriod(ComparePreviousPeriod, start, end)
+	previous, err := countAll(store, previousStart, previousEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, count := range counts {
+		change := count - previous[name]
+		top.offer(TopEvent{Name: name, Count: count, PreviousCount: previous[name], Change: change}, abs(change))
+	}
+	for name, count := range previous {
+		if _, ok := counts[name]; !ok {
+			top.offer(TopEvent{Name: name, PreviousCount: count, Change: -count}, count)
+		}
+	}
+	return top.sorted(), nil
+}
+
+// rankedEvent is an event along with the rank by which it is ordered.
+type rankedEvent struct {
+	TopEvent
+	rank int
+}
+
+// outranks reports whether `event` ranks above `other`.
+func (event rankedEvent) outranks(other rankedEvent) bool {
+	if event.rank != other.rank {
+		return event.rank > other.rank
+	}
+	return event.Name < other.Name
+}
+
+// topHeap keeps the `limit` highest ranked events offered to it, as a
+// min-heap whose root is the lowest ranked of those kept.
+type topHeap struct {
+	limit  int
+	events []rankedEvent
+}
+
+// offer keeps `event` if it is among the top events offered so far. Events
+// with a rank of zero are never kept.
+func (top *topHeap) offer(event TopEvent, rank int) {
+	ranked := rankedEvent{event, rank}
+	switch {
+	case rank <= 0:
+	case len(top.events) < top.limit:
+		heap.Push(top, ranked)
+	case ranked.outranks(top.events[0]):
+		top.events[0] = ranked
+		heap.Fix(top, 0)
+	}
+}
+
+// sorted returns the kept events, highest ranked first.
+func (top *topHeap) sorted() []TopEvent {
+	sort.Slice(top.events, func(i, j int) bool {
+		return top.events[i].outranks(top.events[j])
+	})
+
+	events := make([]TopEvent, len(top.events))
+	for i, ranked := range top.events {
+		events[i] = ranked.TopEvent
+	}
+	return events
+}
+
+func (top *topHeap) Len() int           { return len(top.events) }
+func (top *topHeap) Less(i, j int) bool { return top.events[j].outranks(top.events[i]) }
+func (top *topHeap) Swap(i, j int)      { top.events[i], top.events[j] = top.events[j], top.events[i] }
+
+func (top *topHeap) Push(x interface{}) {
+	top.events = append(top.events, x.(rankedEvent))
+}
+
+func (top *topHeap) Pop() interface{} {
+	n := len(top.events) - 1
+	event := top.events[n]
+	top.events = top.events[:n]
+	return event
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
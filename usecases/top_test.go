@@ -0,0 +1,94 @@
+package usecases
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+func newTopInteractor(t *testing.T) EventInteractor {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+
+	// the previous hour
+	addEvents(t, interactor, "login", "2015-02-11T14:10:00Z", 5)
+	addEvents(t, interactor, "error", "2015-02-11T14:20:00Z", 1)
+	addEvents(t, interactor, "logout", "2015-02-11T14:30:00Z", 4)
+
+	// the hour being ranked
+	addEvents(t, interactor, "login", "2015-02-11T15:10:00Z", 6)
+	addEvents(t, interactor, "error", "2015-02-11T15:20:00Z", 9)
+	addEvents(t, interactor, "signup", "2015-02-11T15:30:00Z", 2)
+	addEvents(t, interactor, "search", "2015-02-11T15:40:00Z", 2)
+	return interactor
+}
+
+func TestTopEvents(t *testing.T) {
+	interactor := newTopInteractor(t)
+	top, err := interactor.TopEvents("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, TopOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []TopEvent{
+		{Name: "error", Count: 9},
+		{Name: "login", Count: 6},
+		{Name: "search", Count: 2},
+	}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %+v, got %+v", expected, top)
+	}
+}
+
+func TestTopMovers(t *testing.T) {
+	interactor := newTopInteractor(t)
+	top, err := interactor.TopEvents("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, TopOptions{Limit: 10, Movers: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []TopEvent{
+		{Name: "error", Count: 9, PreviousCount: 1, Change: 8},
+		{Name: "logout", Count: 0, PreviousCount: 4, Change: -4},
+		{Name: "search", Count: 2, PreviousCount: 0, Change: 2},
+		{Name: "signup", Count: 2, PreviousCount: 0, Change: 2},
+		{Name: "login", Count: 6, PreviousCount: 5, Change: 1},
+	}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %+v, got %+v", expected, top)
+	}
+}
+
+func TestTopEventsInvalidLimit(t *testing.T) {
+	interactor := newTopInteractor(t)
+	for _, limit := range []int{0, MaxTopLimit + 1} {
+		_, err := interactor.TopEvents("acme", TimeRange{From: "2015-02-11T15:00:00Z", To: "2015-02-11T16:00:00Z"}, TopOptions{Limit: limit})
+		if e, ok := err.(domain.ValidationError); !ok || e.Field != "limit" {
+			t.Errorf("expected ValidationError for limit %d, got %v", limit, err)
+		}
+	}
+}
+
+func TestTopHeapKeepsHighestRanked(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	var all []rankedEvent
+	top := &topHeap{limit: 10}
+	for i := 0; i < 1000; i++ {
+		event := TopEvent{Name: fmt.Sprintf("event-%d", i), Count: random.Intn(50)}
+		all = append(all, rankedEvent{event, event.Count})
+		top.offer(event, event.Count)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].outranks(all[j]) })
+	var expected []TopEvent
+	for _, ranked := range all[:10] {
+		expected = append(expected, ranked.TopEvent)
+	}
+	if sorted := top.sorted(); !reflect.DeepEqual(sorted, expected) {
+		t.Errorf("expected %+v, got %+v", expected, sorted)
+	}
+}
@@ -0,0 +1,27 @@
+package usecases
+
+import (
+	"strings"
+
+	"github.com/declantraynor/go-events-service/domain"
+)
+
+// CountUniqueActors returns an estimate of the number of distinct actors of
+// the events named `name` stored by `tenant` with a timestamp in
+// `timeRange`, as well as any error encountered.
+func (interactor *EventInteractor) CountUniqueActors(tenant, name string, timeRange TimeRange) (int, error) {
+	if strings.TrimSpace(name) == "" {
+		return 0, domain.ValidationError{Field: "name", Reason: "is required"}
+	}
+
+	start, end, err := timeRange.parse(interactor.now())
+	if err != nil {
+		return 0, err
+	}
+
+	counter, ok := interactor.Store.ForTenant(tenant).(domain.UniqueCounter)
+	if !ok {
+		return 0, UnsupportedError{Operation: "counting unique actors"}
+	}
+	return counter.CountUniqueInTimeRange(name, start, end)
+}
@@ -0,0 +1,54 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/declantraynor/go-events-service/domain"
+	"github.com/declantraynor/go-events-service/internal/storetest"
+)
+
+func TestCountUniqueActors(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	interactor.AddEvent("acme", "login", "2015-02-11T15:01:00Z", EventOptions{Actor: "alice"})
+	interactor.AddEvent("acme", "login", "2015-02-11T15:01:10Z", EventOptions{Actor: "bob"})
+	interactor.AddEvent("acme", "login", "2015-02-11T15:01:20Z", EventOptions{Actor: "alice"})
+	interactor.AddEvent("acme", "login", "2015-02-11T15:01:30Z", EventOptions{})
+	interactor.AddEvent("acme", "login", "2015-02-11T15:02:00Z", EventOptions{Actor: "carol"})
+	interactor.AddEvent("acme", "logout", "2015-02-11T15:01:40Z", EventOptions{Actor: "dave"})
+	interactor.AddEvent("globex", "login", "2015-02-11T15:01:50Z", EventOptions{Actor: "erin"})
+
+	count, err := interactor.CountUniqueActors("acme", "login", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 unique actors, got %d", count)
+	}
+}
+
+func TestCountUniqueActorsRequiresName(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	_, err := interactor.CountUniqueActors("acme", " ", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"})
+
+	if err != (domain.ValidationError{Field: "name", Reason: "is required"}) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestCountUniqueActorsInvalidTimeRange(t *testing.T) {
+	interactor := EventInteractor{Store: storetest.NewEventStore()}
+	_, err := interactor.CountUniqueActors("acme", "login", TimeRange{From: "2015-02-11T15:01:59Z", To: "2015-02-11T15:01:00Z"})
+
+	if _, ok := err.(InvalidTimeRangeError); !ok {
+		t.Errorf("expected InvalidTimeRangeError, got %v", err)
+	}
+}
+
+func TestCountUniqueActorsUnsupported(t *testing.T) {
+	interactor := EventInteractor{Store: new(StubEventStore)}
+	_, err := interactor.CountUniqueActors("acme", "login", TimeRange{From: "2015-02-11T15:01:00Z", To: "2015-02-11T15:02:00Z"})
+
+	if err != (UnsupportedError{Operation: "counting unique actors"}) {
+		t.Errorf("expected UnsupportedError, got %v", err)
+	}
+}
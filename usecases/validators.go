@@ -20,3 +20,70 @@ func ParseTimestamp(timestamp string) (time.Time, error) {
 
 	return t, nil
 }
+
+// parseTimestampField parses a timestamp as ParseTimestamp does, naming
+// `field` in any InvalidTimestampError returned.
+func parseTimestampField(field, timestamp string) (time.Time, error) {
+	t, err := ParseTimestamp(timestamp)
+	if e, ok := err.(InvalidTimestampError); ok {
+		e.Field = field
+		return t, e
+	}
+	return t, err
+}
+
+// parseTimeField parses a timestamp as parseTimestampField does, or resolves
+// a relative time expression against `now`.
+func parseTimeField(field, timestamp string, now time.Time) (time.Time, error) {
+	if isRelativeTime(timestamp) {
+		return parseRelativeTime(field, timestamp, now)
+	}
+	return parseTimestampField(field, timestamp)
+}
+
+// TimeRange is the range of time between From and To, each an ISO8601 UTC
+// timestamp or a relative time expression such as `now-1h`. Unless Closed
+// is set, the range is half-open: it includes From but not To, so that
+// consecutive ranges sharing a boundary never both include an event at it,
+// and counts over them add up to the count over their union.
+type TimeRange struct {
+	From   string
+	To     string
+	Closed bool
+}
+
+// parse returns the first and last whole seconds within the range as Unix
+// times, resolving relative times against `now`. It returns an
+// InvalidTimeRangeError if From is not before To, and an
+// EmptyTimeRangeError if the range includes no whole second. Stores count
+// the events between two seconds inclusive, so converting every range here
+// applies the same boundaries in every store.
+func (timeRange TimeRange) parse(now time.Time) (int64, int64, error) {
+	from, err := parseTimeField("from", timeRange.From, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := parseTimeField("to", timeRange.To, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !from.Before(to) {
+		return 0, 0, InvalidTimeRangeError{From: timeRange.From, To: timeRange.To}
+	}
+
+	// event timestamps are whole seconds, so a fractional boundary falls
+	// between two of them
+	start, end := from.Unix(), to.Unix()
+	if from.Nanosecond() != 0 {
+		start++
+	}
+	if !timeRange.Closed && to.Nanosecond() == 0 {
+		end--
+	}
+	if end < start {
+		return 0, 0, EmptyTimeRangeError{From: timeRange.From, To: timeRange.To}
+	}
+	return start, end, nil
+}